@@ -0,0 +1,335 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"leaderboard-system/models"
+)
+
+const (
+	DefaultBreakerFailures = 5
+	DefaultBreakerCooldown = 10 * time.Second
+
+	// breakerFlushTimeout bounds the flush that follows an outage.
+	breakerFlushTimeout = time.Minute
+)
+
+// ErrCircuitOpen is returned by the few BreakerCache calls that cannot be
+// answered without the cache, like RankEpoch, while the breaker is open.
+var ErrCircuitOpen = errors.New("cache circuit breaker open")
+
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+
+type BreakerStats struct {
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	Opens               uint64       `json:"opens"`
+	Bypassed            uint64       `json:"bypassed"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+}
+
+// BreakerReporter is implemented by caches that sit behind a circuit
+// breaker; ok is false when this one does not.
+type BreakerReporter interface {
+	BreakerStats() (stats BreakerStats, ok bool)
+}
+
+// BreakerCache puts a circuit breaker in front of a cache, normally the
+// Redis CacheManager. After Failures calls in a row fail it opens, and for
+// Cooldown the service runs as if the cache were empty: reads miss, writes
+// and invalidations are dropped, and every caller gets the load lock. Then
+// one call is let through to probe; its success closes the breaker, its
+// failure opens it for another Cooldown.
+//
+// Invalidations dropped while open could leave entries stale once the cache
+// is back, so the first close after dropping any flushes the cache. An
+// outage ends with a cold cache rather than a wrong one.
+type BreakerCache struct {
+	next     Cache
+	failures int
+	cooldown time.Duration
+
+	mu          sync.Mutex
+	state       BreakerState
+	consecutive int
+	openedAt    time.Time
+	probing     bool
+	dropped     bool
+	lastErr     string
+
+	opens    uint64
+	bypassed uint64
+}
+
+
+func NewBreakerCache(next Cache, failures int, cooldown time.Duration) *BreakerCache {
+	if failures <= 0 {
+		failures = DefaultBreakerFailures
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	return &BreakerCache{
+		next:     next,
+		failures: failures,
+		cooldown: cooldown,
+		state:    BreakerClosed,
+	}
+}
+
+// allow reports whether a call may go to the cache. While open, the first
+// call after the cooldown goes through as the probe.
+func (b *BreakerCache) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			break
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if !b.probing {
+			b.probing = true
+			return true
+		}
+	default:
+		return true
+	}
+	atomic.AddUint64(&b.bypassed, 1)
+	return false
+}
+
+// record counts the outcome of a call allow let through. A call cut short
+// by its own context says nothing about the cache and is not counted.
+func (b *BreakerCache) record(ctx context.Context, err error) {
+	if err != nil && ctx.Err() != nil {
+		b.mu.Lock()
+		b.probing = false
+		b.mu.Unlock()
+		return
+	}
+
+	b.mu.Lock()
+	b.probing = false
+	if err == nil {
+		recovered := b.state != BreakerClosed && b.dropped
+		b.state = BreakerClosed
+		b.consecutive = 0
+		if recovered {
+			b.dropped = false
+		}
+		b.mu.Unlock()
+		if recovered {
+			go b.flushStale()
+		}
+		return
+	}
+
+	b.consecutive++
+	b.lastErr = err.Error()
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.consecutive >= b.failures) {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+		atomic.AddUint64(&b.opens, 1)
+	}
+	b.mu.Unlock()
+}
+
+// drop notes a write or invalidation skipped while open.
+func (b *BreakerCache) drop() {
+	b.mu.Lock()
+	b.dropped = true
+	b.mu.Unlock()
+}
+
+
+func (b *BreakerCache) flushStale() {
+	ctx, cancel := context.WithTimeout(context.Background(), breakerFlushTimeout)
+	defer cancel()
+
+	if err := b.next.Flush(ctx); err != nil {
+		// Try again at the next recovery.
+		b.drop()
+	}
+}
+
+
+func (b *BreakerCache) BreakerStats() (BreakerStats, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BreakerStats{
+		State:               b.state,
+		ConsecutiveFailures: b.consecutive,
+		Opens:               atomic.LoadUint64(&b.opens),
+		Bypassed:            atomic.LoadUint64(&b.bypassed),
+		LastError:           b.lastErr,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt.UTC()
+		stats.OpenedAt = &openedAt
+	}
+	return stats, true
+}
+
+// write runs a write or invalidation through the breaker, dropping it while
+// open.
+func (b *BreakerCache) write(ctx context.Context, op func() error) error {
+	if !b.allow() {
+		b.drop()
+		return nil
+	}
+	err := op()
+	b.record(ctx, err)
+	return err
+}
+
+
+func (b *BreakerCache) SetUser(ctx context.Context, user *models.User) error {
+	return b.write(ctx, func() error { return b.next.SetUser(ctx, user) })
+}
+
+
+func (b *BreakerCache) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	if !b.allow() {
+		return nil, nil
+	}
+	user, err := b.next.GetUser(ctx, userID)
+	b.record(ctx, err)
+	return user, err
+}
+
+
+func (b *BreakerCache) InvalidateUser(ctx context.Context, userID string) error {
+	return b.write(ctx, func() error { return b.next.InvalidateUser(ctx, userID) })
+}
+
+
+func (b *BreakerCache) RankEpoch(ctx context.Context) (int64, error) {
+	if !b.allow() {
+		return 0, ErrCircuitOpen
+	}
+	epoch, err := b.next.RankEpoch(ctx)
+	b.record(ctx, err)
+	return epoch, err
+}
+
+
+func (b *BreakerCache) BumpRankEpoch(ctx context.Context) (int64, error) {
+	if !b.allow() {
+		b.drop()
+		return 0, ErrCircuitOpen
+	}
+	epoch, err := b.next.BumpRankEpoch(ctx)
+	b.record(ctx, err)
+	return epoch, err
+}
+
+
+func (b *BreakerCache) SetRank(ctx context.Context, epoch int64, userID string, rank int64, delta time.Duration) error {
+	return b.write(ctx, func() error { return b.next.SetRank(ctx, epoch, userID, rank, delta) })
+}
+
+
+func (b *BreakerCache) GetRank(ctx context.Context, epoch int64, userID string) (int64, Freshness, error) {
+	if !b.allow() {
+		return 0, Freshness{}, nil
+	}
+	rank, fresh, err := b.next.GetRank(ctx, epoch, userID)
+	b.record(ctx, err)
+	return rank, fresh, err
+}
+
+
+func (b *BreakerCache) SetLeaderboardPage(ctx context.Context, board string, page, pageSize int, resp *models.LeaderboardResponse, delta time.Duration) error {
+	return b.write(ctx, func() error { return b.next.SetLeaderboardPage(ctx, board, page, pageSize, resp, delta) })
+}
+
+
+func (b *BreakerCache) GetLeaderboardPage(ctx context.Context, board string, page, pageSize int) (*models.LeaderboardResponse, Freshness, error) {
+	if !b.allow() {
+		return nil, Freshness{}, nil
+	}
+	resp, fresh, err := b.next.GetLeaderboardPage(ctx, board, page, pageSize)
+	b.record(ctx, err)
+	return resp, fresh, err
+}
+
+
+func (b *BreakerCache) InvalidateLeaderboardRange(ctx context.Context, board string, minRating, maxRating int32) error {
+	return b.write(ctx, func() error { return b.next.InvalidateLeaderboardRange(ctx, board, minRating, maxRating) })
+}
+
+
+func (b *BreakerCache) InvalidateLeaderboard(ctx context.Context, board string) error {
+	return b.write(ctx, func() error { return b.next.InvalidateLeaderboard(ctx, board) })
+}
+
+// AcquireLock hands out a lock that guards nothing while open; callers then
+// load for themselves, coalesced only within their own process.
+func (b *BreakerCache) AcquireLock(ctx context.Context, name string, ttl time.Duration) (func(context.Context) error, bool, error) {
+	if !b.allow() {
+		return func(context.Context) error { return nil }, true, nil
+	}
+	release, acquired, err := b.next.AcquireLock(ctx, name, ttl)
+	b.record(ctx, err)
+	return release, acquired, err
+}
+
+// Ping fails fast while open, so health checks report the outage without
+// adding to it.
+func (b *BreakerCache) Ping(ctx context.Context) error {
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+	err := b.next.Ping(ctx)
+	b.record(ctx, err)
+	return err
+}
+
+
+func (b *BreakerCache) Close() error {
+	return b.next.Close()
+}
+
+
+func (b *BreakerCache) Flush(ctx context.Context) error {
+	return b.next.Flush(ctx)
+}
+
+// PublishInvalidation drops broadcasts while open like any other
+// invalidation. It requires next to be an InvalidationBus.
+func (b *BreakerCache) PublishInvalidation(ctx context.Context, msg Invalidation) error {
+	bus, ok := b.next.(InvalidationBus)
+	if !ok {
+		return nil
+	}
+	return b.write(ctx, func() error { return bus.PublishInvalidation(ctx, msg) })
+}
+
+// SubscribeInvalidations bypasses the breaker: the subscription reconnects
+// on its own.
+func (b *BreakerCache) SubscribeInvalidations(ctx context.Context, handler func(Invalidation)) (func() error, error) {
+	bus, ok := b.next.(InvalidationBus)
+	if !ok {
+		return nil, errors.New("cache does not broadcast invalidations")
+	}
+	return bus.SubscribeInvalidations(ctx, handler)
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"leaderboard-system/models"
+)
+
+// flakyCache is a cache whose Ping fails while down.
+type flakyCache struct {
+	Cache
+
+	mu    sync.Mutex
+	down  bool
+	pings int
+}
+
+func (c *flakyCache) Ping(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pings++
+	if c.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (c *flakyCache) set(down bool) {
+	c.mu.Lock()
+	c.down = down
+	c.mu.Unlock()
+}
+
+// The breaker opens after its failures in a row, bypasses the cache for the
+// cooldown, probes once, and after an outage that dropped writes comes back
+// to a flushed cache.
+func TestBreakerCacheStates(t *testing.T) {
+	ctx := context.Background()
+	const cooldown = 20 * time.Millisecond
+	next := &flakyCache{Cache: NewMemoryCache(100)}
+	b := NewBreakerCache(next, 2, cooldown)
+
+	if err := next.SetUser(ctx, &models.User{ID: "u1", Username: "alice", Rating: 1000}); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(step string, state BreakerState, opens, bypassed uint64, pings int) {
+		t.Helper()
+		stats, _ := b.BreakerStats()
+		next.mu.Lock()
+		got := next.pings
+		next.mu.Unlock()
+		if stats.State != state || stats.Opens != opens || stats.Bypassed != bypassed || got != pings {
+			t.Fatalf("%s: state %s, opens %d, bypassed %d, pings %d; want %s, %d, %d, %d",
+				step, stats.State, stats.Opens, stats.Bypassed, got, state, opens, bypassed, pings)
+		}
+	}
+
+	next.set(true)
+	b.Ping(ctx)
+	expect("one failure", BreakerClosed, 0, 0, 1)
+	b.Ping(ctx)
+	expect("two failures", BreakerOpen, 1, 0, 2)
+
+	if err := b.Ping(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Ping while open = %v, want %v", err, ErrCircuitOpen)
+	}
+	if err := b.InvalidateUser(ctx, "u1"); err != nil {
+		t.Fatalf("InvalidateUser while open = %v, want it dropped", err)
+	}
+	expect("open", BreakerOpen, 1, 2, 2)
+
+	time.Sleep(cooldown)
+	b.Ping(ctx)
+	expect("failed probe", BreakerOpen, 2, 2, 3)
+
+	next.set(false)
+	time.Sleep(cooldown)
+	if err := b.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expect("recovered", BreakerClosed, 2, 2, 4)
+
+	// The invalidation dropped while open is made good by a flush.
+	deadline := time.Now().Add(time.Second)
+	for {
+		user, err := next.GetUser(ctx, "u1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cache not flushed after recovering from dropped writes")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// A call cut short by its own context says nothing about the cache.
+func TestBreakerCacheIgnoresCanceledCalls(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	next := &flakyCache{Cache: NewMemoryCache(100), down: true}
+	b := NewBreakerCache(next, 1, time.Minute)
+
+	b.Ping(ctx)
+	if stats, _ := b.BreakerStats(); stats.State != BreakerClosed || stats.ConsecutiveFailures != 0 {
+		t.Fatalf("after a canceled call: %+v, want closed with no failures", stats)
+	}
+}
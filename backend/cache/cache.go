@@ -3,12 +3,17 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"leaderboard-system/config"
 	"leaderboard-system/models"
+	"leaderboard-system/tenant"
 )
 
 const (
@@ -20,36 +25,209 @@ const (
 	
 	UserCacheKeyPrefix   = "user:"
 	RankCacheKeyPrefix   = "rank:"
+	RankEpochKey         = "rank:epoch"
 	LeaderboardCacheKey  = "leaderboard"
+	LockKeyPrefix        = "lock:"
+
+	// KeySchemaVersion is part of every Redis key. Bump it when the encoding
+	// of a cached value changes, so a deploy reads only entries it wrote and
+	// the old ones expire untouched.
+	KeySchemaVersion = 2
+
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+	BackendTiered = "tiered"
 )
 
+// Cache is the storage the service layer caches users, ranks and leaderboard
+// pages in. CacheManager backs it with Redis, MemoryCache keeps everything in
+// process for single-node setups and tests.
+//
+// Every key, rank epoch and lock belongs to the tenant the context acts for,
+// so tenants never see each other's entries. Flush clears all of them.
+type Cache interface {
+	SetUser(ctx context.Context, user *models.User) error
+	GetUser(ctx context.Context, userID string) (*models.User, error)
+	InvalidateUser(ctx context.Context, userID string) error
+
+	// Ranks are cached per rank epoch. Any rating change can shift the rank
+	// of every user between the old and new rating, so writers bump the epoch
+	// instead of tracking who was displaced, and entries from older epochs
+	// are never read again.
+	//
+	// Ranks and leaderboard pages are stored with their Freshness and stay
+	// readable for StaleFactor times their TTL, so callers can serve a stale
+	// value while refreshing it. delta is how long the value took to compute.
+	RankEpoch(ctx context.Context) (int64, error)
+	BumpRankEpoch(ctx context.Context) (int64, error)
+	SetRank(ctx context.Context, epoch int64, userID string, rank int64, delta time.Duration) error
+	GetRank(ctx context.Context, epoch int64, userID string) (int64, Freshness, error)
+
+	SetLeaderboardPage(ctx context.Context, board string, page, pageSize int, resp *models.LeaderboardResponse, delta time.Duration) error
+	GetLeaderboardPage(ctx context.Context, board string, page, pageSize int) (*models.LeaderboardResponse, Freshness, error)
+	InvalidateLeaderboardRange(ctx context.Context, board string, minRating, maxRating int32) error
+	InvalidateLeaderboard(ctx context.Context, board string) error
+
+	// AcquireLock takes a short-lived lock shared by every instance using the
+	// cache. When acquired is false another holder has it; release is only
+	// set when the lock was taken.
+	AcquireLock(ctx context.Context, name string, ttl time.Duration) (release func(context.Context) error, acquired bool, err error)
+
+	Ping(ctx context.Context) error
+	Close() error
+	Flush(ctx context.Context) error
+}
+
+
+func NewCache(cfg *config.CacheConfig, redisCfg *config.RedisConfig) (Cache, error) {
+	switch cfg.Backend {
+	case BackendRedis, "":
+		cm, err := NewCacheManager(redisCfg, cfg.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		return NewBreakerCache(cm, cfg.BreakerFailures, cfg.BreakerCooldown), nil
+	case BackendMemory:
+		return NewMemoryCache(cfg.MaxEntries), nil
+	case BackendTiered:
+		cm, err := NewCacheManager(redisCfg, cfg.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		bc := NewBreakerCache(cm, cfg.BreakerFailures, cfg.BreakerCooldown)
+		tc, err := NewTieredCache(bc, bc, cfg.L1MaxEntries, cfg.L1TTL)
+		if err != nil {
+			cm.Close()
+			return nil, err
+		}
+		return tc, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %s", cfg.Backend)
+	}
+}
+
+
+// tenantKey places a logical key among those of the tenant ctx acts for.
+// Tenant IDs contain no ':' or braces, so keys of different tenants never
+// collide and never form a hash tag.
+func tenantKey(ctx context.Context, logical string) string {
+	return fmt.Sprintf("t:%s:%s", tenant.ID(ctx), logical)
+}
+
+
+func userKey(ctx context.Context, userID string) string {
+	return tenantKey(ctx, UserCacheKeyPrefix+userID)
+}
+
+
+func rankKey(ctx context.Context, epoch int64, userID string) string {
+	return tenantKey(ctx, fmt.Sprintf("%s%d:%s", RankCacheKeyPrefix, epoch, userID))
+}
 
+
+func rankEpochKey(ctx context.Context) string {
+	return tenantKey(ctx, RankEpochKey)
+}
+
+// initialRankEpoch seeds a missing epoch from the clock, so an epoch lost to
+// eviction restarts above any value still referenced by live rank entries.
+func initialRankEpoch() int64 {
+	return time.Now().UnixMilli()
+}
+
+
+func lockKey(ctx context.Context, name string) string {
+	return tenantKey(ctx, LockKeyPrefix+name)
+}
+
+
+// CacheManager stores everything under "<namespace>:v<KeySchemaVersion>:t:<tenant>:",
+// so environments can share a Redis database and Flush only touches our keys.
 type CacheManager struct {
-	client *redis.Client
+	client    redis.UniversalClient
+	namespace string
+	prefix    string
 }
 
 
-func NewCacheManager(cfg *config.RedisConfig) (*CacheManager, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+func NewCacheManager(cfg *config.RedisConfig, namespace string) (*CacheManager, error) {
+	if err := validateNamespace(namespace); err != nil {
+		return nil, err
+	}
 
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to redis: %w", err)
 	}
 
-	return &CacheManager{client: client}, nil
+	return &CacheManager{
+		client:    client,
+		namespace: namespace,
+		prefix:    fmt.Sprintf("%s:v%d:", namespace, KeySchemaVersion),
+	}, nil
+}
+
+// validateNamespace rejects namespaces whose keys could not be told apart
+// from another namespace's. A segment shaped like a schema version would let
+// "a:v1" pass for namespace "a" at version 1.
+func validateNamespace(namespace string) error {
+	if namespace == "" {
+		return errors.New("cache namespace must not be empty")
+	}
+	// A brace would act as a Redis Cluster hash tag and pin every key to one
+	// slot.
+	if strings.ContainsAny(namespace, "{}") {
+		return fmt.Errorf("cache namespace %q must not contain braces", namespace)
+	}
+	for _, segment := range strings.Split(namespace, ":") {
+		if isVersionSegment(segment) {
+			return fmt.Errorf("cache namespace %q must not contain a segment like %q, which keys use for the schema version", namespace, segment)
+		}
+	}
+	return nil
+}
+
+// isVersionSegment reports whether segment is "v" followed by digits.
+func isVersionSegment(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'v' {
+		return false
+	}
+	for _, r := range segment[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// inNamespace reports whether key is one of namespace's, at any schema
+// version. A namespace that extends ours, like "<namespace>:eu", matches the
+// same SCAN pattern, but its next segment is not a version.
+func inNamespace(namespace, key string) bool {
+	rest := strings.TrimPrefix(key, namespace+":")
+	if rest == key {
+		return false
+	}
+	version, _, found := strings.Cut(rest, ":")
+	return found && isVersionSegment(version)
+}
+
+// key places a logical key in our namespace.
+func (cm *CacheManager) key(logical string) string {
+	return cm.prefix + logical
 }
 
 
 func (cm *CacheManager) SetUser(ctx context.Context, user *models.User) error {
-	key := fmt.Sprintf("%s%s", UserCacheKeyPrefix, user.ID)
+	key := cm.key(userKey(ctx, user.ID))
 	
 	data, err := json.Marshal(user)
 	if err != nil {
@@ -61,7 +239,7 @@ func (cm *CacheManager) SetUser(ctx context.Context, user *models.User) error {
 
 
 func (cm *CacheManager) GetUser(ctx context.Context, userID string) (*models.User, error) {
-	key := fmt.Sprintf("%s%s", UserCacheKeyPrefix, userID)
+	key := cm.key(userKey(ctx, userID))
 	
 	val, err := cm.client.Get(ctx, key).Result()
 	if err == redis.Nil {
@@ -81,45 +259,205 @@ func (cm *CacheManager) GetUser(ctx context.Context, userID string) (*models.Use
 
 
 func (cm *CacheManager) InvalidateUser(ctx context.Context, userID string) error {
-	key := fmt.Sprintf("%s%s", UserCacheKeyPrefix, userID)
+	key := cm.key(userKey(ctx, userID))
 	return cm.client.Del(ctx, key).Err()
 }
 
 
-func (cm *CacheManager) SetRank(ctx context.Context, userID string, rank int64) error {
-	key := fmt.Sprintf("%s%s", RankCacheKeyPrefix, userID)
-	return cm.client.Set(ctx, key, rank, CacheRankTTL).Err()
+func (cm *CacheManager) RankEpoch(ctx context.Context) (int64, error) {
+	key := cm.key(rankEpochKey(ctx))
+
+	epoch, err := cm.client.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		if err := cm.client.SetNX(ctx, key, initialRankEpoch(), 0).Err(); err != nil {
+			return 0, err
+		}
+		return cm.client.Get(ctx, key).Int64()
+	}
+	return epoch, err
 }
 
 
-func (cm *CacheManager) GetRank(ctx context.Context, userID string) (int64, error) {
-	key := fmt.Sprintf("%s%s", RankCacheKeyPrefix, userID)
+func (cm *CacheManager) BumpRankEpoch(ctx context.Context) (int64, error) {
+	key := cm.key(rankEpochKey(ctx))
+
+	if err := cm.client.SetNX(ctx, key, initialRankEpoch(), 0).Err(); err != nil {
+		return 0, err
+	}
+	return cm.client.Incr(ctx, key).Result()
+}
+
+
+func (cm *CacheManager) SetRank(ctx context.Context, epoch int64, userID string, rank int64, delta time.Duration) error {
+	key := cm.key(rankKey(ctx, epoch, userID))
+
+	data, err := encodeRank(rank, NewFreshness(delta, CacheRankTTL))
+	if err != nil {
+		return err
+	}
+
+	return cm.client.Set(ctx, key, data, storedTTL(CacheRankTTL)).Err()
+}
+
+
+func (cm *CacheManager) GetRank(ctx context.Context, epoch int64, userID string) (int64, Freshness, error) {
+	key := cm.key(rankKey(ctx, epoch, userID))
 	
-	val, err := cm.client.Get(ctx, key).Result()
+	val, err := cm.client.Get(ctx, key).Bytes()
 	if err == redis.Nil {
-		return 0, nil
+		return 0, Freshness{}, nil
 	}
 	if err != nil {
-		return 0, err
+		return 0, Freshness{}, err
 	}
 
-	var rank int64
-	if _, err := fmt.Sscanf(val, "%d", &rank); err != nil {
-		return 0, err
+	return decodeRank(val)
+}
+
+// A board's pages and its page index are written and deleted together, so
+// their keys share a {tenant:board} hash tag and land in one Redis Cluster
+// slot, while different tenants' boards spread over the cluster.
+func leaderboardPageKey(ctx context.Context, board string, page, pageSize int) string {
+	return tenantKey(ctx, fmt.Sprintf("%s:{%s}:%d:%d", LeaderboardCacheKey, tenantBoard(ctx, board), pageSize, page))
+}
+
+
+func leaderboardIndexKey(ctx context.Context, board string) string {
+	return tenantKey(ctx, fmt.Sprintf("%s:{%s}:pages", LeaderboardCacheKey, tenantBoard(ctx, board)))
+}
+
+// tenantBoard names board of the tenant ctx acts for.
+func tenantBoard(ctx context.Context, board string) string {
+	return tenant.ID(ctx) + ":" + board
+}
+
+// pageRange encodes the rating span of a cached page as "bottom:top". Empty
+// pages have no span and are only dropped by a full invalidation.
+func pageRange(resp *models.LeaderboardResponse) string {
+	if len(resp.Entries) == 0 {
+		return ""
 	}
+	top := resp.Entries[0].Rating
+	bottom := resp.Entries[len(resp.Entries)-1].Rating
+	return fmt.Sprintf("%d:%d", bottom, top)
+}
+
 
-	return rank, nil
+func pageOverlaps(span string, minRating, maxRating int32) bool {
+	bottomStr, topStr, ok := strings.Cut(span, ":")
+	if !ok {
+		return false
+	}
+	bottom, err1 := strconv.ParseInt(bottomStr, 10, 32)
+	top, err2 := strconv.ParseInt(topStr, 10, 32)
+	if err1 != nil || err2 != nil {
+		return true
+	}
+	return int32(bottom) <= maxRating && int32(top) >= minRating
 }
 
 
-func (cm *CacheManager) InvalidateRank(ctx context.Context, userID string) error {
-	key := fmt.Sprintf("%s%s", RankCacheKeyPrefix, userID)
-	return cm.client.Del(ctx, key).Err()
+func (cm *CacheManager) SetLeaderboardPage(ctx context.Context, board string, page, pageSize int, resp *models.LeaderboardResponse, delta time.Duration) error {
+	key := cm.key(leaderboardPageKey(ctx, board, page, pageSize))
+	indexKey := cm.key(leaderboardIndexKey(ctx, board))
+
+	data, err := encodePage(resp, NewFreshness(delta, CacheLeaderboardTTL))
+	if err != nil {
+		return err
+	}
+
+	pipe := cm.client.TxPipeline()
+	pipe.Set(ctx, key, data, storedTTL(CacheLeaderboardTTL))
+	pipe.HSet(ctx, indexKey, key, pageRange(resp))
+	pipe.Expire(ctx, indexKey, storedTTL(CacheLeaderboardTTL))
+	_, err = pipe.Exec(ctx)
+	return err
 }
 
 
-func (cm *CacheManager) InvalidateLeaderboard(ctx context.Context) error {
-	return cm.client.Del(ctx, LeaderboardCacheKey).Err()
+func (cm *CacheManager) GetLeaderboardPage(ctx context.Context, board string, page, pageSize int) (*models.LeaderboardResponse, Freshness, error) {
+	key := cm.key(leaderboardPageKey(ctx, board, page, pageSize))
+
+	val, err := cm.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, Freshness{}, nil
+	}
+	if err != nil {
+		return nil, Freshness{}, err
+	}
+
+	return decodePage(val)
+}
+
+// InvalidateLeaderboardRange drops only the cached pages of board whose rating
+// span overlaps [minRating, maxRating]. A rating change moves rows only inside
+// that window, so pages entirely above or below it are still correct.
+func (cm *CacheManager) InvalidateLeaderboardRange(ctx context.Context, board string, minRating, maxRating int32) error {
+	indexKey := cm.key(leaderboardIndexKey(ctx, board))
+
+	pages, err := cm.client.HGetAll(ctx, indexKey).Result()
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	for key, span := range pages {
+		if pageOverlaps(span, minRating, maxRating) {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	pipe := cm.client.TxPipeline()
+	pipe.Del(ctx, stale...)
+	pipe.HDel(ctx, indexKey, stale...)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+
+func (cm *CacheManager) InvalidateLeaderboard(ctx context.Context, board string) error {
+	indexKey := cm.key(leaderboardIndexKey(ctx, board))
+
+	keys, err := cm.client.HKeys(ctx, indexKey).Result()
+	if err != nil {
+		return err
+	}
+
+	return cm.client.Del(ctx, append(keys, indexKey)...).Err()
+}
+
+
+// releaseLockScript deletes the lock only if it still holds our token, so an
+// expired lock taken over by another instance is never released by us.
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+
+func (cm *CacheManager) AcquireLock(ctx context.Context, name string, ttl time.Duration) (func(context.Context) error, bool, error) {
+	key := cm.key(lockKey(ctx, name))
+	token := uuid.NewString()
+
+	acquired, err := cm.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !acquired {
+		return nil, false, err
+	}
+
+	release := func(ctx context.Context) error {
+		return releaseLockScript.Run(ctx, cm.client, []string{key}, token).Err()
+	}
+	return release, true, nil
+}
+
+
+func (cm *CacheManager) Ping(ctx context.Context) error {
+	return cm.client.Ping(ctx).Err()
 }
 
 
@@ -128,6 +466,65 @@ func (cm *CacheManager) Close() error {
 }
 
 
+// FlushBatchSize is how many keys Flush scans for and deletes per round trip.
+const FlushBatchSize = 1000
+
+// Flush deletes every key in our namespace, across all schema versions, and
+// leaves the rest of the database alone. In cluster mode every master holds
+// part of the keyspace, so each one is scanned.
 func (cm *CacheManager) Flush(ctx context.Context) error {
-	return cm.client.FlushDB(ctx).Err()
+	if cluster, ok := cm.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return cm.flushNode(ctx, node)
+		})
+	}
+	return cm.flushNode(ctx, cm.client)
+}
+
+// flushNode unlinks keys one per command so no call spans cluster slots. The
+// pattern cannot pin the version segment, so keys of namespaces that extend
+// ours are dropped from each batch before deleting.
+func (cm *CacheManager) flushNode(ctx context.Context, node redis.UniversalClient) error {
+	match := globEscape(cm.namespace) + ":v*"
+
+	var cursor uint64
+	for {
+		keys, next, err := node.Scan(ctx, cursor, match, FlushBatchSize).Result()
+		if err != nil {
+			return err
+		}
+
+		ours := keys[:0]
+		for _, key := range keys {
+			if inNamespace(cm.namespace, key) {
+				ours = append(ours, key)
+			}
+		}
+		if len(ours) > 0 {
+			pipe := node.Pipeline()
+			for _, key := range ours {
+				pipe.Unlink(ctx, key)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// globEscape quotes the characters SCAN MATCH treats as patterns.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
@@ -0,0 +1,49 @@
+package cache
+
+import "testing"
+
+// Flush must take every schema version of its own namespace and nothing of a
+// namespace that shares its prefix.
+func TestInNamespace(t *testing.T) {
+	cases := []struct {
+		key  string
+		want bool
+	}{
+		{"leaderboard:prod:v3:t:default:user:u1", true},
+		{"leaderboard:prod:v1:t:default:rank:1:u1", true},
+		{"leaderboard:prod:v12:invalidate", true},
+		{"leaderboard:prod:eu:v3:t:default:user:u1", false},
+		{"leaderboard:prod:vip:t:default:user:u1", false},
+		{"leaderboard:prod:v:t:default:user:u1", false},
+		{"leaderboard:prod:v3", false},
+		{"leaderboard:production:v3:t:default:user:u1", false},
+		{"leaderboard:staging:v3:t:default:user:u1", false},
+	}
+
+	for _, tc := range cases {
+		if got := inNamespace("leaderboard:prod", tc.key); got != tc.want {
+			t.Errorf("inNamespace(%q) = %v, want %v", tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	cases := []struct {
+		namespace string
+		ok        bool
+	}{
+		{"leaderboard:prod", true},
+		{"leaderboard:prod:eu", true},
+		{"leaderboard:vip", true},
+		{"", false},
+		{"leaderboard:{prod}", false},
+		{"leaderboard:v2", false},
+		{"v1:leaderboard", false},
+	}
+
+	for _, tc := range cases {
+		if err := validateNamespace(tc.namespace); (err == nil) != tc.ok {
+			t.Errorf("validateNamespace(%q) = %v, want ok %v", tc.namespace, err, tc.ok)
+		}
+	}
+}
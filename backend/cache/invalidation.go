@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	InvalidationChannel = "cache:invalidations"
+)
+
+
+type InvalidationKind string
+
+const (
+	InvalidateUserKind             InvalidationKind = "user"
+	InvalidateRankEpochKind        InvalidationKind = "rank_epoch"
+	InvalidateLeaderboardRangeKind InvalidationKind = "leaderboard_range"
+	InvalidateLeaderboardKind      InvalidationKind = "leaderboard"
+	InvalidateAllKind              InvalidationKind = "all"
+)
+
+// Invalidation tells other instances to drop entries from their in-process
+// tier. Origin identifies the sender so it can skip its own messages; Tenant
+// is the tenant whose entries are dropped.
+type Invalidation struct {
+	Origin    string           `json:"origin"`
+	Tenant    string           `json:"tenant,omitempty"`
+	Kind      InvalidationKind `json:"kind"`
+	UserID    string           `json:"user_id,omitempty"`
+	Board     string           `json:"board,omitempty"`
+	MinRating int32            `json:"min_rating,omitempty"`
+	MaxRating int32            `json:"max_rating,omitempty"`
+	Epoch     int64            `json:"epoch,omitempty"`
+}
+
+
+type InvalidationBus interface {
+	PublishInvalidation(ctx context.Context, msg Invalidation) error
+	SubscribeInvalidations(ctx context.Context, handler func(Invalidation)) (stop func() error, err error)
+}
+
+
+func (cm *CacheManager) PublishInvalidation(ctx context.Context, msg Invalidation) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal invalidation: %w", err)
+	}
+	return cm.client.Publish(ctx, cm.key(InvalidationChannel), data).Err()
+}
+
+// SubscribeInvalidations delivers messages to handler until stop is called.
+// The redis client resubscribes on its own after a dropped connection.
+func (cm *CacheManager) SubscribeInvalidations(ctx context.Context, handler func(Invalidation)) (func() error, error) {
+	pubsub := cm.client.Subscribe(ctx, cm.key(InvalidationChannel))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to invalidations: %w", err)
+	}
+
+	go func() {
+		for m := range pubsub.Channel() {
+			var msg Invalidation
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				continue
+			}
+			handler(msg)
+		}
+	}()
+
+	return pubsub.Close, nil
+}
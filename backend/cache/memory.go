@@ -0,0 +1,359 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"leaderboard-system/models"
+	"leaderboard-system/tenant"
+)
+
+const (
+	DefaultMemoryMaxEntries = 100000
+)
+
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	board     string
+}
+
+// MemoryCache is an in-process Cache bounded by entry count. Entries expire
+// after the same TTLs CacheManager uses, and the least recently used entry is
+// evicted once the cache is full; all tenants share the bound. Values are
+// stored JSON-encoded so callers never share memory with the cache, just as
+// with Redis.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxTTL     time.Duration
+	order      *list.List
+	items      map[string]*list.Element
+	pages      map[string]map[string]string
+	locks      map[string]lockHold
+	lockSeq    uint64
+	rankEpochs map[string]int64
+	now        func() time.Time
+}
+
+
+type lockHold struct {
+	token     uint64
+	expiresAt time.Time
+}
+
+
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return NewMemoryCacheWithTTL(maxEntries, 0)
+}
+
+// NewMemoryCacheWithTTL caps every entry's lifetime at maxTTL, which lets a
+// MemoryCache serve as a short-lived L1 in front of a shared cache. A zero
+// maxTTL keeps the regular per-kind TTLs.
+func NewMemoryCacheWithTTL(maxEntries int, maxTTL time.Duration) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMemoryMaxEntries
+	}
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		maxTTL:     maxTTL,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		pages:      make(map[string]map[string]string),
+		locks:      make(map[string]lockHold),
+		rankEpochs: make(map[string]int64),
+		now:        time.Now,
+	}
+}
+
+
+func (mc *MemoryCache) get(key string) ([]byte, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	el, ok := mc.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*memoryEntry)
+	if mc.now().After(entry.expiresAt) {
+		mc.removeElement(el)
+		return nil, false
+	}
+	mc.order.MoveToFront(el)
+	return entry.value, true
+}
+
+
+func (mc *MemoryCache) set(key string, value []byte, ttl time.Duration, board string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.maxTTL > 0 && ttl > mc.maxTTL {
+		ttl = mc.maxTTL
+	}
+	expiresAt := mc.now().Add(ttl)
+	if el, ok := mc.items[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		entry.board = board
+		mc.order.MoveToFront(el)
+		return
+	}
+
+	el := mc.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt, board: board})
+	mc.items[key] = el
+
+	for mc.order.Len() > mc.maxEntries {
+		mc.removeElement(mc.order.Back())
+	}
+}
+
+
+func (mc *MemoryCache) delete(keys ...string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := mc.items[key]; ok {
+			mc.removeElement(el)
+		}
+	}
+}
+
+// removeElement must be called with mu held.
+func (mc *MemoryCache) removeElement(el *list.Element) {
+	entry := el.Value.(*memoryEntry)
+	mc.order.Remove(el)
+	delete(mc.items, entry.key)
+
+	if entry.board != "" {
+		if index, ok := mc.pages[entry.board]; ok {
+			delete(index, entry.key)
+			if len(index) == 0 {
+				delete(mc.pages, entry.board)
+			}
+		}
+	}
+}
+
+
+func (mc *MemoryCache) SetUser(ctx context.Context, user *models.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user: %w", err)
+	}
+
+	mc.set(userKey(ctx, user.ID), data, CacheUserTTL, "")
+	return nil
+}
+
+
+func (mc *MemoryCache) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	val, ok := mc.get(userKey(ctx, userID))
+	if !ok {
+		return nil, nil
+	}
+
+	var user models.User
+	if err := json.Unmarshal(val, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+
+func (mc *MemoryCache) InvalidateUser(ctx context.Context, userID string) error {
+	mc.delete(userKey(ctx, userID))
+	return nil
+}
+
+
+func (mc *MemoryCache) RankEpoch(ctx context.Context) (int64, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return mc.epochOf(ctx), nil
+}
+
+
+func (mc *MemoryCache) BumpRankEpoch(ctx context.Context) (int64, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	epoch := mc.epochOf(ctx) + 1
+	mc.rankEpochs[tenant.ID(ctx)] = epoch
+	return epoch, nil
+}
+
+// epochOf returns the rank epoch of the tenant ctx acts for, starting it on
+// first use. Must be called with mu held.
+func (mc *MemoryCache) epochOf(ctx context.Context) int64 {
+	id := tenant.ID(ctx)
+	epoch, ok := mc.rankEpochs[id]
+	if !ok {
+		epoch = initialRankEpoch()
+		mc.rankEpochs[id] = epoch
+	}
+	return epoch
+}
+
+
+func (mc *MemoryCache) SetRank(ctx context.Context, epoch int64, userID string, rank int64, delta time.Duration) error {
+	return mc.putRank(ctx, epoch, userID, rank, NewFreshness(delta, CacheRankTTL))
+}
+
+// putRank stores a rank with freshness decided elsewhere, which is how a
+// TieredCache copies an L2 entry into L1 without extending its lifetime.
+func (mc *MemoryCache) putRank(ctx context.Context, epoch int64, userID string, rank int64, fresh Freshness) error {
+	data, err := encodeRank(rank, fresh)
+	if err != nil {
+		return err
+	}
+
+	mc.set(rankKey(ctx, epoch, userID), data, storedTTL(CacheRankTTL), "")
+	return nil
+}
+
+
+func (mc *MemoryCache) GetRank(ctx context.Context, epoch int64, userID string) (int64, Freshness, error) {
+	val, ok := mc.get(rankKey(ctx, epoch, userID))
+	if !ok {
+		return 0, Freshness{}, nil
+	}
+
+	return decodeRank(val)
+}
+
+
+func (mc *MemoryCache) SetLeaderboardPage(ctx context.Context, board string, page, pageSize int, resp *models.LeaderboardResponse, delta time.Duration) error {
+	return mc.putPage(ctx, board, page, pageSize, resp, NewFreshness(delta, CacheLeaderboardTTL))
+}
+
+// putPage indexes pages by the tenant's board, so invalidating one tenant's
+// board leaves the others alone.
+func (mc *MemoryCache) putPage(ctx context.Context, board string, page, pageSize int, resp *models.LeaderboardResponse, fresh Freshness) error {
+	key := leaderboardPageKey(ctx, board, page, pageSize)
+	board = tenantBoard(ctx, board)
+
+	data, err := encodePage(resp, fresh)
+	if err != nil {
+		return err
+	}
+
+	mc.set(key, data, storedTTL(CacheLeaderboardTTL), board)
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if _, ok := mc.items[key]; !ok {
+		return nil
+	}
+	index, ok := mc.pages[board]
+	if !ok {
+		index = make(map[string]string)
+		mc.pages[board] = index
+	}
+	index[key] = pageRange(resp)
+	return nil
+}
+
+
+func (mc *MemoryCache) GetLeaderboardPage(ctx context.Context, board string, page, pageSize int) (*models.LeaderboardResponse, Freshness, error) {
+	val, ok := mc.get(leaderboardPageKey(ctx, board, page, pageSize))
+	if !ok {
+		return nil, Freshness{}, nil
+	}
+
+	return decodePage(val)
+}
+
+
+func (mc *MemoryCache) InvalidateLeaderboardRange(ctx context.Context, board string, minRating, maxRating int32) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	for key, span := range mc.pages[tenantBoard(ctx, board)] {
+		if !pageOverlaps(span, minRating, maxRating) {
+			continue
+		}
+		if el, ok := mc.items[key]; ok {
+			mc.removeElement(el)
+		}
+	}
+	return nil
+}
+
+
+func (mc *MemoryCache) InvalidateLeaderboard(ctx context.Context, board string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	board = tenantBoard(ctx, board)
+	for key := range mc.pages[board] {
+		if el, ok := mc.items[key]; ok {
+			mc.removeElement(el)
+		}
+	}
+	delete(mc.pages, board)
+	return nil
+}
+
+
+// AcquireLock only coordinates callers within this process, which is all a
+// single-node setup needs. Held locks are removed on release or expiry.
+func (mc *MemoryCache) AcquireLock(ctx context.Context, name string, ttl time.Duration) (func(context.Context) error, bool, error) {
+	name = lockKey(ctx, name)
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	now := mc.now()
+	if hold, ok := mc.locks[name]; ok && now.Before(hold.expiresAt) {
+		return nil, false, nil
+	}
+
+	mc.lockSeq++
+	token := mc.lockSeq
+	mc.locks[name] = lockHold{token: token, expiresAt: now.Add(ttl)}
+
+	release := func(ctx context.Context) error {
+		mc.mu.Lock()
+		defer mc.mu.Unlock()
+
+		if hold, ok := mc.locks[name]; ok && hold.token == token {
+			delete(mc.locks, name)
+		}
+		return nil
+	}
+	return release, true, nil
+}
+
+
+func (mc *MemoryCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+
+func (mc *MemoryCache) Close() error {
+	return nil
+}
+
+
+func (mc *MemoryCache) Flush(ctx context.Context) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.order.Init()
+	mc.items = make(map[string]*list.Element)
+	mc.pages = make(map[string]map[string]string)
+	return nil
+}
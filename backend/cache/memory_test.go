@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"leaderboard-system/models"
+	"leaderboard-system/tenant"
+)
+
+// page builds a leaderboard page holding one entry per rating, top first.
+func page(ratings ...int32) *models.LeaderboardResponse {
+	resp := &models.LeaderboardResponse{Entries: []models.LeaderboardEntry{}}
+	for _, r := range ratings {
+		resp.Entries = append(resp.Entries, models.LeaderboardEntry{Rating: r})
+	}
+	return resp
+}
+
+// A rating change drops only the pages whose span it overlaps, bounds
+// included, and only on the tenant's own board.
+func TestInvalidateLeaderboardRange(t *testing.T) {
+	cases := []struct {
+		name     string
+		min, max int32
+		kept     []int
+	}{
+		{name: "inside one page", min: 1600, max: 1800, kept: []int{1, 3, 4}},
+		{name: "touching two pages' bounds", min: 1950, max: 2000, kept: []int{3, 4}},
+		{name: "in a gap between pages", min: 1960, max: 1990, kept: []int{1, 2, 3, 4}},
+		{name: "below every page", min: 100, max: 500, kept: []int{1, 2, 3, 4}},
+		{name: "spanning the board", min: 0, max: 5000, kept: []int{4}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			other := tenant.WithID(ctx, "other")
+			mc := NewMemoryCache(100)
+			pages := map[int]*models.LeaderboardResponse{
+				1: page(2500, 2000),
+				2: page(1950, 1500),
+				3: page(1499, 1000),
+				4: page(), // past the end: no span
+			}
+			for n, resp := range pages {
+				for _, c := range []context.Context{ctx, other} {
+					if err := mc.SetLeaderboardPage(c, "global", n, 2, resp, 0); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			if err := mc.InvalidateLeaderboardRange(ctx, "global", tc.min, tc.max); err != nil {
+				t.Fatal(err)
+			}
+
+			kept := make(map[int]bool)
+			for _, n := range tc.kept {
+				kept[n] = true
+			}
+			for n := range pages {
+				got, _, err := mc.GetLeaderboardPage(ctx, "global", n, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if (got != nil) != kept[n] {
+					t.Errorf("page %d cached = %v, want %v", n, got != nil, kept[n])
+				}
+				if got, _, _ := mc.GetLeaderboardPage(other, "global", n, 2); got == nil {
+					t.Errorf("other tenant's page %d was dropped", n)
+				}
+			}
+		})
+	}
+}
+
+// The cache holds at most maxEntries, evicting the least recently used, and
+// an evicted page leaves its board's index.
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	mc := NewMemoryCache(3)
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := mc.SetUser(ctx, &models.User{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Reading a makes b the least recently used.
+	if u, _ := mc.GetUser(ctx, "a"); u == nil {
+		t.Fatal("a missing before any eviction")
+	}
+	if err := mc.SetUser(ctx, &models.User{ID: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if u, _ := mc.GetUser(ctx, id); (u != nil) != want {
+			t.Errorf("user %s cached = %v, want %v", id, u != nil, want)
+		}
+	}
+
+	if err := mc.SetLeaderboardPage(ctx, "global", 1, 10, page(2000, 1000), 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"e", "f", "g"} {
+		if err := mc.SetUser(ctx, &models.User{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, _, _ := mc.GetLeaderboardPage(ctx, "global", 1, 10); got != nil {
+		t.Fatal("page survived three newer entries")
+	}
+	if n := len(mc.pages); n != 0 {
+		t.Fatalf("%d board indexes left after their only page was evicted", n)
+	}
+}
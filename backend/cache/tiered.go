@@ -0,0 +1,360 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"leaderboard-system/models"
+	"leaderboard-system/tenant"
+)
+
+const (
+	DefaultL1MaxEntries = 10000
+	DefaultL1TTL        = 5 * time.Second
+)
+
+
+type TierStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+
+type Stats struct {
+	L1 TierStats `json:"l1"`
+	L2 TierStats `json:"l2"`
+}
+
+
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// TieredCache keeps a small in-process L1 in front of a shared L2. Writes and
+// invalidations go to both tiers, and invalidations are broadcast so other
+// replicas drop their L1 copies too. Broadcasts are best effort, so the L1
+// TTL bounds how long a replica that missed one can serve an old entry.
+type TieredCache struct {
+	l1     *MemoryCache
+	l2     Cache
+	bus    InvalidationBus
+	origin string
+	l1TTL  time.Duration
+	stop   func() error
+
+	epochMu sync.Mutex
+	epochs  map[string]*tierEpoch
+
+	l1Hits, l1Misses uint64
+	l2Hits, l2Misses uint64
+}
+
+
+// tierEpoch is a tenant's rank epoch as last fetched from L2 or heard of over
+// the bus.
+type tierEpoch struct {
+	epoch   int64
+	fetched time.Time
+}
+
+
+func NewTieredCache(l2 Cache, bus InvalidationBus, maxEntries int, ttl time.Duration) (*TieredCache, error) {
+	if ttl <= 0 {
+		ttl = DefaultL1TTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultL1MaxEntries
+	}
+
+	tc := &TieredCache{
+		l1:     NewMemoryCacheWithTTL(maxEntries, ttl),
+		l2:     l2,
+		bus:    bus,
+		origin: uuid.NewString(),
+		l1TTL:  ttl,
+		epochs: make(map[string]*tierEpoch),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stop, err := bus.SubscribeInvalidations(ctx, tc.apply)
+	if err != nil {
+		return nil, err
+	}
+	tc.stop = stop
+
+	return tc, nil
+}
+
+
+func (tc *TieredCache) apply(msg Invalidation) {
+	if msg.Origin == tc.origin {
+		return
+	}
+
+	ctx := tenant.WithID(context.Background(), msg.Tenant)
+	switch msg.Kind {
+	case InvalidateUserKind:
+		tc.l1.InvalidateUser(ctx, msg.UserID)
+	case InvalidateRankEpochKind:
+		tc.observeEpoch(ctx, msg.Epoch)
+	case InvalidateLeaderboardRangeKind:
+		tc.l1.InvalidateLeaderboardRange(ctx, msg.Board, msg.MinRating, msg.MaxRating)
+	case InvalidateLeaderboardKind:
+		tc.l1.InvalidateLeaderboard(ctx, msg.Board)
+	case InvalidateAllKind:
+		tc.l1.Flush(ctx)
+		tc.forgetEpochs()
+	}
+}
+
+
+func (tc *TieredCache) publish(ctx context.Context, msg Invalidation) error {
+	msg.Origin = tc.origin
+	msg.Tenant = tenant.ID(ctx)
+	return tc.bus.PublishInvalidation(ctx, msg)
+}
+
+
+func (tc *TieredCache) hit(l1 bool) {
+	if l1 {
+		atomic.AddUint64(&tc.l1Hits, 1)
+	} else {
+		atomic.AddUint64(&tc.l2Hits, 1)
+	}
+}
+
+
+func (tc *TieredCache) miss(l1 bool) {
+	if l1 {
+		atomic.AddUint64(&tc.l1Misses, 1)
+	} else {
+		atomic.AddUint64(&tc.l2Misses, 1)
+	}
+}
+
+
+func (tc *TieredCache) Stats() Stats {
+	return Stats{
+		L1: TierStats{Hits: atomic.LoadUint64(&tc.l1Hits), Misses: atomic.LoadUint64(&tc.l1Misses)},
+		L2: TierStats{Hits: atomic.LoadUint64(&tc.l2Hits), Misses: atomic.LoadUint64(&tc.l2Misses)},
+	}
+}
+
+
+// BreakerStats reports the breaker in front of L2, if there is one.
+func (tc *TieredCache) BreakerStats() (BreakerStats, bool) {
+	if reporter, ok := tc.l2.(BreakerReporter); ok {
+		return reporter.BreakerStats()
+	}
+	return BreakerStats{}, false
+}
+
+
+func (tc *TieredCache) SetUser(ctx context.Context, user *models.User) error {
+	if err := tc.l2.SetUser(ctx, user); err != nil {
+		return err
+	}
+	return tc.l1.SetUser(ctx, user)
+}
+
+
+func (tc *TieredCache) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	if user, _ := tc.l1.GetUser(ctx, userID); user != nil {
+		tc.hit(true)
+		return user, nil
+	}
+	tc.miss(true)
+
+	user, err := tc.l2.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		tc.miss(false)
+		return nil, nil
+	}
+	tc.hit(false)
+
+	tc.l1.SetUser(ctx, user)
+	return user, nil
+}
+
+
+func (tc *TieredCache) InvalidateUser(ctx context.Context, userID string) error {
+	tc.l1.InvalidateUser(ctx, userID)
+	err := tc.l2.InvalidateUser(ctx, userID)
+	return errors.Join(err, tc.publish(ctx, Invalidation{Kind: InvalidateUserKind, UserID: userID}))
+}
+
+
+func (tc *TieredCache) observeEpoch(ctx context.Context, epoch int64) {
+	tc.epochMu.Lock()
+	defer tc.epochMu.Unlock()
+
+	id := tenant.ID(ctx)
+	e, ok := tc.epochs[id]
+	if !ok {
+		e = &tierEpoch{}
+		tc.epochs[id] = e
+	}
+	if epoch >= e.epoch {
+		e.epoch = epoch
+		e.fetched = time.Now()
+	}
+}
+
+// forgetEpochs makes every tenant's next RankEpoch read L2 again.
+func (tc *TieredCache) forgetEpochs() {
+	tc.epochMu.Lock()
+	defer tc.epochMu.Unlock()
+
+	for _, e := range tc.epochs {
+		e.fetched = time.Time{}
+	}
+}
+
+// RankEpoch serves the epoch from memory while it is younger than the L1 TTL;
+// bumps from other replicas arrive over the bus in the meantime.
+func (tc *TieredCache) RankEpoch(ctx context.Context) (int64, error) {
+	tc.epochMu.Lock()
+	if e, ok := tc.epochs[tenant.ID(ctx)]; ok && !e.fetched.IsZero() && time.Since(e.fetched) < tc.l1TTL {
+		epoch := e.epoch
+		tc.epochMu.Unlock()
+		tc.hit(true)
+		return epoch, nil
+	}
+	tc.epochMu.Unlock()
+	tc.miss(true)
+
+	epoch, err := tc.l2.RankEpoch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tc.hit(false)
+
+	tc.observeEpoch(ctx, epoch)
+	return epoch, nil
+}
+
+
+func (tc *TieredCache) BumpRankEpoch(ctx context.Context) (int64, error) {
+	epoch, err := tc.l2.BumpRankEpoch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tc.observeEpoch(ctx, epoch)
+
+	return epoch, tc.publish(ctx, Invalidation{Kind: InvalidateRankEpochKind, Epoch: epoch})
+}
+
+
+func (tc *TieredCache) SetRank(ctx context.Context, epoch int64, userID string, rank int64, delta time.Duration) error {
+	if err := tc.l2.SetRank(ctx, epoch, userID, rank, delta); err != nil {
+		return err
+	}
+	return tc.l1.SetRank(ctx, epoch, userID, rank, delta)
+}
+
+
+func (tc *TieredCache) GetRank(ctx context.Context, epoch int64, userID string) (int64, Freshness, error) {
+	if rank, fresh, _ := tc.l1.GetRank(ctx, epoch, userID); rank > 0 {
+		tc.hit(true)
+		return rank, fresh, nil
+	}
+	tc.miss(true)
+
+	rank, fresh, err := tc.l2.GetRank(ctx, epoch, userID)
+	if err != nil {
+		return 0, Freshness{}, err
+	}
+	if rank == 0 {
+		tc.miss(false)
+		return 0, Freshness{}, nil
+	}
+	tc.hit(false)
+
+	tc.l1.putRank(ctx, epoch, userID, rank, fresh)
+	return rank, fresh, nil
+}
+
+
+func (tc *TieredCache) SetLeaderboardPage(ctx context.Context, board string, page, pageSize int, resp *models.LeaderboardResponse, delta time.Duration) error {
+	if err := tc.l2.SetLeaderboardPage(ctx, board, page, pageSize, resp, delta); err != nil {
+		return err
+	}
+	return tc.l1.SetLeaderboardPage(ctx, board, page, pageSize, resp, delta)
+}
+
+
+func (tc *TieredCache) GetLeaderboardPage(ctx context.Context, board string, page, pageSize int) (*models.LeaderboardResponse, Freshness, error) {
+	if resp, fresh, _ := tc.l1.GetLeaderboardPage(ctx, board, page, pageSize); resp != nil {
+		tc.hit(true)
+		return resp, fresh, nil
+	}
+	tc.miss(true)
+
+	resp, fresh, err := tc.l2.GetLeaderboardPage(ctx, board, page, pageSize)
+	if err != nil {
+		return nil, Freshness{}, err
+	}
+	if resp == nil {
+		tc.miss(false)
+		return nil, Freshness{}, nil
+	}
+	tc.hit(false)
+
+	tc.l1.putPage(ctx, board, page, pageSize, resp, fresh)
+	return resp, fresh, nil
+}
+
+
+func (tc *TieredCache) InvalidateLeaderboardRange(ctx context.Context, board string, minRating, maxRating int32) error {
+	tc.l1.InvalidateLeaderboardRange(ctx, board, minRating, maxRating)
+	err := tc.l2.InvalidateLeaderboardRange(ctx, board, minRating, maxRating)
+	return errors.Join(err, tc.publish(ctx, Invalidation{
+		Kind:      InvalidateLeaderboardRangeKind,
+		Board:     board,
+		MinRating: minRating,
+		MaxRating: maxRating,
+	}))
+}
+
+
+func (tc *TieredCache) InvalidateLeaderboard(ctx context.Context, board string) error {
+	tc.l1.InvalidateLeaderboard(ctx, board)
+	err := tc.l2.InvalidateLeaderboard(ctx, board)
+	return errors.Join(err, tc.publish(ctx, Invalidation{Kind: InvalidateLeaderboardKind, Board: board}))
+}
+
+
+func (tc *TieredCache) AcquireLock(ctx context.Context, name string, ttl time.Duration) (func(context.Context) error, bool, error) {
+	return tc.l2.AcquireLock(ctx, name, ttl)
+}
+
+
+func (tc *TieredCache) Ping(ctx context.Context) error {
+	return tc.l2.Ping(ctx)
+}
+
+
+func (tc *TieredCache) Close() error {
+	var stopErr error
+	if tc.stop != nil {
+		stopErr = tc.stop()
+	}
+	return errors.Join(stopErr, tc.l2.Close())
+}
+
+
+func (tc *TieredCache) Flush(ctx context.Context) error {
+	tc.l1.Flush(ctx)
+	tc.forgetEpochs()
+	err := tc.l2.Flush(ctx)
+	return errors.Join(err, tc.publish(ctx, Invalidation{Kind: InvalidateAllKind}))
+}
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"leaderboard-system/models"
+)
+
+const (
+	// XFetchBeta scales how eagerly entries refresh before they expire.
+	// Values above 1 refresh earlier, below 1 later.
+	XFetchBeta = 1.0
+
+	// StaleFactor is how long, as a multiple of the TTL, an entry stays
+	// readable. Past its TTL it is served stale while one caller refreshes it.
+	StaleFactor = 2
+)
+
+// Freshness describes when a cached value expires and how long it took to
+// compute, which is what XFetch needs to decide on an early refresh.
+type Freshness struct {
+	Delta     time.Duration
+	ExpiresAt time.Time
+}
+
+
+func NewFreshness(delta, ttl time.Duration) Freshness {
+	return Freshness{Delta: delta, ExpiresAt: time.Now().Add(ttl)}
+}
+
+
+func (f Freshness) Stale(now time.Time) bool {
+	return !f.ExpiresAt.IsZero() && !now.Before(f.ExpiresAt)
+}
+
+// ShouldRefresh implements XFetch: a value is refreshed early with a
+// probability that rises as expiry nears and with how slow it is to compute,
+// so one reader of a hot key recomputes it before the rest would all miss.
+func (f Freshness) ShouldRefresh(now time.Time) bool {
+	if f.ExpiresAt.IsZero() {
+		return false
+	}
+	if f.Stale(now) {
+		return true
+	}
+
+	r := rand.Float64()
+	if r == 0 {
+		return true
+	}
+	gap := -float64(f.Delta) * XFetchBeta * math.Log(r)
+	return float64(f.ExpiresAt.Sub(now)) <= gap
+}
+
+
+type entry struct {
+	Value     json.RawMessage `json:"v"`
+	Delta     int64           `json:"d"`
+	ExpiresAt int64           `json:"e"`
+}
+
+
+func encodeEntry(value []byte, fresh Freshness) ([]byte, error) {
+	return json.Marshal(entry{
+		Value:     value,
+		Delta:     int64(fresh.Delta),
+		ExpiresAt: fresh.ExpiresAt.UnixMilli(),
+	})
+}
+
+
+func decodeEntry(data []byte) ([]byte, Freshness, error) {
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, Freshness{}, err
+	}
+	return e.Value, Freshness{
+		Delta:     time.Duration(e.Delta),
+		ExpiresAt: time.UnixMilli(e.ExpiresAt),
+	}, nil
+}
+
+
+func storedTTL(ttl time.Duration) time.Duration {
+	return ttl * StaleFactor
+}
+
+
+func encodeRank(rank int64, fresh Freshness) ([]byte, error) {
+	value, err := json.Marshal(rank)
+	if err != nil {
+		return nil, err
+	}
+	return encodeEntry(value, fresh)
+}
+
+
+func decodeRank(data []byte) (int64, Freshness, error) {
+	value, fresh, err := decodeEntry(data)
+	if err != nil {
+		return 0, Freshness{}, err
+	}
+
+	var rank int64
+	if err := json.Unmarshal(value, &rank); err != nil {
+		return 0, Freshness{}, err
+	}
+	return rank, fresh, nil
+}
+
+
+func encodePage(resp *models.LeaderboardResponse, fresh Freshness) ([]byte, error) {
+	value, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal leaderboard page: %w", err)
+	}
+	return encodeEntry(value, fresh)
+}
+
+
+func decodePage(data []byte) (*models.LeaderboardResponse, Freshness, error) {
+	value, fresh, err := decodeEntry(data)
+	if err != nil {
+		return nil, Freshness{}, err
+	}
+
+	var resp models.LeaderboardResponse
+	if err := json.Unmarshal(value, &resp); err != nil {
+		return nil, Freshness{}, err
+	}
+	return &resp, fresh, nil
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"leaderboard-system/cache"
+	"leaderboard-system/config"
+	"leaderboard-system/database"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+	"leaderboard-system/service"
+	"leaderboard-system/tenant"
+)
+
+// runCommand runs a one-off maintenance command instead of the server.
+func runCommand(cfg *config.Config, log *zap.Logger, name string, args []string) error {
+	switch name {
+	case "rebuild-cache":
+		return rebuildCache(cfg, log, args)
+	case "migrate":
+		return migrate(cfg, args)
+	case "import":
+		return importUsers(cfg, log, args)
+	case "seed":
+		return seedUsers(cfg, log, args)
+	default:
+		return fmt.Errorf("unknown command %q (available: rebuild-cache, migrate, import, seed)", name)
+	}
+}
+
+// migrate manages the schema: up applies pending migrations, down reverts the
+// latest ones, status lists them, and schema prints the full schema that
+// DATABASE_SCHEMA.sql is generated from.
+func migrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down [-steps N] | status | schema [-o FILE]")
+	}
+
+	if args[0] == "schema" {
+		fs := flag.NewFlagSet("migrate schema", flag.ContinueOnError)
+		out := fs.String("o", "", "write the schema to this file instead of stdout")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		schema, err := database.Schema()
+		if err != nil {
+			return err
+		}
+		if *out == "" {
+			_, err = fmt.Print(schema)
+			return err
+		}
+		return os.WriteFile(*out, []byte(schema), 0o644)
+	}
+
+	db, err := database.Open(&cfg.Database, logger.Silent)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(db)
+		if err != nil {
+			return err
+		}
+		for _, mig := range applied {
+			fmt.Printf("applied  %04d_%s\n", mig.Version, mig.Name)
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return nil
+
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := fs.Int("steps", 1, "number of migrations to revert")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		reverted, err := database.MigrateDown(db, *steps)
+		if err != nil {
+			return err
+		}
+		for _, mig := range reverted {
+			fmt.Printf("reverted %04d_%s\n", mig.Version, mig.Name)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("nothing to revert")
+		}
+		return nil
+
+	case "status":
+		statuses, err := database.MigrationStatuses(db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Modified {
+				state += " (modified since applied)"
+			}
+			fmt.Printf("%04d_%-32s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// rebuildCache rewrites every derived cache entry of one tenant from
+// Postgres. It stops cleanly on SIGINT or SIGTERM; whatever was rebuilt so far
+// stays cached.
+func rebuildCache(cfg *config.Config, log *zap.Logger, args []string) error {
+	fs := flag.NewFlagSet("rebuild-cache", flag.ContinueOnError)
+	tenantID := fs.String("tenant", tenant.Default, "tenant whose cache to rebuild")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !tenant.ValidID(*tenantID) {
+		return fmt.Errorf("invalid tenant %q", *tenantID)
+	}
+
+	ctx, stop := signal.NotifyContext(tenant.WithID(context.Background(), *tenantID), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.InitDB(&cfg.Database, logger.Silent)
+	if err != nil {
+		return err
+	}
+
+	replicas, err := database.OpenReplicas(db, &cfg.Database, logger.Silent)
+	if err != nil {
+		return err
+	}
+	defer replicas.Close()
+
+	cacheStore, err := cache.NewCache(&cfg.Cache, &cfg.Redis)
+	if err != nil {
+		return err
+	}
+	defer cacheStore.Close()
+
+	return newCacheWarmer(db, replicas, cacheStore, cfg, log).Rebuild(ctx)
+}
+
+
+func newCacheWarmer(db *gorm.DB, replicas *database.Replicas, cacheStore cache.Cache, cfg *config.Config, log *zap.Logger) *service.CacheWarmer {
+	userRepo := repository.NewUserRepository(db, replicas)
+	return service.NewCacheWarmer(newUserService(db, userRepo, replicas, cacheStore, cfg, log), service.WarmOptionsFromConfig(&cfg.Cache), log)
+}
+
+
+func newUserService(db *gorm.DB, userRepo *repository.UserRepository, replicas *database.Replicas, cacheStore cache.Cache, cfg *config.Config, log *zap.Logger) *service.UserService {
+	changeLogService := service.NewChangeLogService(repository.NewChangeLogRepository(db, replicas), userRepo, log)
+	userService := service.NewUserService(userRepo, changeLogService, cacheStore, log)
+	userService.SetReplicaLag(replicas.MaxLag())
+	if cfg.Ranks.Materialized {
+		userService.SetRankTable(repository.NewRankRepository(db, replicas))
+	}
+	return userService
+}
+
+// importUsers bulk loads users into one tenant from a CSV or NDJSON file, or
+// stdin with "-". Rejected rows are printed to stderr and make the command
+// fail once every valid row is loaded.
+func importUsers(cfg *config.Config, log *zap.Logger, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "csv or ndjson (default: from the file extension)")
+	batchSize := fs.Int("batch", service.DefaultImportBatchSize, "rows per COPY batch")
+	tenantID := fs.String("tenant", tenant.Default, "tenant to import into")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: import [-format csv|ndjson] [-batch N] [-tenant ID] FILE|-")
+	}
+	if !tenant.ValidID(*tenantID) {
+		return fmt.Errorf("invalid tenant %q", *tenantID)
+	}
+
+	path := fs.Arg(0)
+	if *format == "" {
+		detected, ok := service.DetectImportFormat(path)
+		if !ok {
+			return errors.New("cannot tell the format from the file name; pass -format csv or -format ndjson")
+		}
+		*format = detected
+	}
+
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	ctx, stop := signal.NotifyContext(tenant.WithID(context.Background(), *tenantID), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.InitDB(&cfg.Database, logger.Silent)
+	if err != nil {
+		return err
+	}
+
+	cacheStore, err := cache.NewCache(&cfg.Cache, &cfg.Redis)
+	if err != nil {
+		return err
+	}
+	defer cacheStore.Close()
+
+	userRepo := repository.NewUserRepository(db, nil)
+	importer := service.NewImportService(newUserService(db, userRepo, nil, cacheStore, cfg, log), userRepo, *batchSize, log)
+
+	report, err := importer.Import(ctx, in, *format)
+	importer.Wait()
+	return printImportReport(report, err)
+}
+
+// printImportReport prints what an import did and turns rejected rows into
+// the command's error.
+func printImportReport(report *models.ImportReport, err error) error {
+	if report != nil {
+		for _, e := range report.Errors {
+			fmt.Fprintf(os.Stderr, "line %d: %s\n", e.Line, e.Error)
+		}
+		if report.ErrorsTruncated {
+			fmt.Fprintf(os.Stderr, "... %d more rejected rows not listed\n", report.Rejected-int64(len(report.Errors)))
+		}
+		fmt.Printf("processed %d: inserted %d, updated %d, unchanged %d, rejected %d\n",
+			report.Processed, report.Inserted, report.Updated, report.Unchanged, report.Rejected)
+	}
+	if err != nil {
+		return err
+	}
+	if report.Rejected > 0 {
+		return fmt.Errorf("%d rows rejected", report.Rejected)
+	}
+	return nil
+}
+
+// seedUsers loads synthetic users into one tenant for development and load
+// testing. The same flags, -seed included, always generate the same users.
+func seedUsers(cfg *config.Config, log *zap.Logger, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	users := fs.Int("users", service.DefaultSeedUsers, "number of users to generate")
+	dist := fs.String("dist", service.SeedUniform, "rating distribution: uniform, normal or power-law")
+	seed := fs.Int64("seed", 1, "random seed; the same seed generates the same users")
+	ties := fs.Float64("ties", 0, "share of users, 0 to 1, that copy a recent user's rating")
+	names := fs.String("names", service.DefaultSeedNamePattern, "comma-separated username patterns, each with one integer verb")
+	mean := fs.Float64("mean", 0, "normal distribution mean (default: middle of the rating range)")
+	stddev := fs.Float64("stddev", 0, "normal distribution standard deviation (default: a sixth of the rating range)")
+	alpha := fs.Float64("alpha", service.DefaultSeedAlpha, "power-law exponent")
+	batchSize := fs.Int("batch", service.DefaultImportBatchSize, "rows per COPY batch")
+	tenantID := fs.String("tenant", tenant.Default, "tenant to seed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: seed [-users N] [-dist uniform|normal|power-law] [-seed N] [-ties F] [-names PATTERNS] [-tenant ID]")
+	}
+	if !tenant.ValidID(*tenantID) {
+		return fmt.Errorf("invalid tenant %q", *tenantID)
+	}
+
+	opts := service.SeedOptions{
+		Users:        *users,
+		Distribution: *dist,
+		RandomSeed:   *seed,
+		TieDensity:   *ties,
+		NamePatterns: strings.Split(*names, ","),
+		Mean:         *mean,
+		StdDev:       *stddev,
+		Alpha:        *alpha,
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(tenant.WithID(context.Background(), *tenantID), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.InitDB(&cfg.Database, logger.Silent)
+	if err != nil {
+		return err
+	}
+
+	cacheStore, err := cache.NewCache(&cfg.Cache, &cfg.Redis)
+	if err != nil {
+		return err
+	}
+	defer cacheStore.Close()
+
+	userRepo := repository.NewUserRepository(db, nil)
+	importer := service.NewImportService(newUserService(db, userRepo, nil, cacheStore, cfg, log), userRepo, *batchSize, log)
+
+	report, err := importer.Seed(ctx, opts)
+	importer.Wait()
+	return printImportReport(report, err)
+}
+
+// seedOnStart seeds the default tenant as SEED_* configures, unless it
+// already has users.
+func seedOnStart(db *gorm.DB, cacheStore cache.Cache, cfg *config.Config, log *zap.Logger) error {
+	ctx := tenant.WithID(context.Background(), tenant.Default)
+
+	userRepo := repository.NewUserRepository(db, nil)
+	count, err := userRepo.GetUserCount(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		log.Info("Skipping seed: default tenant already has users", zap.Int64("users", count))
+		return nil
+	}
+
+	importer := service.NewImportService(newUserService(db, userRepo, nil, cacheStore, cfg, log), userRepo, 0, log)
+	report, err := importer.Seed(ctx, service.SeedOptions{
+		Users:        cfg.Seed.Users,
+		Distribution: cfg.Seed.Distribution,
+		RandomSeed:   cfg.Seed.RandomSeed,
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("Database seeded with synthetic users", zap.Int64("inserted", report.Inserted))
+	return nil
+}
@@ -3,7 +3,10 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -17,24 +20,135 @@ type DatabaseConfig struct {
 	SSLMode  string
 
 	DatabaseURL string
+
+	// AutoMigrate applies pending migrations on startup. Without it startup
+	// fails until `migrate up` has been run.
+	AutoMigrate bool
+
+	// Pool limits for every connection pool, primary and replicas.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// StatementCacheSize is how many prepared statements each connection
+	// keeps; 0 disables preparing, as PgBouncer transaction pooling needs.
+	StatementCacheSize int
+
+	// StatementTimeout is how long Postgres lets one statement run before
+	// cancelling it. 0 means no limit.
+	StatementTimeout time.Duration
+
+	// RetryAttempts is how many times a statement or unit of work that fails
+	// transiently is tried, the first time included. Retries wait a random
+	// time up to RetryBaseDelay, doubling per retry up to RetryMaxDelay.
+	RetryAttempts  int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// ReplicaURLs are read replica DSNs. Reads go to a replica whose lag is
+	// at most ReplicaMaxLag, checked every ReplicaLagCheckInterval, and to
+	// the primary when none qualifies.
+	ReplicaURLs             []string
+	ReplicaMaxLag           time.Duration
+	ReplicaLagCheckInterval time.Duration
 }
 
 type RedisConfig struct {
-	Host     string
-	Port     string
-	Password string
-	DB       int
+	// Mode is standalone, sentinel or cluster.
+	Mode string
+
+	Host string
+	Port string
+	// Addrs lists sentinel or cluster seed nodes as host:port. When empty,
+	// Host and Port are used.
+	Addrs      []string
+	MasterName string
+
+	Username         string
+	Password         string
+	SentinelUsername string
+	SentinelPassword string
+	DB               int
+
+	TLS                   bool
+	TLSServerName         string
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolTimeout  time.Duration
+}
+
+type CacheConfig struct {
+	Backend    string
+	MaxEntries int
+
+	// Namespace prefixes every Redis key and pub/sub channel.
+	Namespace string
+
+	L1MaxEntries int
+	L1TTL        time.Duration
+
+	// BreakerFailures Redis errors in a row open the circuit breaker; the
+	// service then bypasses Redis for BreakerCooldown before probing it.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+
+	WarmupPages      int
+	WarmupHotUsers   int
+	WarmupTimeout    time.Duration
+	RebuildBatchSize int
+	RebuildPause     time.Duration
+}
+
+// RanksConfig controls the materialized user_ranks table. When Materialized,
+// boards and ranks are read from it; a worker applies rating changes every
+// SyncInterval and recomputes the whole table every RecomputeInterval.
+type RanksConfig struct {
+	Materialized      bool
+	SyncInterval      time.Duration
+	RecomputeInterval time.Duration
+}
+
+// SeedConfig opts in to seeding the default tenant with synthetic users on
+// startup. It only runs while the tenant has no users, so restarts never
+// undo rating changes.
+type SeedConfig struct {
+	OnStart      bool
+	Users        int
+	Distribution string
+	RandomSeed   int64
+}
+
+type AdminConfig struct {
+	Token string
 }
 
 type ServerConfig struct {
 	Port string
 	Env  string
+
+	// RateLimitRPS and RateLimitBurst limit each client IP across every
+	// route, admin and health included. Tenant limits apply on top.
+	RateLimitRPS   int
+	RateLimitBurst int
 }
 
 type Config struct {
 	Database DatabaseConfig
 	Redis    RedisConfig
+	Cache    CacheConfig
+	Ranks    RanksConfig
 	Server   ServerConfig
+	Seed     SeedConfig
+	Admin    AdminConfig
 }
 
 var (
@@ -52,6 +166,8 @@ func GetConfig() *Config {
 
 
 func loadConfig() *Config {
+	env := getEnv("ENV", "development")
+
 	return &Config{
 		Database: DatabaseConfig{
 		
@@ -63,16 +179,89 @@ func loadConfig() *Config {
 			Password: getEnv("DB_PASSWORD", "postgres"),
 			DBName:   getEnv("DB_NAME", "leaderboard"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+			AutoMigrate: getEnvBool("DB_AUTO_MIGRATE", true),
+
+			MaxOpenConns:       getEnvInt("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:       getEnvInt("DB_MAX_IDLE_CONNS", 10),
+			ConnMaxLifetime:    getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+			ConnMaxIdleTime:    getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+			StatementCacheSize: getEnvInt("DB_STATEMENT_CACHE_SIZE", 512),
+			StatementTimeout:   getEnvDuration("DB_STATEMENT_TIMEOUT", 5*time.Second),
+			RetryAttempts:      getEnvInt("DB_RETRY_ATTEMPTS", 3),
+			RetryBaseDelay:     getEnvDuration("DB_RETRY_BASE_DELAY", 25*time.Millisecond),
+			RetryMaxDelay:      getEnvDuration("DB_RETRY_MAX_DELAY", time.Second),
+
+			ReplicaURLs:             getEnvList("DB_REPLICA_URLS"),
+			ReplicaMaxLag:           getEnvDuration("DB_REPLICA_MAX_LAG", 2*time.Second),
+			ReplicaLagCheckInterval: getEnvDuration("DB_REPLICA_LAG_CHECK_INTERVAL", 5*time.Second),
 		},
 		Redis: RedisConfig{
-			Host:     getEnv("REDIS_HOST", "localhost"),
-			Port:     getEnv("REDIS_PORT", "6379"),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       0,
+			Mode: getEnv("REDIS_MODE", "standalone"),
+
+			Host:       getEnv("REDIS_HOST", "localhost"),
+			Port:       getEnv("REDIS_PORT", "6379"),
+			Addrs:      getEnvList("REDIS_ADDRS"),
+			MasterName: getEnv("REDIS_MASTER_NAME", ""),
+
+			Username:         getEnv("REDIS_USERNAME", ""),
+			Password:         getEnv("REDIS_PASSWORD", ""),
+			SentinelUsername: getEnv("REDIS_SENTINEL_USERNAME", ""),
+			SentinelPassword: getEnv("REDIS_SENTINEL_PASSWORD", ""),
+			DB:               getEnvInt("REDIS_DB", 0),
+
+			TLS:                   getEnvBool("REDIS_TLS", false),
+			TLSServerName:         getEnv("REDIS_TLS_SERVER_NAME", ""),
+			TLSCAFile:             getEnv("REDIS_TLS_CA_FILE", ""),
+			TLSCertFile:           getEnv("REDIS_TLS_CERT_FILE", ""),
+			TLSKeyFile:            getEnv("REDIS_TLS_KEY_FILE", ""),
+			TLSInsecureSkipVerify: getEnvBool("REDIS_TLS_INSECURE_SKIP_VERIFY", false),
+
+			PoolSize:     getEnvInt("REDIS_POOL_SIZE", 0),
+			MinIdleConns: getEnvInt("REDIS_MIN_IDLE_CONNS", 0),
+			DialTimeout:  getEnvDuration("REDIS_DIAL_TIMEOUT", 5*time.Second),
+			ReadTimeout:  getEnvDuration("REDIS_READ_TIMEOUT", 3*time.Second),
+			WriteTimeout: getEnvDuration("REDIS_WRITE_TIMEOUT", 3*time.Second),
+			PoolTimeout:  getEnvDuration("REDIS_POOL_TIMEOUT", 4*time.Second),
+		},
+		Cache: CacheConfig{
+			Backend:    getEnv("CACHE_BACKEND", "redis"),
+			MaxEntries: getEnvInt("CACHE_MAX_ENTRIES", 100000),
+
+			Namespace: getEnv("CACHE_NAMESPACE", "leaderboard:"+env),
+
+			L1MaxEntries: getEnvInt("CACHE_L1_MAX_ENTRIES", 10000),
+			L1TTL:        getEnvDuration("CACHE_L1_TTL", 5*time.Second),
+
+			BreakerFailures: getEnvInt("CACHE_BREAKER_FAILURES", 5),
+			BreakerCooldown: getEnvDuration("CACHE_BREAKER_COOLDOWN", 10*time.Second),
+
+			WarmupPages:      getEnvInt("CACHE_WARMUP_PAGES", 10),
+			WarmupHotUsers:   getEnvInt("CACHE_WARMUP_HOT_USERS", 1000),
+			WarmupTimeout:    getEnvDuration("CACHE_WARMUP_TIMEOUT", 30*time.Second),
+			RebuildBatchSize: getEnvInt("CACHE_REBUILD_BATCH_SIZE", 500),
+			RebuildPause:     getEnvDuration("CACHE_REBUILD_PAUSE", 50*time.Millisecond),
+		},
+		Ranks: RanksConfig{
+			Materialized:      getEnvBool("RANKS_MATERIALIZED", true),
+			SyncInterval:      getEnvDuration("RANKS_SYNC_INTERVAL", time.Second),
+			RecomputeInterval: getEnvDuration("RANKS_RECOMPUTE_INTERVAL", 15*time.Minute),
 		},
 		Server: ServerConfig{
 			Port: getEnv("PORT", "8080"),
-			Env:  getEnv("ENV", "development"),
+			Env:  env,
+
+			RateLimitRPS:   getEnvInt("RATE_LIMIT_RPS", 300),
+			RateLimitBurst: getEnvInt("RATE_LIMIT_BURST", 600),
+		},
+		Seed: SeedConfig{
+			OnStart:      getEnvBool("SEED_ON_START", false),
+			Users:        getEnvInt("SEED_USERS", 500),
+			Distribution: getEnv("SEED_DISTRIBUTION", "uniform"),
+			RandomSeed:   int64(getEnvInt("SEED_RANDOM_SEED", 1)),
+		},
+		Admin: AdminConfig{
+			Token: getEnv("ADMIN_TOKEN", ""),
 		},
 	}
 }
@@ -84,6 +273,44 @@ func getEnv(key, defaultVal string) string {
 	return defaultVal
 }
 
+func getEnvInt(key string, defaultVal int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultVal
+}
+
+func getEnvBool(key string, defaultVal bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultVal
+}
+
+// getEnvList splits a comma-separated variable, dropping empty items.
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultVal
+}
+
 
 func (c *DatabaseConfig) GetDSN() string {
 
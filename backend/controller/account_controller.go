@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"leaderboard-system/service"
+)
+
+
+type AccountController struct {
+	service *service.AccountService
+	logger  *zap.Logger
+}
+
+
+func NewAccountController(service *service.AccountService, logger *zap.Logger) *AccountController {
+	return &AccountController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// DeleteUser deactivates the user. An optional ?reason= is kept in the audit
+// log. Erasure cannot be undone, so ?erase=true is only taken on the admin
+// route.
+func (ctrl *AccountController) DeleteUser(c *gin.Context) {
+	ctrl.deleteUser(c, service.ActorSelf)
+}
+
+// AdminDeleteUser is DeleteUser audited as done by an admin, or erases the
+// user with ?erase=true.
+func (ctrl *AccountController) AdminDeleteUser(c *gin.Context) {
+	ctrl.deleteUser(c, service.ActorAdmin)
+}
+
+
+func (ctrl *AccountController) deleteUser(c *gin.Context, actor string) {
+	userID := c.Param("user_id")
+
+	erase, err := strconv.ParseBool(c.DefaultQuery("erase", "false"))
+	if err != nil {
+		c.Error(invalidParam("erase", "must be true or false"))
+		return
+	}
+	if erase && actor != service.ActorAdmin {
+		c.Error(service.NewError(service.ErrForbidden, "ERASE_REQUIRES_ADMIN", "Erasing a user requires the admin route"))
+		return
+	}
+
+	status := "deactivated"
+	if erase {
+		status = "erased"
+		err = ctrl.service.Erase(c.Request.Context(), userID, actor, c.Query("reason"))
+	} else {
+		err = ctrl.service.Deactivate(c.Request.Context(), userID, actor, c.Query("reason"))
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data: gin.H{
+			"id":     userID,
+			"status": status,
+		},
+	})
+}
+
+
+func (ctrl *AccountController) ReactivateUser(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	userDTO, rank, err := ctrl.service.Reactivate(c.Request.Context(), userID, service.ActorAdmin, c.Query("reason"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data: gin.H{
+			"id":          userDTO.ID,
+			"username":    userDTO.Username,
+			"rating":      userDTO.Rating,
+			"rank":        rank,
+			"ranks_as_of": userDTO.RanksAsOf,
+		},
+	})
+}
+
+
+func (ctrl *AccountController) AuditLog(c *gin.Context) {
+	entries, err := ctrl.service.AuditLog(c.Request.Context(), c.Param("user_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    entries,
+	})
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"leaderboard-system/service"
+)
+
+
+type AdminController struct {
+	warmer   *service.CacheWarmer
+	importer *service.ImportService
+	logger   *zap.Logger
+}
+
+
+func NewAdminController(warmer *service.CacheWarmer, importer *service.ImportService, logger *zap.Logger) *AdminController {
+	return &AdminController{
+		warmer:   warmer,
+		importer: importer,
+		logger:   logger,
+	}
+}
+
+
+func (ctrl *AdminController) RebuildCache(c *gin.Context) {
+	status, err := ctrl.warmer.StartRebuild(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	ctrl.logger.Info("Cache rebuild started")
+	c.JSON(http.StatusAccepted, SuccessResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+
+func (ctrl *AdminController) RebuildStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    ctrl.warmer.Status(),
+	})
+}
+
+// ImportUsers streams the request body into the user table. The format is
+// ?format=csv|ndjson, or else taken from the Content-Type.
+func (ctrl *AdminController) ImportUsers(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format, _ = service.DetectImportFormat(c.ContentType())
+	}
+
+	// A large import outlasts the server's read and write timeouts, which
+	// are meant for ordinary requests.
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		ctrl.logger.Warn("Failed to lift read deadline for import", zap.Error(err))
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		ctrl.logger.Warn("Failed to lift write deadline for import", zap.Error(err))
+	}
+
+	// A failed import still reports the rows loaded before it stopped.
+	report, err := ctrl.importer.Import(c.Request.Context(), c.Request.Body, format)
+	if err != nil {
+		c.Error(err).SetMeta(gin.H{"report": report})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    report,
+	})
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"leaderboard-system/service"
+)
+
+// Binding errors name fields by their JSON name, the one clients send.
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// bindError turns a failure to bind a request body into an INVALID_REQUEST
+// validation error naming the fields at fault.
+func bindError(err error) *service.Error {
+	var (
+		invalid   validator.ValidationErrors
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &invalid):
+		fields := make([]service.FieldError, 0, len(invalid))
+		for _, fe := range invalid {
+			message := "failed the " + fe.Tag() + " check"
+			if fe.Tag() == "required" {
+				message = "is required"
+			}
+			fields = append(fields, service.FieldError{Field: fe.Field(), Message: message})
+		}
+		return service.NewValidationError("INVALID_REQUEST", "request has invalid fields", fields...)
+	case errors.As(err, &typeErr):
+		return service.NewValidationError("INVALID_REQUEST", "request has invalid fields",
+			service.FieldError{Field: typeErr.Field, Message: "must be " + jsonType(typeErr.Type)})
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return service.NewValidationError("INVALID_REQUEST", "request body must be a JSON object")
+	}
+	return service.NewValidationError("INVALID_REQUEST", err.Error())
+}
+
+// invalidParam reports a query or path parameter that is malformed.
+func invalidParam(name, message string) *service.Error {
+	return service.NewValidationError("INVALID_REQUEST", fmt.Sprintf("%s %s", name, message),
+		service.FieldError{Field: name, Message: message})
+}
+
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"leaderboard-system/database"
+	"leaderboard-system/service"
+)
+
+
+type HealthController struct {
+	users    *service.UserService
+	db       *gorm.DB
+	replicas *database.Replicas
+	logger   *zap.Logger
+}
+
+
+func NewHealthController(users *service.UserService, db *gorm.DB, replicas *database.Replicas, logger *zap.Logger) *HealthController {
+	return &HealthController{
+		users:    users,
+		db:       db,
+		replicas: replicas,
+		logger:   logger,
+	}
+}
+
+// Health reports unhealthy when the cache or the primary database does not
+// answer. Lagging replicas only degrade reads to the primary, so they are
+// reported but do not fail the check. Neither does a failing Redis behind a
+// circuit breaker: the service bypasses it, so it is only degraded.
+func (ctrl *HealthController) Health(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	cacheHealthy := ctrl.users.IsHealthy(ctx)
+	cacheStatus := gin.H{"healthy": cacheHealthy}
+	breaker, bypassable := ctrl.users.CacheBreaker()
+	if bypassable {
+		cacheStatus["breaker"] = breaker
+	}
+
+	dbErr := database.Ping(ctx, ctrl.db)
+	if dbErr != nil {
+		ctrl.logger.Warn("Database health check failed", zap.Error(dbErr))
+	}
+
+	db := gin.H{
+		"healthy":  dbErr == nil,
+		"pool":     ctrl.poolStats(),
+		"replicas": ctrl.replicas.Status(),
+	}
+
+	status, code := "healthy", http.StatusOK
+	switch {
+	case dbErr != nil, !cacheHealthy && !bypassable:
+		status, code = "unhealthy", http.StatusServiceUnavailable
+	case !cacheHealthy:
+		status = "degraded"
+	}
+
+	c.JSON(code, gin.H{
+		"status":    status,
+		"timestamp": time.Now().UTC(),
+		"cache":     cacheStatus,
+		"database":  db,
+	})
+}
+
+
+func (ctrl *HealthController) Metrics(c *gin.Context) {
+	metrics := gin.H{
+		"timestamp": time.Now().UTC(),
+		"database": gin.H{
+			"pool":     ctrl.poolStats(),
+			"replicas": ctrl.replicas.Status(),
+		},
+	}
+
+	cacheMetrics := gin.H{}
+	if stats, ok := ctrl.users.CacheStats(); ok {
+		cacheMetrics["l1"] = stats.L1
+		cacheMetrics["l2"] = stats.L2
+	}
+	if breaker, ok := ctrl.users.CacheBreaker(); ok {
+		cacheMetrics["breaker"] = breaker
+	}
+	if len(cacheMetrics) > 0 {
+		metrics["cache"] = cacheMetrics
+	}
+
+	// How far the materialized rank table trails rating changes.
+	if st, ok, err := ctrl.users.RankTableState(c.Request.Context()); ok {
+		if err != nil {
+			ctrl.logger.Warn("Failed to read rank table state", zap.Error(err))
+		}
+		metrics["ranks"] = st
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+
+func (ctrl *HealthController) poolStats() database.PoolStats {
+	stats, err := database.Stats(ctrl.db)
+	if err != nil {
+		ctrl.logger.Warn("Failed to read database pool stats", zap.Error(err))
+	}
+	return stats
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"leaderboard-system/models"
+	"leaderboard-system/service"
+)
+
+// TenantController serves the admin endpoints that create and configure
+// tenants.
+type TenantController struct {
+	service *service.TenantService
+	logger  *zap.Logger
+}
+
+// issuedTenant is a tenant with the API key just issued to it, the only time
+// the key is shown.
+type issuedTenant struct {
+	*models.Tenant
+	APIKey string `json:"api_key"`
+}
+
+
+func NewTenantController(service *service.TenantService, logger *zap.Logger) *TenantController {
+	return &TenantController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+
+func (ctrl *TenantController) CreateTenant(c *gin.Context) {
+	var req struct {
+		ID             string  `json:"id" binding:"required"`
+		Name           string  `json:"name"`
+		RateLimitRPS   float64 `json:"rate_limit_rps"`
+		RateLimitBurst int     `json:"rate_limit_burst"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	t, key, err := ctrl.service.CreateTenant(c.Request.Context(), req.ID, req.Name, req.RateLimitRPS, req.RateLimitBurst)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, SuccessResponse{
+		Success: true,
+		Data:    issuedTenant{Tenant: t, APIKey: key},
+	})
+}
+
+
+func (ctrl *TenantController) ListTenants(c *gin.Context) {
+	tenants, err := ctrl.service.ListTenants(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    tenants,
+	})
+}
+
+
+func (ctrl *TenantController) GetTenant(c *gin.Context) {
+	t, err := ctrl.service.GetTenant(c.Request.Context(), c.Param("tenant_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    t,
+	})
+}
+
+// UpdateTenant changes the name or rate limit given in the body.
+func (ctrl *TenantController) UpdateTenant(c *gin.Context) {
+	var req service.TenantUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	t, err := ctrl.service.UpdateTenant(c.Request.Context(), c.Param("tenant_id"), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    t,
+	})
+}
+
+// RotateAPIKey replaces the tenant's API key and returns the new one.
+func (ctrl *TenantController) RotateAPIKey(c *gin.Context) {
+	t, key, err := ctrl.service.RotateAPIKey(c.Request.Context(), c.Param("tenant_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    issuedTenant{Tenant: t, APIKey: key},
+	})
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
 	"leaderboard-system/service"
 )
 
@@ -26,13 +28,6 @@ func NewUserController(service *service.UserService, logger *zap.Logger) *UserCo
 }
 
 
-type ErrorResponse struct {
-	Error     string `json:"error"`
-	Message   string `json:"message"`
-	Timestamp string `json:"timestamp"`
-}
-
-
 type SuccessResponse struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data"`
@@ -47,23 +42,13 @@ func (ctrl *UserController) CreateUser(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ctrl.logger.Warn("Invalid create user request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:     "INVALID_REQUEST",
-			Message:   err.Error(),
-			Timestamp: time.Now().UTC().String(),
-		})
+		c.Error(bindError(err))
 		return
 	}
 
 	user, err := ctrl.service.CreateUser(c.Request.Context(), req.UserID, req.Username, req.InitialRating)
 	if err != nil {
-		ctrl.logger.Error("Failed to create user", zap.Error(err))
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:     "CREATE_FAILED",
-			Message:   err.Error(),
-			Timestamp: time.Now().UTC().String(),
-		})
+		c.Error(err)
 		return
 	}
 
@@ -79,21 +64,18 @@ func (ctrl *UserController) GetUser(c *gin.Context) {
 
 	userDTO, rank, err := ctrl.service.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
-		ctrl.logger.Error("Failed to get user", zap.Error(err))
-		c.JSON(http.StatusNotFound, ErrorResponse{
-			Error:     "NOT_FOUND",
-			Message:   "User not found",
-			Timestamp: time.Now().UTC().String(),
-		})
+		c.Error(err)
 		return
 	}
 
 
 	response := gin.H{
-		"id":       userDTO.ID,
-		"username": userDTO.Username,
-		"rating":   userDTO.Rating,
-		"rank":     rank,
+		"id":          userDTO.ID,
+		"username":    userDTO.Username,
+		"rating":      userDTO.Rating,
+		"rank":        rank,
+		"metadata":    userDTO.Metadata,
+		"ranks_as_of": userDTO.RanksAsOf,
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse{
@@ -102,6 +84,87 @@ func (ctrl *UserController) GetUser(c *gin.Context) {
 	})
 }
 
+// UpdateUser serves PATCH /users/:user_id with a username, metadata or both.
+func (ctrl *UserController) UpdateUser(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	var req struct {
+		Username *string             `json:"username"`
+		Metadata models.UserMetadata `json:"metadata"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	userDTO, rank, err := ctrl.service.UpdateUser(c.Request.Context(), userID, service.UserPatch{
+		Username: req.Username,
+		Metadata: req.Metadata,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data: gin.H{
+			"id":          userDTO.ID,
+			"username":    userDTO.Username,
+			"rating":      userDTO.Rating,
+			"rank":        rank,
+			"metadata":    userDTO.Metadata,
+			"ranks_as_of": userDTO.RanksAsOf,
+		},
+	})
+}
+
+// ListUsers serves GET /users?username=&min_rating=&max_rating=
+// &created_after=&created_before=&sort=&order=&page=&page_size=. username
+// is a prefix; the created_ bounds are RFC 3339 times.
+func (ctrl *UserController) ListUsers(c *gin.Context) {
+	opts := service.UserListOptions{
+		Username: c.Query("username"),
+		Sort:     c.Query("sort"),
+		Order:    c.Query("order"),
+	}
+	opts.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	opts.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(service.DefaultUserListPageSize)))
+
+	for name, dst := range map[string]*int32{"min_rating": &opts.MinRating, "max_rating": &opts.MaxRating} {
+		if v := c.Query(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil {
+				c.Error(invalidParam(name, "must be an integer"))
+				return
+			}
+			*dst = int32(n)
+		}
+	}
+	for name, dst := range map[string]*time.Time{"created_after": &opts.CreatedAfter, "created_before": &opts.CreatedBefore} {
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.Error(invalidParam(name, "must be an RFC 3339 time"))
+				return
+			}
+			*dst = t
+		}
+	}
+
+	users, err := ctrl.service.ListUsers(c.Request.Context(), opts)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    users,
+	})
+}
+
 
 func (ctrl *UserController) UpdateRating(c *gin.Context) {
 	userID := c.Param("user_id")
@@ -111,31 +174,22 @@ func (ctrl *UserController) UpdateRating(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ctrl.logger.Warn("Invalid rating update request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:     "INVALID_REQUEST",
-			Message:   err.Error(),
-			Timestamp: time.Now().UTC().String(),
-		})
+		c.Error(bindError(err))
 		return
 	}
 
 	userDTO, rank, err := ctrl.service.UpdateUserRating(c.Request.Context(), userID, req.Rating)
 	if err != nil {
-		ctrl.logger.Error("Failed to update rating", zap.Error(err))
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:     "UPDATE_FAILED",
-			Message:   err.Error(),
-			Timestamp: time.Now().UTC().String(),
-		})
+		c.Error(err)
 		return
 	}
 
 	response := gin.H{
-		"id":       userDTO.ID,
-		"username": userDTO.Username,
-		"rating":   userDTO.Rating,
-		"rank":     rank,
+		"id":          userDTO.ID,
+		"username":    userDTO.Username,
+		"rating":      userDTO.Rating,
+		"rank":        rank,
+		"ranks_as_of": userDTO.RanksAsOf,
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse{
@@ -146,25 +200,21 @@ func (ctrl *UserController) UpdateRating(c *gin.Context) {
 
 
 func (ctrl *UserController) SearchUser(c *gin.Context) {
+	if _, ok := c.GetQuery("q"); ok {
+		ctrl.SearchUsers(c)
+		return
+	}
+
 	username := c.Query("username")
 
 	if username == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:     "INVALID_REQUEST",
-			Message:   "username parameter required",
-			Timestamp: time.Now().UTC().String(),
-		})
+		c.Error(invalidParam("username", "is required"))
 		return
 	}
 
 	userDTO, rank, err := ctrl.service.SearchUserByUsername(c.Request.Context(), username)
 	if err != nil {
-		ctrl.logger.Error("Search failed", zap.Error(err))
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:     "SEARCH_FAILED",
-			Message:   err.Error(),
-			Timestamp: time.Now().UTC().String(),
-		})
+		c.Error(err)
 		return
 	}
 
@@ -192,6 +242,25 @@ func (ctrl *UserController) SearchUser(c *gin.Context) {
 }
 
 
+// SearchUsers serves GET /users/search?q=...&mode=prefix|substring|fuzzy
+// &page=N&page_size=N.
+func (ctrl *UserController) SearchUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(service.DefaultSearchPageSize)))
+
+	results, err := ctrl.service.SearchUsers(c.Request.Context(), c.Query("q"), repository.SearchMode(c.Query("mode")), page, pageSize)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    results,
+	})
+}
+
+
 func (ctrl *UserController) GetLeaderboard(c *gin.Context) {
 	page := c.DefaultQuery("page", "1")
 	pageSize := c.DefaultQuery("page_size", "100")
@@ -208,12 +277,7 @@ func (ctrl *UserController) GetLeaderboard(c *gin.Context) {
 
 	leaderboard, err := ctrl.service.GetLeaderboard(c.Request.Context(), pageNum, pageSizeNum)
 	if err != nil {
-		ctrl.logger.Error("Failed to get leaderboard", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:     "FETCH_FAILED",
-			Message:   "Failed to fetch leaderboard",
-			Timestamp: time.Now().UTC().String(),
-		})
+		c.Error(err)
 		return
 	}
 
@@ -224,6 +288,26 @@ func (ctrl *UserController) GetLeaderboard(c *gin.Context) {
 }
 
 
+func (ctrl *UserController) GetLeaderboardChanges(c *gin.Context) {
+	since, err := strconv.ParseInt(c.Query("since"), 10, 64)
+	if err != nil {
+		c.Error(invalidParam("since", "must be a leaderboard version"))
+		return
+	}
+
+	changes, err := ctrl.service.GetLeaderboardChanges(c.Request.Context(), since)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    changes,
+	})
+}
+
+
 func (ctrl *UserController) GetLeaderboardAroundUser(c *gin.Context) {
 	userID := c.Param("user_id")
 	contextSize := c.DefaultQuery("context_size", "10")
@@ -235,12 +319,7 @@ func (ctrl *UserController) GetLeaderboardAroundUser(c *gin.Context) {
 
 	leaderboard, err := ctrl.service.GetLeaderboardAroundUser(c.Request.Context(), userID, contextSizeNum)
 	if err != nil {
-		ctrl.logger.Error("Failed to get leaderboard context", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:     "FETCH_FAILED",
-			Message:   "Failed to fetch leaderboard context",
-			Timestamp: time.Now().UTC().String(),
-		})
+		c.Error(err)
 		return
 	}
 
@@ -249,20 +328,3 @@ func (ctrl *UserController) GetLeaderboardAroundUser(c *gin.Context) {
 		Data:    leaderboard,
 	})
 }
-
-
-func (ctrl *UserController) Health(c *gin.Context) {
-	healthy := ctrl.service.IsHealthy(c.Request.Context())
-
-	if healthy {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "healthy",
-			"timestamp": time.Now().UTC(),
-		})
-	} else {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"status": "unhealthy",
-			"timestamp": time.Now().UTC(),
-		})
-	}
-}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"leaderboard-system/models"
+	"leaderboard-system/service"
+)
+
+
+type WatchController struct {
+	service *service.WatchService
+	logger  *zap.Logger
+}
+
+
+func NewWatchController(service *service.WatchService, logger *zap.Logger) *WatchController {
+	return &WatchController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+
+func (ctrl *WatchController) CreateWatch(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	var req struct {
+		Type      models.WatchType `json:"type" binding:"required"`
+		TargetID  string           `json:"target_id"`
+		Threshold int64            `json:"threshold"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	watch, err := ctrl.service.CreateWatch(c.Request.Context(), userID, req.Type, req.TargetID, req.Threshold)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, SuccessResponse{
+		Success: true,
+		Data:    watch,
+	})
+}
+
+
+func (ctrl *WatchController) ListWatches(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	watches, err := ctrl.service.ListWatches(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    watches,
+	})
+}
+
+
+func (ctrl *WatchController) DeleteWatch(c *gin.Context) {
+	userID := c.Param("user_id")
+	watchID := c.Param("watch_id")
+
+	if err := ctrl.service.DeleteWatch(c.Request.Context(), userID, watchID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
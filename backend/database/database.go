@@ -1,61 +1,134 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"strconv"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"leaderboard-system/config"
-	"leaderboard-system/models"
 )
 
 
 func InitDB(cfg *config.DatabaseConfig, logLevel logger.LogLevel) (*gorm.DB, error) {
-	dsn := cfg.GetDSN()
-	
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
-	})
+	db, err := Open(cfg, logLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := EnsureSchema(db, cfg.AutoMigrate); err != nil {
+		return nil, fmt.Errorf("failed to check database schema: %w", err)
+	}
+
+	return db, nil
+}
+
+// Open connects without touching the schema, for the migrate command.
+func Open(cfg *config.DatabaseConfig, logLevel logger.LogLevel) (*gorm.DB, error) {
+	db, err := openPool(cfg.GetDSN(), cfg, logLevel)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	if err := runMigrations(db); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	return db, nil
+}
+
+// openPool opens dsn with the pool limits, statement cache and statement
+// timeout from cfg. Replicas are opened the same way.
+func openPool(dsn string, cfg *config.DatabaseConfig, logLevel logger.LogLevel) (*gorm.DB, error) {
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Postgres cancels any statement running longer than this, so a slow
+	// query gives its connection back instead of holding it indefinitely.
+	if cfg.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	// pgx prepares each distinct query once per connection and reuses it.
+	// Without the cache every query is sent unprepared, which is what a
+	// transaction-pooling proxy like PgBouncer needs.
+	if cfg.StatementCacheSize > 0 {
+		connConfig.StatementCacheCapacity = cfg.StatementCacheSize
+		connConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	} else {
+		connConfig.StatementCacheCapacity = 0
+		connConfig.DescriptionCacheCapacity = 0
+		connConfig.DefaultQueryExecMode = pgx.QueryExecModeExec
 	}
 
+	sqlDB := stdlib.OpenDB(*connConfig)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	if err := createIndexes(db); err != nil {
-		return nil, fmt.Errorf("failed to create indexes: %w", err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: newRetryPool(sqlDB, RetryPolicyFromConfig(cfg))}), &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+	})
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
 	}
 
 	return db, nil
 }
 
 
-func runMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(&models.User{})
+type PoolStats struct {
+	MaxOpenConnections int     `json:"max_open_connections"`
+	OpenConnections    int     `json:"open_connections"`
+	InUse              int     `json:"in_use"`
+	Idle               int     `json:"idle"`
+	WaitCount          int64   `json:"wait_count"`
+	WaitSeconds        float64 `json:"wait_seconds"`
+	MaxIdleClosed      int64   `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64   `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64   `json:"max_lifetime_closed"`
+	// Retries counts statements retried after a transient failure.
+	Retries uint64 `json:"retries"`
 }
 
+// Stats reports the connection pool behind db.
+func Stats(db *gorm.DB) (PoolStats, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return PoolStats{}, err
+	}
+	stats := poolStats(sqlDB.Stats())
+	stats.Retries = retryCount(db)
+	return stats, nil
+}
 
-func createIndexes(db *gorm.DB) error {
 
-	if err := db.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_users_rating_username 
-		ON users(rating DESC, username)
-	`).Error; err != nil {
-		return err
+func poolStats(s sql.DBStats) PoolStats {
+	return PoolStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitSeconds:        s.WaitDuration.Seconds(),
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
 	}
+}
 
-	if err := db.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_users_username_lower 
-		ON users(LOWER(username))
-	`).Error; err != nil {
+// Ping checks that the database behind db answers.
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
 		return err
 	}
-
-	return nil
+	return sqlDB.PingContext(ctx)
 }
 
 
@@ -0,0 +1,328 @@
+package database
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey serializes migrations across instances starting at once.
+const migrationLockKey = 7208301
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrSchemaMismatch = errors.New("database schema does not match this build")
+
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;column:version;autoIncrement:false"`
+	Name      string    `gorm:"column:name;not null"`
+	Checksum  string    `gorm:"column:checksum;not null"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+}
+
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified means the migration file changed after it was applied.
+	Modified bool
+}
+
+// Migrations returns the embedded migrations in version order.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := migrationName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("bad migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", mig.Version, mig.Name)
+		}
+		sum := sha256.Sum256([]byte(mig.Up))
+		mig.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Schema renders the full schema by concatenating every up migration. It is
+// what DATABASE_SCHEMA.sql is generated from.
+func Schema() (string, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("-- Code generated by `go run . migrate schema`. DO NOT EDIT.\n")
+	b.WriteString("-- Leaderboard System - Database Schema (PostgreSQL 12+)\n")
+	b.WriteString("-- Source: backend/database/migrations/*.up.sql, applied in order.\n")
+	for _, mig := range migrations {
+		fmt.Fprintf(&b, "\n-- ========================================\n-- %04d %s\n-- ========================================\n\n", mig.Version, mig.Name)
+		b.WriteString(strings.TrimSpace(mig.Up))
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+
+func ensureMigrationsTable(db *gorm.DB) error {
+	return db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`).Error
+}
+
+
+func appliedMigrations(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := db.Order("version ASC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	applied := make(map[int64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// checkKnown fails when the database has migrations this build does not ship
+// or applied migrations whose file has since changed.
+func checkKnown(migrations []Migration, applied map[int64]SchemaMigration) error {
+	known := make(map[int64]Migration, len(migrations))
+	for _, mig := range migrations {
+		known[mig.Version] = mig
+	}
+
+	for version, row := range applied {
+		mig, ok := known[version]
+		if !ok {
+			return fmt.Errorf("%w: migration %d_%s is applied but unknown to this build", ErrSchemaMismatch, version, row.Name)
+		}
+		if mig.Checksum != row.Checksum {
+			return fmt.Errorf("%w: migration %d_%s was modified after it was applied", ErrSchemaMismatch, version, row.Name)
+		}
+	}
+	return nil
+}
+
+// withMigrationLock runs fn in one transaction holding the migration lock, so
+// concurrent instances migrate one after another and a failed migration
+// leaves no partial schema behind.
+func withMigrationLock(db *gorm.DB, fn func(tx *gorm.DB, migrations []Migration, applied map[int64]SchemaMigration) error) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Building an index on a large table can outlast the statement
+		// timeout meant for request queries.
+		if err := tx.Exec("SET LOCAL statement_timeout = 0").Error; err != nil {
+			return fmt.Errorf("failed to lift statement timeout: %w", err)
+		}
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey).Error; err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		if err := ensureMigrationsTable(tx); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+
+		applied, err := appliedMigrations(tx)
+		if err != nil {
+			return err
+		}
+		if err := checkKnown(migrations, applied); err != nil {
+			return err
+		}
+		return fn(tx, migrations, applied)
+	})
+}
+
+// MigrateUp applies every pending migration and returns the ones it applied.
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	var done []Migration
+	err := withMigrationLock(db, func(tx *gorm.DB, migrations []Migration, applied map[int64]SchemaMigration) error {
+		for _, mig := range migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := tx.Exec(mig.Up).Error; err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			row := SchemaMigration{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum, AppliedAt: time.Now().UTC()}
+			if err := tx.Create(&row).Error; err != nil {
+				return fmt.Errorf("failed to record migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the latest steps applied migrations, newest first.
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	var done []Migration
+	err := withMigrationLock(db, func(tx *gorm.DB, migrations []Migration, applied map[int64]SchemaMigration) error {
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := tx.Exec(mig.Down).Error; err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			if err := tx.Delete(&SchemaMigration{}, "version = ?", mig.Version).Error; err != nil {
+				return fmt.Errorf("failed to unrecord migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// MigrationStatuses lists every known migration and whether it is applied.
+// Applied versions this build does not know are listed too.
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, mig := range migrations {
+		status := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if row, ok := applied[mig.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = row.AppliedAt
+			status.Modified = row.Checksum != mig.Checksum
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		statuses = append(statuses, MigrationStatus{Version: row.Version, Name: row.Name, Applied: true, AppliedAt: row.AppliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// EnsureSchema makes sure the database is at exactly the schema this build
+// expects. It refuses unknown or modified migrations, and applies pending ones
+// only when autoMigrate is set.
+func EnsureSchema(db *gorm.DB, autoMigrate bool) error {
+	if autoMigrate {
+		_, err := MigrateUp(db)
+		return err
+	}
+
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	known := make(map[int64]bool, len(migrations))
+	for _, mig := range migrations {
+		known[mig.Version] = true
+	}
+
+	var pending []string
+	for _, status := range statuses {
+		switch {
+		case !known[status.Version]:
+			return fmt.Errorf("%w: migration %d_%s is applied but unknown to this build", ErrSchemaMismatch, status.Version, status.Name)
+		case status.Modified:
+			return fmt.Errorf("%w: migration %d_%s was modified after it was applied", ErrSchemaMismatch, status.Version, status.Name)
+		case !status.Applied:
+			pending = append(pending, fmt.Sprintf("%d_%s", status.Version, status.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending migrations %s; run `migrate up`", ErrSchemaMismatch, strings.Join(pending, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+-- Users and their ratings. Statements are idempotent so a database created
+-- by the old GORM AutoMigrate is adopted as is.
+CREATE TABLE IF NOT EXISTS users (
+  id VARCHAR(255) PRIMARY KEY,
+  username VARCHAR(255) NOT NULL,
+  rating INTEGER NOT NULL DEFAULT 1000,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Ratings are validated by the service too (service/validation.go); the
+-- constraint keeps bulk loads and manual fixes honest.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_rating;
+ALTER TABLE users ADD CONSTRAINT chk_users_rating
+  CHECK (rating >= 100 AND rating <= 5000);
+
+-- Usernames are unique as written; lookups are case-insensitive.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(username);
+
+-- Rank calculation: SELECT COUNT(*) + 1 FROM users WHERE rating > $1.
+-- AutoMigrate created a plain index under this name, so it is rebuilt.
+DROP INDEX IF EXISTS idx_users_rating;
+CREATE INDEX idx_users_rating ON users(rating DESC)
+  WHERE rating >= 100 AND rating <= 5000;
+
+-- Leaderboard pages: ORDER BY rating DESC, username ASC.
+CREATE INDEX IF NOT EXISTS idx_users_rating_username ON users(rating DESC, username);
+
+-- Case-insensitive lookup and prefix search: WHERE LOWER(username) = LOWER($1).
+CREATE INDEX IF NOT EXISTS idx_users_username_lower ON users(LOWER(username));
@@ -0,0 +1,13 @@
+-- Rank watches: notify a watcher when a rival overtakes them or they drop
+-- out of a rank threshold.
+CREATE TABLE IF NOT EXISTS watches (
+  id VARCHAR(255) PRIMARY KEY,
+  watcher_id VARCHAR(255) NOT NULL,
+  type VARCHAR(32) NOT NULL,
+  target_id VARCHAR(255),
+  threshold BIGINT,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_watches_watcher ON watches(watcher_id);
+CREATE INDEX IF NOT EXISTS idx_watches_target ON watches(target_id);
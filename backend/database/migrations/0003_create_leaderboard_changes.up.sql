@@ -0,0 +1,9 @@
+-- Append-only log of rating writes. version is the leaderboard version
+-- clients poll GET /leaderboard/changes with; old rows are pruned.
+CREATE TABLE IF NOT EXISTS leaderboard_changes (
+  version BIGSERIAL PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL,
+  old_rating INTEGER NOT NULL,
+  new_rating INTEGER NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS user_audit_log;
+
+DROP INDEX IF EXISTS idx_users_rating_username;
+CREATE INDEX idx_users_rating_username ON users(rating DESC, username);
+
+DROP INDEX IF EXISTS idx_users_rating;
+CREATE INDEX idx_users_rating ON users(rating DESC)
+  WHERE rating >= 100 AND rating <= 5000;
+
+ALTER TABLE users DROP COLUMN IF EXISTS erased_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,27 @@
+-- Deactivated users keep their row, and their username, until reactivated or
+-- erased. Erased users are deactivated for good with the username replaced.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS erased_at TIMESTAMP WITH TIME ZONE;
+
+-- Every board and rank query only sees active users, so the rank indexes
+-- cover only those.
+DROP INDEX IF EXISTS idx_users_rating;
+CREATE INDEX idx_users_rating ON users(rating DESC)
+  WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_users_rating_username;
+CREATE INDEX idx_users_rating_username ON users(rating DESC, username)
+  WHERE deleted_at IS NULL;
+
+-- Who deactivated, reactivated or erased which user, and when. Entries hold
+-- no username so they survive erasure.
+CREATE TABLE IF NOT EXISTS user_audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL,
+  action VARCHAR(32) NOT NULL,
+  actor VARCHAR(255) NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_audit_log_user ON user_audit_log(user_id, id);
@@ -0,0 +1,2 @@
+-- The extension is left installed; other schemas may use it.
+DROP INDEX IF EXISTS idx_users_username_trgm;
@@ -0,0 +1,19 @@
+-- Fuzzy username search uses pg_trgm when the database lets us install it.
+-- Without it the migration still succeeds and the service searches an
+-- in-memory index instead.
+DO $$
+BEGIN
+  CREATE EXTENSION IF NOT EXISTS pg_trgm;
+EXCEPTION WHEN insufficient_privilege OR undefined_file OR feature_not_supported THEN
+  RAISE NOTICE 'pg_trgm unavailable, username search falls back to memory';
+END $$;
+
+-- Trigram index for WHERE LOWER(username) % $1 and LIKE '%...%' searches.
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+    CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users
+      USING gin (LOWER(username) gin_trgm_ops)
+      WHERE deleted_at IS NULL;
+  END IF;
+END $$;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_ranks_state;
+DROP TABLE IF EXISTS user_ranks;
@@ -0,0 +1,28 @@
+-- Ranks of every active user, materialized so board and rank reads need no
+-- COUNT(*). position is the dense 1-based place in leaderboard order (rating
+-- descending, then username) and rank the tie-aware rank. A background worker
+-- keeps the table in step with leaderboard_changes and recomputes it in full
+-- from time to time.
+CREATE TABLE IF NOT EXISTS user_ranks (
+  user_id VARCHAR(255) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  username VARCHAR(255) NOT NULL,
+  rating INTEGER NOT NULL,
+  rank BIGINT NOT NULL,
+  position BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_ranks_position ON user_ranks(position);
+CREATE INDEX IF NOT EXISTS idx_user_ranks_rating_username ON user_ranks(rating DESC, username);
+
+-- The single row says how far user_ranks has caught up: every change up to
+-- version is applied and the ranks were current at as_of. as_of is NULL until
+-- the first full recompute; until then ranks are computed live.
+CREATE TABLE IF NOT EXISTS user_ranks_state (
+  id SMALLINT PRIMARY KEY CHECK (id = 1),
+  version BIGINT NOT NULL DEFAULT 0,
+  total BIGINT NOT NULL DEFAULT 0,
+  as_of TIMESTAMP WITH TIME ZONE,
+  recomputed_at TIMESTAMP WITH TIME ZONE
+);
+
+INSERT INTO user_ranks_state (id) VALUES (1) ON CONFLICT DO NOTHING;
@@ -0,0 +1,35 @@
+-- Folds every tenant back into one. Fails if two tenants share a user id or
+-- username; delete the other tenants' rows first.
+ALTER TABLE user_ranks_state ADD COLUMN total BIGINT NOT NULL DEFAULT 0;
+UPDATE user_ranks_state SET as_of = NULL, recomputed_at = NULL;
+
+ALTER TABLE user_ranks DROP CONSTRAINT user_ranks_user_fkey;
+ALTER TABLE user_ranks DROP CONSTRAINT user_ranks_pkey;
+ALTER TABLE user_ranks DROP COLUMN tenant_id;
+ALTER TABLE user_ranks ADD CONSTRAINT user_ranks_pkey PRIMARY KEY (user_id);
+CREATE INDEX IF NOT EXISTS idx_user_ranks_position ON user_ranks(position);
+CREATE INDEX IF NOT EXISTS idx_user_ranks_rating_username ON user_ranks(rating DESC, username);
+
+ALTER TABLE user_audit_log DROP COLUMN tenant_id;
+CREATE INDEX IF NOT EXISTS idx_user_audit_log_user ON user_audit_log(user_id, id);
+
+ALTER TABLE leaderboard_changes DROP COLUMN tenant_id;
+
+ALTER TABLE watches DROP COLUMN tenant_id;
+CREATE INDEX IF NOT EXISTS idx_watches_watcher ON watches(watcher_id);
+CREATE INDEX IF NOT EXISTS idx_watches_target ON watches(target_id);
+
+ALTER TABLE users DROP CONSTRAINT users_pkey;
+ALTER TABLE users DROP COLUMN tenant_id;
+ALTER TABLE users ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+ALTER TABLE user_ranks ADD CONSTRAINT user_ranks_user_id_fkey
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(username);
+CREATE INDEX IF NOT EXISTS idx_users_username_lower ON users(LOWER(username));
+CREATE INDEX IF NOT EXISTS idx_users_rating ON users(rating DESC)
+  WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_rating_username ON users(rating DESC, username)
+  WHERE deleted_at IS NULL;
+
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,81 @@
+-- Tenants are the games hosted on the service. Every row of every table
+-- belongs to one; rows written before tenants existed belong to 'default'.
+-- A tenant with an API key can only be reached with that key; one without
+-- is named by the X-Tenant-ID header.
+CREATE TABLE IF NOT EXISTS tenants (
+  id VARCHAR(64) PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  api_key_hash VARCHAR(64),
+  api_key_prefix VARCHAR(16) NOT NULL DEFAULT '',
+  rate_limit_rps DOUBLE PRECISION NOT NULL DEFAULT 100,
+  rate_limit_burst INTEGER NOT NULL DEFAULT 200,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenants_api_key_hash ON tenants(api_key_hash);
+
+INSERT INTO tenants (id, name) VALUES ('default', 'Default') ON CONFLICT (id) DO NOTHING;
+
+-- Users: ids and usernames are unique per tenant. The default only backfills
+-- existing rows; the application always names the tenant.
+ALTER TABLE user_ranks DROP CONSTRAINT IF EXISTS user_ranks_user_id_fkey;
+
+ALTER TABLE users ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES tenants(id);
+ALTER TABLE users ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE users DROP CONSTRAINT users_pkey;
+ALTER TABLE users ADD CONSTRAINT users_pkey PRIMARY KEY (tenant_id, id);
+
+DROP INDEX IF EXISTS idx_users_username;
+CREATE UNIQUE INDEX idx_users_username ON users(tenant_id, username);
+
+DROP INDEX IF EXISTS idx_users_username_lower;
+CREATE INDEX idx_users_username_lower ON users(tenant_id, LOWER(username));
+
+DROP INDEX IF EXISTS idx_users_rating;
+CREATE INDEX idx_users_rating ON users(tenant_id, rating DESC)
+  WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_users_rating_username;
+CREATE INDEX idx_users_rating_username ON users(tenant_id, rating DESC, username)
+  WHERE deleted_at IS NULL;
+
+-- Watches
+ALTER TABLE watches ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES tenants(id);
+ALTER TABLE watches ALTER COLUMN tenant_id DROP DEFAULT;
+
+DROP INDEX IF EXISTS idx_watches_watcher;
+CREATE INDEX idx_watches_watcher ON watches(tenant_id, watcher_id);
+
+DROP INDEX IF EXISTS idx_watches_target;
+CREATE INDEX idx_watches_target ON watches(tenant_id, target_id);
+
+-- Change log: versions stay global, clients poll their tenant's changes.
+ALTER TABLE leaderboard_changes ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES tenants(id);
+ALTER TABLE leaderboard_changes ALTER COLUMN tenant_id DROP DEFAULT;
+
+CREATE INDEX idx_leaderboard_changes_tenant ON leaderboard_changes(tenant_id, version);
+
+-- Audit log
+ALTER TABLE user_audit_log ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES tenants(id);
+ALTER TABLE user_audit_log ALTER COLUMN tenant_id DROP DEFAULT;
+
+DROP INDEX IF EXISTS idx_user_audit_log_user;
+CREATE INDEX idx_user_audit_log_user ON user_audit_log(tenant_id, user_id, id);
+
+-- Materialized ranks and positions are per tenant. The tenant's total is its
+-- highest position, so the state row no longer keeps one.
+ALTER TABLE user_ranks ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE user_ranks ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE user_ranks DROP CONSTRAINT user_ranks_pkey;
+ALTER TABLE user_ranks ADD CONSTRAINT user_ranks_pkey PRIMARY KEY (tenant_id, user_id);
+ALTER TABLE user_ranks ADD CONSTRAINT user_ranks_user_fkey
+  FOREIGN KEY (tenant_id, user_id) REFERENCES users(tenant_id, id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS idx_user_ranks_position;
+CREATE INDEX idx_user_ranks_position ON user_ranks(tenant_id, position);
+
+DROP INDEX IF EXISTS idx_user_ranks_rating_username;
+CREATE INDEX idx_user_ranks_rating_username ON user_ranks(tenant_id, rating DESC, username);
+
+ALTER TABLE user_ranks_state DROP COLUMN total;
@@ -0,0 +1,7 @@
+-- Free-form data clients keep on a user, like a country or an avatar URL.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+
+-- GET /users lists active users newest or oldest first; rating and username
+-- orders are served by idx_users_rating_username.
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(tenant_id, created_at, id)
+  WHERE deleted_at IS NULL;
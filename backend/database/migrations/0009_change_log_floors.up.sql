@@ -0,0 +1,14 @@
+-- Each tenant's change log is pruned on its own. pruned_before is the version
+-- a tenant was last pruned up to: its changes before it may be gone, all from
+-- it on are kept. Tenants never pruned have no row.
+CREATE TABLE IF NOT EXISTS leaderboard_change_floors (
+  tenant_id VARCHAR(64) PRIMARY KEY REFERENCES tenants(id),
+  pruned_before BIGINT NOT NULL
+);
+
+-- Pruning so far was global, so every tenant's floor is the oldest version
+-- left in the log.
+INSERT INTO leaderboard_change_floors (tenant_id, pruned_before)
+SELECT t.id, c.oldest
+FROM tenants t, (SELECT MIN(version) AS oldest FROM leaderboard_changes) c
+WHERE c.oldest > 1;
@@ -0,0 +1,7 @@
+ALTER TABLE user_ranks_state ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
+
+-- One position has to hold for every tenant, so take the one furthest behind.
+UPDATE user_ranks_state SET version = COALESCE((SELECT MIN(version) FROM user_ranks_versions), 0)
+WHERE id = 1;
+
+DROP TABLE IF EXISTS user_ranks_versions;
@@ -0,0 +1,14 @@
+-- The rank table's sync position, kept per tenant: every change of the tenant
+-- up to version is applied. Versions are shared by all tenants but only
+-- commit in order within one, so a single position could pass over a change
+-- of one tenant that commits after a later change of another.
+CREATE TABLE IF NOT EXISTS user_ranks_versions (
+  tenant_id VARCHAR(64) PRIMARY KEY REFERENCES tenants(id),
+  version BIGINT NOT NULL
+);
+
+INSERT INTO user_ranks_versions (tenant_id, version)
+SELECT t.id, s.version FROM tenants t, user_ranks_state s
+WHERE s.id = 1 AND s.version > 0;
+
+ALTER TABLE user_ranks_state DROP COLUMN version;
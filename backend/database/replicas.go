@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"leaderboard-system/config"
+)
+
+// replicaLagQuery reports how far a standby is behind in seconds. A standby
+// that has replayed everything it received is caught up even if the primary
+// has been idle, and a server that is not in recovery reports 0.
+const replicaLagQuery = `
+	SELECT COALESCE(
+		CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		     ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+		END, 0)`
+
+
+type replica struct {
+	name string
+	db   *gorm.DB
+
+	mu        sync.RWMutex
+	healthy   bool
+	lag       time.Duration
+	checkedAt time.Time
+	err       error
+}
+
+
+type ReplicaStatus struct {
+	Name       string    `json:"name"`
+	Healthy    bool      `json:"healthy"`
+	LagSeconds float64   `json:"lag_seconds"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Error      string    `json:"error,omitempty"`
+	Pool       PoolStats `json:"pool"`
+}
+
+// Replicas routes reads to read replicas whose replication lag is within
+// MaxLag and falls back to the primary when none is. Lag is checked in the
+// background; a replica only takes reads once a check has passed.
+type Replicas struct {
+	primary  *gorm.DB
+	replicas []*replica
+	maxLag   time.Duration
+	interval time.Duration
+	next     uint64
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// OpenReplicas connects to every replica DSN in cfg. With none configured,
+// every read goes to the primary.
+func OpenReplicas(primary *gorm.DB, cfg *config.DatabaseConfig, logLevel logger.LogLevel) (*Replicas, error) {
+	r := &Replicas{
+		primary:  primary,
+		maxLag:   cfg.ReplicaMaxLag,
+		interval: cfg.ReplicaLagCheckInterval,
+		done:     make(chan struct{}),
+	}
+	if r.interval <= 0 {
+		r.interval = 5 * time.Second
+	}
+
+	for i, dsn := range cfg.ReplicaURLs {
+		db, err := openPool(dsn, cfg, logLevel)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to connect to replica %d: %w", i+1, err)
+		}
+		r.replicas = append(r.replicas, &replica{name: fmt.Sprintf("replica-%d", i+1), db: db})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.checkAll(ctx)
+	go r.loop(ctx)
+
+	return r, nil
+}
+
+
+func (r *Replicas) loop(ctx context.Context) {
+	defer close(r.done)
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.checkAll(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+
+func (r *Replicas) checkAll(ctx context.Context) {
+	for _, rep := range r.replicas {
+		r.check(ctx, rep)
+	}
+}
+
+
+func (r *Replicas) check(ctx context.Context, rep *replica) {
+	ctx, cancel := context.WithTimeout(ctx, r.interval)
+	defer cancel()
+
+	var seconds float64
+	err := rep.db.WithContext(ctx).Raw(replicaLagQuery).Scan(&seconds).Error
+	lag := time.Duration(seconds * float64(time.Second))
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	rep.checkedAt = time.Now().UTC()
+	rep.err = err
+	rep.lag = lag
+	rep.healthy = err == nil && (r.maxLag <= 0 || lag <= r.maxLag)
+}
+
+
+func (r *Replicas) Primary() *gorm.DB {
+	return r.primary
+}
+
+// Reader returns a healthy replica, round robin, or the primary when there
+// is none.
+func (r *Replicas) Reader() *gorm.DB {
+	if r == nil {
+		return nil
+	}
+
+	n := len(r.replicas)
+	start := atomic.AddUint64(&r.next, 1)
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(start+uint64(i))%uint64(n)]
+		rep.mu.RLock()
+		healthy := rep.healthy
+		rep.mu.RUnlock()
+		if healthy {
+			return rep.db
+		}
+	}
+	return r.primary
+}
+
+
+func (r *Replicas) Status() []ReplicaStatus {
+	if r == nil {
+		return nil
+	}
+	statuses := make([]ReplicaStatus, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		rep.mu.RLock()
+		status := ReplicaStatus{
+			Name:       rep.name,
+			Healthy:    rep.healthy,
+			LagSeconds: rep.lag.Seconds(),
+			CheckedAt:  rep.checkedAt,
+		}
+		if rep.err != nil {
+			status.Error = rep.err.Error()
+		}
+		rep.mu.RUnlock()
+		status.Pool, _ = Stats(rep.db)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// MaxLag is how stale a replica read may be.
+func (r *Replicas) MaxLag() time.Duration {
+	if r == nil || len(r.replicas) == 0 {
+		return 0
+	}
+	return r.maxLag
+}
+
+
+func (r *Replicas) Close() error {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+	for _, rep := range r.replicas {
+		if sqlDB, err := rep.db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"leaderboard-system/config"
+)
+
+const (
+	DefaultRetryAttempts  = 3
+	DefaultRetryBaseDelay = 25 * time.Millisecond
+	DefaultRetryMaxDelay  = time.Second
+)
+
+// RetryPolicy is how often and how patiently a statement or transaction that
+// failed transiently is tried again. Attempts counts the first try, so 1
+// disables retries.
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+
+func RetryPolicyFromConfig(cfg *config.DatabaseConfig) RetryPolicy {
+	p := RetryPolicy{
+		Attempts:  cfg.RetryAttempts,
+		BaseDelay: cfg.RetryBaseDelay,
+		MaxDelay:  cfg.RetryMaxDelay,
+	}
+	if p.Attempts < 1 {
+		p.Attempts = 1
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryBaseDelay
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	return p
+}
+
+// Backoff waits before retry number attempt (1 for the first retry): a random
+// time up to BaseDelay doubled per earlier retry, capped at MaxDelay. The
+// jitter keeps clients that failed together from retrying together. It
+// returns ctx's error if ctx ends first.
+func (p RetryPolicy) Backoff(ctx context.Context, attempt int) error {
+	ceiling := p.MaxDelay
+	if shift := attempt - 1; shift < 30 && p.BaseDelay<<shift < ceiling {
+		ceiling = p.BaseDelay << shift
+	}
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(ceiling) + 1)))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Retryable reports whether err is a failure that trying again can fix: a
+// serialization failure or deadlock, which roll the transaction back, or a
+// lost connection. Whether the statement itself is safe to run again is the
+// caller's call.
+func Retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "40001", pgErr.Code == "40P01":
+			return true
+		case strings.HasPrefix(pgErr.Code, "08"):
+			// Connection exceptions.
+			return true
+		case pgErr.Code == "57P01", pgErr.Code == "57P02", pgErr.Code == "57P03":
+			// The server is shutting down or not accepting connections yet.
+			return true
+		}
+		return false
+	}
+	return sentNothing(err) || connectionLost(err)
+}
+
+// sentNothing reports whether err happened before the statement reached the
+// server, so it cannot have run: no connection could be made, or pgx failed
+// before writing.
+func sentNothing(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var safe interface{ SafeToRetry() bool }
+	return errors.As(err, &safe) && safe.SafeToRetry()
+}
+
+
+func connectionLost(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return false
+	}
+	msg := err.Error()
+	for _, s := range []string{"connection reset", "broken pipe", "unexpected EOF", "conn closed", "connection refused"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// rolledBack reports whether err means the server undid the statement, so
+// running it again cannot apply it twice.
+func rolledBack(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && Retryable(err)
+}
+
+// retryPool retries statements run outside a transaction that fail
+// transiently. Reads are retried after any retryable error. A write is only
+// retried when it never reached the server or the server rolled it back; a
+// connection lost after sending it leaves unknown whether it committed.
+// Statements in a transaction go through the *sql.Tx BeginTx returns and are
+// never retried one by one; repositories retry whole units of work instead.
+type retryPool struct {
+	db      *sql.DB
+	policy  RetryPolicy
+	retries uint64
+}
+
+
+func newRetryPool(db *sql.DB, policy RetryPolicy) *retryPool {
+	return &retryPool{db: db, policy: policy}
+}
+
+
+func (p *retryPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.db.PrepareContext(ctx, query)
+}
+
+
+func (p *retryPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var res sql.Result
+	err := p.do(ctx, query, func() error {
+		var err error
+		res, err = p.db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+
+func (p *retryPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := p.do(ctx, query, func() error {
+		var err error
+		rows, err = p.db.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// QueryRowContext defers its error to Scan, past where it could be retried.
+func (p *retryPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.db.QueryRowContext(ctx, query, args...)
+}
+
+
+func (p *retryPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	var tx *sql.Tx
+	err := p.do(ctx, "BEGIN", func() error {
+		var err error
+		tx, err = p.db.BeginTx(ctx, opts)
+		return err
+	})
+	return tx, err
+}
+
+// GetDBConn lets gorm's DB() reach the pool for stats, pings and raw
+// connections.
+func (p *retryPool) GetDBConn() (*sql.DB, error) {
+	return p.db, nil
+}
+
+
+func (p *retryPool) Ping() error {
+	return p.db.Ping()
+}
+
+
+func (p *retryPool) do(ctx context.Context, query string, op func() error) error {
+	read := isRead(query)
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= p.policy.Attempts || !Retryable(err) {
+			return err
+		}
+		if !read && !sentNothing(err) && !rolledBack(err) {
+			return err
+		}
+		atomic.AddUint64(&p.retries, 1)
+		if werr := p.policy.Backoff(ctx, attempt); werr != nil {
+			return err
+		}
+	}
+}
+
+// isRead reports whether query only reads. BEGIN counts: it changes nothing
+// until later statements do.
+func isRead(query string) bool {
+	q := strings.ToUpper(strings.TrimSpace(query))
+	return q == "BEGIN" || (strings.HasPrefix(q, "SELECT") && !strings.Contains(q, "FOR UPDATE"))
+}
+
+// RetryPolicyOf returns the policy db retries with. A db opened elsewhere
+// does not retry.
+func RetryPolicyOf(db *gorm.DB) RetryPolicy {
+	if p, ok := db.Config.ConnPool.(*retryPool); ok {
+		return p.policy
+	}
+	return RetryPolicy{Attempts: 1}
+}
+
+
+func retryCount(db *gorm.DB) uint64 {
+	if p, ok := db.Config.ConnPool.(*retryPool); ok {
+		return atomic.LoadUint64(&p.retries)
+	}
+	return 0
+}
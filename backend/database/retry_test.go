@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type safeToRetryError struct{}
+
+func (safeToRetryError) Error() string     { return "pgx failed before sending" }
+func (safeToRetryError) SafeToRetry() bool { return true }
+
+func TestRetryable(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, true},
+		{"wrapped serialization failure", fmt.Errorf("update rating: %w", &pgconn.PgError{Code: "40001"}), true},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"cannot connect now", &pgconn.PgError{Code: "57P03"}, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"query canceled", &pgconn.PgError{Code: "57014"}, false},
+		{"dial failure", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, true},
+		{"not sent", safeToRetryError{}, true},
+		{"connection reset", &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, true},
+		{"unexpected EOF", errors.New("unexpected EOF"), true},
+		{"canceled", fmt.Errorf("conn closed: %w", context.Canceled), false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"no rows", sql.ErrNoRows, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Retryable(tc.err); got != tc.want {
+				t.Fatalf("Retryable(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+// Reads are retried after any retryable error; writes only when they cannot
+// have applied.
+func TestRetryPoolRetries(t *testing.T) {
+	reset := &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+	cases := []struct {
+		name  string
+		query string
+		err   error
+		tries int
+	}{
+		{"read after a lost connection", "SELECT 1", reset, 3},
+		{"write after a lost connection", "UPDATE users SET rating = 1", reset, 1},
+		{"locking read after a lost connection", "SELECT * FROM users FOR UPDATE", reset, 1},
+		{"write that was never sent", "UPDATE users SET rating = 1", safeToRetryError{}, 3},
+		{"write the server rolled back", "UPDATE users SET rating = 1", &pgconn.PgError{Code: "40P01"}, 3},
+		{"begin after a lost connection", "BEGIN", reset, 3},
+		{"read that cannot succeed", "SELECT 1", &pgconn.PgError{Code: "42P01"}, 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newRetryPool(nil, RetryPolicy{Attempts: 3, BaseDelay: time.Microsecond, MaxDelay: time.Microsecond})
+			tries := 0
+			err := p.do(context.Background(), tc.query, func() error {
+				tries++
+				return tc.err
+			})
+			if err != tc.err {
+				t.Fatalf("do = %v, want %v", err, tc.err)
+			}
+			if tries != tc.tries {
+				t.Fatalf("tried %d times, want %d", tries, tc.tries)
+			}
+		})
+	}
+}
@@ -15,10 +15,15 @@ import (
 	"leaderboard-system/cache"
 	"leaderboard-system/config"
 	"leaderboard-system/database"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
 	"leaderboard-system/routes"
+	"leaderboard-system/tenant"
 	"gorm.io/gorm/logger"
 )
 
+//go:generate go run . migrate schema -o ../DATABASE_SCHEMA.sql
+
 func main() {
 	 
 	_ = godotenv.Load()
@@ -39,6 +44,13 @@ func main() {
 	}
 	defer log.Sync()
 
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, log, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal("Command failed", zap.String("command", os.Args[1]), zap.Error(err))
+		}
+		return
+	}
+
 	log.Info("Starting leaderboard service",
 		zap.String("environment", cfg.Server.Env),
 		zap.String("port", cfg.Server.Port),
@@ -57,23 +69,47 @@ func main() {
 
 	log.Info("Database connected")
 
-	 
-	if cfg.Server.Env != "production" {
-		if err := database.SeedData(db); err != nil {
-			log.Warn("Failed to seed data", zap.Error(err))
-		} else {
-			log.Info("Database seeded with initial data")
-		}
+	replicas, err := database.OpenReplicas(db, &cfg.Database, logLevel)
+	if err != nil {
+		log.Fatal("Failed to connect to read replicas", zap.Error(err))
+	}
+	defer replicas.Close()
+
+	if len(cfg.Database.ReplicaURLs) > 0 {
+		log.Info("Read replicas connected", zap.Int("count", len(cfg.Database.ReplicaURLs)))
 	}
 
  
-	cacheManager, err := cache.NewCacheManager(&cfg.Redis)
+	cacheStore, err := cache.NewCache(&cfg.Cache, &cfg.Redis)
 	if err != nil {
 		log.Fatal("Failed to initialize cache", zap.Error(err))
 	}
-	defer cacheManager.Close()
+	defer cacheStore.Close()
 
-	log.Info("Cache connected")
+	log.Info("Cache connected", zap.String("backend", cfg.Cache.Backend))
+
+	if cfg.Seed.OnStart {
+		if err := seedOnStart(db, cacheStore, cfg, log); err != nil {
+			log.Warn("Failed to seed data", zap.Error(err))
+		}
+	}
+
+	// Warm the cache before taking traffic so a restarted Redis does not send
+	// the first requests straight to Postgres. Every tenant shares the one
+	// warm-up timeout.
+	warmCtx, cancelWarm := context.WithTimeout(context.Background(), cfg.Cache.WarmupTimeout)
+	tenants, err := repository.NewTenantRepository(db).ListTenants(warmCtx)
+	if err != nil {
+		log.Warn("Failed to list tenants to warm", zap.Error(err))
+		tenants = []models.Tenant{{ID: tenant.Default}}
+	}
+	warmer := newCacheWarmer(db, replicas, cacheStore, cfg, log)
+	for _, t := range tenants {
+		if err := warmer.WarmUp(tenant.WithID(warmCtx, t.ID)); err != nil {
+			log.Warn("Cache warm-up incomplete", zap.String("tenant_id", t.ID), zap.Error(err))
+		}
+	}
+	cancelWarm()
 
  
 	if cfg.Server.Env == "production" {
@@ -82,8 +118,11 @@ func main() {
 
 	router := gin.New()
 
+	workers, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
  
-	routes.SetupRoutes(router, db, cacheManager, log)
+	routes.SetupRoutes(workers, router, db, replicas, cacheStore, cfg, log)
 
  
 	server := &http.Server{
@@ -116,6 +155,7 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatal("Server shutdown error", zap.Error(err))
 	}
+	stopWorkers()
 
 	log.Info("Server stopped")
 }
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"leaderboard-system/service"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+
+	// problemTypePrefix prefixes the error code to make the problem type URI.
+	problemTypePrefix = "urn:leaderboard:error:"
+
+	// StatusClientClosedRequest is the status, borrowed from nginx, of a
+	// request its client disconnected from. No one reads the response; it
+	// keeps such requests out of 5xx counts.
+	StatusClientClosedRequest = 499
+)
+
+// ErrorMiddleware turns the last error a handler added with c.Error into an
+// RFC 9457 problem+json response, unless the handler already wrote one:
+//
+//	{"type": "urn:leaderboard:error:USER_NOT_FOUND", "title": "Not Found",
+//	 "status": 404, "detail": "user not found", "instance": "/api/v1/users/42",
+//	 "code": "USER_NOT_FOUND"}
+//
+// The status follows the error's kind, and code is what clients switch on.
+// Validation errors list the bad fields under "errors", and a gin.H set as
+// the error's meta adds members of its own. Errors the service does not know
+// are 500s, or 503s when they look transient, and their cause is logged,
+// never sent. A request its client disconnected from is a 499 and not
+// logged as a failure.
+func ErrorMiddleware(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		last := c.Errors.Last()
+		if last == nil || c.Writer.Written() {
+			return
+		}
+
+		e := service.AsError(last.Err)
+		status := problemStatus(e)
+		detail := last.Err.Error()
+		switch status {
+		case http.StatusInternalServerError:
+			detail = e.Message
+			logger.Error("Request failed",
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.String("code", e.Code),
+				zap.Error(last.Err),
+			)
+		case http.StatusServiceUnavailable:
+			detail = e.Message
+			logger.Warn("Request failed on an unavailable dependency",
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.String("code", e.Code),
+				zap.Error(last.Err),
+			)
+		}
+
+		title := http.StatusText(status)
+		if status == StatusClientClosedRequest {
+			title = "Client Closed Request"
+		}
+		problem := gin.H{
+			"type":     problemTypePrefix + e.Code,
+			"title":    title,
+			"status":   status,
+			"detail":   detail,
+			"instance": c.Request.URL.Path,
+			"code":     e.Code,
+		}
+		if len(e.Fields) > 0 {
+			problem["errors"] = e.Fields
+		}
+		if meta, ok := last.Meta.(gin.H); ok {
+			for k, v := range meta {
+				if _, taken := problem[k]; !taken {
+					problem[k] = v
+				}
+			}
+		}
+
+		body, err := json.Marshal(problem)
+		if err != nil {
+			logger.Error("Failed to encode problem", zap.Error(err))
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Data(status, ProblemContentType, body)
+	}
+}
+
+// NoRouteHandler answers requests no route matches with a ROUTE_NOT_FOUND
+// problem.
+func NoRouteHandler(c *gin.Context) {
+	c.Error(service.NewError(service.ErrNotFound, "ROUTE_NOT_FOUND", "No route matches "+c.Request.Method+" "+c.Request.URL.Path))
+}
+
+
+func problemStatus(e *service.Error) int {
+	switch {
+	case errors.Is(e.Kind, service.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(e.Kind, service.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(e.Kind, service.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(e.Kind, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(e.Kind, service.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(e.Kind, service.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(e.Kind, service.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(e.Kind, service.ErrCanceled):
+		return StatusClientClosedRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"leaderboard-system/cache"
+	"leaderboard-system/service"
+)
+
+// Every kind of error maps to its status and code, and causes the client
+// cannot act on are logged rather than sent.
+func TestErrorMiddlewareStatus(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+		logged string // the level, if logged
+	}{
+		{name: "validation", err: service.NewValidationError("INVALID_RATING", "invalid rating", service.FieldError{Field: "rating", Message: "too high"}),
+			status: http.StatusBadRequest, code: "INVALID_RATING", detail: "invalid rating"},
+		{name: "not found", err: service.ErrUserNotFound, status: http.StatusNotFound, code: "USER_NOT_FOUND", detail: "user not found"},
+		{name: "wrapped conflict keeps its code", err: fmt.Errorf("%w: alice", service.ErrUserExists),
+			status: http.StatusConflict, code: "USER_EXISTS", detail: "user already exists: alice"},
+		{name: "unauthorized", err: service.NewError(service.ErrUnauthorized, "NO_TENANT", "missing tenant"), status: http.StatusUnauthorized, code: "NO_TENANT", detail: "missing tenant"},
+		{name: "forbidden", err: service.NewError(service.ErrForbidden, "NOT_ADMIN", "admins only"), status: http.StatusForbidden, code: "NOT_ADMIN", detail: "admins only"},
+		{name: "rate limited", err: service.NewError(service.ErrRateLimited, "RATE_LIMITED", "slow down"), status: http.StatusTooManyRequests, code: "RATE_LIMITED", detail: "slow down"},
+		{name: "deadlock", err: fmt.Errorf("update rating: %w", &pgconn.PgError{Code: "40P01"}),
+			status: http.StatusServiceUnavailable, code: "UNAVAILABLE", detail: "service temporarily unavailable, try again", logged: "warn"},
+		{name: "open cache breaker", err: cache.ErrCircuitOpen,
+			status: http.StatusServiceUnavailable, code: "UNAVAILABLE", detail: "service temporarily unavailable, try again", logged: "warn"},
+		{name: "deadline", err: context.DeadlineExceeded,
+			status: http.StatusServiceUnavailable, code: "UNAVAILABLE", detail: "service temporarily unavailable, try again", logged: "warn"},
+		{name: "client gone", err: fmt.Errorf("load page: %w", context.Canceled),
+			status: StatusClientClosedRequest, code: "REQUEST_CANCELED", detail: "load page: context canceled"},
+		{name: "unknown", err: errors.New("pq: relation \"users\" does not exist"),
+			status: http.StatusInternalServerError, code: "INTERNAL", detail: "internal error", logged: "error"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			core, logs := observer.New(zap.InfoLevel)
+			router := gin.New()
+			router.Use(ErrorMiddleware(zap.New(core)))
+			router.GET("/users/42", func(c *gin.Context) { c.Error(tc.err) })
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d", w.Code, tc.status)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+				t.Fatalf("content type = %q, want %q", ct, ProblemContentType)
+			}
+			var problem struct {
+				Type     string               `json:"type"`
+				Status   int                  `json:"status"`
+				Detail   string               `json:"detail"`
+				Instance string               `json:"instance"`
+				Code     string               `json:"code"`
+				Errors   []service.FieldError `json:"errors"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Code != tc.code || problem.Type != problemTypePrefix+tc.code || problem.Status != tc.status {
+				t.Fatalf("problem = %+v, want code %s and status %d", problem, tc.code, tc.status)
+			}
+			if problem.Detail != tc.detail || problem.Instance != "/users/42" {
+				t.Fatalf("detail %q at %q, want %q at /users/42", problem.Detail, problem.Instance, tc.detail)
+			}
+			var fields *service.Error
+			if errors.As(tc.err, &fields) && len(problem.Errors) != len(fields.Fields) {
+				t.Fatalf("errors = %+v, want %+v", problem.Errors, fields.Fields)
+			}
+
+			entries := logs.All()
+			if tc.logged == "" {
+				if len(entries) != 0 {
+					t.Fatalf("logged %+v, want nothing", entries)
+				}
+				return
+			}
+			if len(entries) != 1 || entries[0].Level.String() != tc.logged {
+				t.Fatalf("logged %+v, want one entry at %s", entries, tc.logged)
+			}
+		})
+	}
+}
@@ -1,12 +1,15 @@
 package middleware
 
 import (
-	"net/http"
+	"crypto/subtle"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"leaderboard-system/models"
+	"leaderboard-system/service"
+	"leaderboard-system/tenant"
 )
 
  
@@ -15,9 +18,17 @@ type RateLimiter struct {
 	maxTokens   float64
 	refillRate  float64  
 	lastRefill  map[string]time.Time
+	// lastSweep is when buckets back at full were last dropped.
+	lastSweep   time.Time
+	now         func() time.Time
 	mu          sync.RWMutex
 }
 
+// LimiterSweepInterval is how often a RateLimiter drops the buckets of
+// clients idle long enough to have refilled. Such a client starts over with
+// a full bucket anyway, so dropping it changes nothing but memory.
+const LimiterSweepInterval = time.Minute
+
  
 func NewRateLimiter(maxTokens float64, requestsPerSecond float64) *RateLimiter {
 	return &RateLimiter{
@@ -25,6 +36,8 @@ func NewRateLimiter(maxTokens float64, requestsPerSecond float64) *RateLimiter {
 		maxTokens:  maxTokens,
 		refillRate: requestsPerSecond,
 		lastRefill: make(map[string]time.Time),
+		lastSweep:  time.Now(),
+		now:        time.Now,
 	}
 }
 
@@ -33,7 +46,10 @@ func (rl *RateLimiter) Allow(clientID string) bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	now := time.Now()
+	now := rl.now()
+	if now.Sub(rl.lastSweep) >= LimiterSweepInterval {
+		rl.sweep(now)
+	}
 
 	 
 	lastRefill, exists := rl.lastRefill[clientID]
@@ -56,24 +72,35 @@ func (rl *RateLimiter) Allow(clientID string) bool {
 	return false
 }
 
- 
-func RateLimitMiddleware() gin.HandlerFunc {
-	limiter := NewRateLimiter(200, 100)  
-	logger, _ := zap.NewDevelopment()
+// sweep drops every bucket that has refilled by now. Must be called with mu
+// held.
+func (rl *RateLimiter) sweep(now time.Time) {
+	rl.lastSweep = now
+	for clientID, last := range rl.lastRefill {
+		if rl.tokens[clientID]+now.Sub(last).Seconds()*rl.refillRate >= rl.maxTokens {
+			delete(rl.tokens, clientID)
+			delete(rl.lastRefill, clientID)
+		}
+	}
+}
+
+// prune sweeps the limiter now and returns how many buckets are left.
+func (rl *RateLimiter) prune() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.sweep(rl.now())
+	return len(rl.lastRefill)
+}
+
+// IPRateLimitMiddleware limits each client IP across every route, before any
+// other work is done for the request, so neither the admin token nor the
+// tenant lookup can be hammered. Tenant limits apply on top of it.
+func IPRateLimitMiddleware(requestsPerSecond float64, burst int, logger *zap.Logger) gin.HandlerFunc {
+	limiter := NewRateLimiter(float64(burst), requestsPerSecond)
 
 	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
-
-		if !limiter.Allow(clientIP) {
-			logger.Warn("Rate limit exceeded",
-				zap.String("client_ip", clientIP),
-				zap.String("path", c.Request.URL.Path),
-			)
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "RATE_LIMITED",
-				"message": "Too many requests, please try again later",
-			})
-			c.Abort()
+		if !limiter.Allow(c.ClientIP()) {
+			rateLimited(c, logger)
 			return
 		}
 
@@ -81,6 +108,82 @@ func RateLimitMiddleware() gin.HandlerFunc {
 	}
 }
 
+// RateLimitMiddleware limits each client IP to the rate and burst of the
+// tenant TenantMiddleware resolved, counting each tenant separately. Routes
+// without a tenant get the default limit.
+func RateLimitMiddleware(logger *zap.Logger) gin.HandlerFunc {
+	limiters := newTenantLimiters()
+
+	return func(c *gin.Context) {
+		if !limiters.limiterFor(TenantFromContext(c)).Allow(c.ClientIP()) {
+			rateLimited(c, logger)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// tenantLimiters holds one RateLimiter per tenant. Every sweep interval each
+// limiter is swept, including those of tenants no longer sending requests,
+// and a limiter left without buckets is dropped, so idle or deleted tenants
+// do not stay in memory.
+type tenantLimiters struct {
+	mu        sync.Mutex
+	limiters  map[string]*RateLimiter
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+
+func newTenantLimiters() *tenantLimiters {
+	return &tenantLimiters{
+		limiters:  make(map[string]*RateLimiter),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+
+func (tl *tenantLimiters) limiterFor(t *models.Tenant) *RateLimiter {
+	id, burst, rps := "", float64(service.DefaultTenantBurst), float64(service.DefaultTenantRPS)
+	if t != nil {
+		id, burst, rps = t.ID, float64(t.RateLimitBurst), t.RateLimitRPS
+	}
+
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	if now := tl.now(); now.Sub(tl.lastSweep) >= LimiterSweepInterval {
+		tl.lastSweep = now
+		for other, limiter := range tl.limiters {
+			if limiter.prune() == 0 {
+				delete(tl.limiters, other)
+			}
+		}
+	}
+
+	// A tenant whose limit changed starts over with full buckets.
+	limiter, ok := tl.limiters[id]
+	if !ok || limiter.maxTokens != burst || limiter.refillRate != rps {
+		limiter = NewRateLimiter(burst, rps)
+		limiter.now = tl.now
+		tl.limiters[id] = limiter
+	}
+	return limiter
+}
+
+
+func rateLimited(c *gin.Context, logger *zap.Logger) {
+	logger.Warn("Rate limit exceeded",
+		zap.String("tenant_id", tenant.ID(c.Request.Context())),
+		zap.String("client_ip", c.ClientIP()),
+		zap.String("path", c.Request.URL.Path),
+	)
+	c.Header("Retry-After", "1")
+	c.Error(service.NewError(service.ErrRateLimited, "RATE_LIMITED", "Too many requests, please try again later"))
+	c.Abort()
+}
+
  
 func LoggingMiddleware(logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -104,8 +207,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-API-Key, X-Tenant-ID")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -116,6 +219,21 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
+// AdminAuthMiddleware guards admin routes with a shared token sent in the
+// X-Admin-Token header. With no token configured admin routes are disabled.
+func AdminAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader("X-Admin-Token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.Error(service.NewError(service.ErrForbidden, "FORBIDDEN", "Admin token required"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
  
 func min(a, b float64) float64 {
 	if a < b {
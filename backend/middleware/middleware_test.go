@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"leaderboard-system/models"
+)
+
+// fakeClock is a time source tests move by hand.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func clients(rl *RateLimiter) []string {
+	var ids []string
+	for id := range rl.lastRefill {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// A sweep drops only buckets that have refilled, and a kept bucket goes on
+// limiting as before.
+func TestRateLimiterSweepsRefilledBuckets(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	rl := NewRateLimiter(2, 1.0/60)
+	rl.now, rl.lastSweep = clock.now, clock.t
+
+	rl.Allow("drained")
+	rl.Allow("drained")
+	rl.Allow("idle")
+
+	clock.t = clock.t.Add(LimiterSweepInterval + time.Second)
+	rl.Allow("new")
+
+	if got, want := clients(rl), []string{"drained", "new"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("clients after sweep = %v, want %v", got, want)
+	}
+	if !rl.Allow("drained") || rl.Allow("drained") {
+		t.Fatal("drained client did not keep its partly refilled bucket")
+	}
+}
+
+// Each tenant counts a client separately, and tenants that stop sending
+// requests are dropped once their buckets refill.
+func TestTenantLimiters(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	tl := newTenantLimiters()
+	tl.now, tl.lastSweep = clock.now, clock.t
+
+	chess := &models.Tenant{ID: "chess", RateLimitBurst: 1, RateLimitRPS: 1}
+	golf := &models.Tenant{ID: "golf", RateLimitBurst: 1, RateLimitRPS: 1}
+	if !tl.limiterFor(chess).Allow("1.2.3.4") || tl.limiterFor(chess).Allow("1.2.3.4") {
+		t.Fatal("chess allowed more than its burst")
+	}
+	if !tl.limiterFor(golf).Allow("1.2.3.4") {
+		t.Fatal("golf was limited by requests made to chess")
+	}
+
+	clock.t = clock.t.Add(LimiterSweepInterval)
+	tl.limiterFor(golf)
+	if len(tl.limiters) != 1 || tl.limiters["golf"] == nil {
+		t.Fatalf("limiters after sweep = %v, want only golf", tl.limiters)
+	}
+}
+
+// Rejections are logged through the logger the middleware is given.
+func TestIPRateLimitMiddlewareLogsToInjectedLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.WarnLevel)
+	router := gin.New()
+	router.Use(IPRateLimitMiddleware(1, 1, zap.New(core)))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := 0; i < 3; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if n := logs.FilterMessage("Rate limit exceeded").Len(); n != 2 {
+		t.Fatalf("logged %d rejections, want 2", n)
+	}
+}
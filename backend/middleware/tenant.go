@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"leaderboard-system/models"
+	"leaderboard-system/service"
+	"leaderboard-system/tenant"
+)
+
+const (
+	APIKeyHeader   = "X-API-Key"
+	TenantIDHeader = "X-Tenant-ID"
+
+	tenantContextKey = "tenant"
+)
+
+// TenantMiddleware resolves the tenant a request acts for and puts it in the
+// request context, where repositories and the cache scope by it. An X-API-Key
+// names its tenant; otherwise X-Tenant-ID does, or the default tenant when
+// that is missing too. A tenant with an API key can only be named by the
+// header when trustHeader is set, as it is behind the admin token.
+func TenantMiddleware(tenants *service.TenantService, trustHeader bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		key := c.GetHeader(APIKeyHeader)
+		id := c.GetHeader(TenantIDHeader)
+
+		var (
+			t   *models.Tenant
+			err error
+		)
+		if key != "" {
+			t, err = tenants.ResolveAPIKey(ctx, key)
+			if err == nil && id != "" && id != t.ID {
+				abortTenant(c, service.NewError(service.ErrForbidden, "TENANT_MISMATCH", "X-Tenant-ID does not match the API key"))
+				return
+			}
+		} else {
+			if id == "" {
+				id = tenant.Default
+			}
+			if !tenant.ValidID(id) {
+				abortTenant(c, service.NewValidationError("INVALID_TENANT", "X-Tenant-ID is not a valid tenant ID",
+					service.FieldError{Field: TenantIDHeader, Message: "is not a valid tenant ID"}))
+				return
+			}
+			t, err = tenants.ResolveTenant(ctx, id)
+			if err == nil && t.APIKeyHash != nil && !trustHeader {
+				abortTenant(c, service.NewError(service.ErrUnauthorized, "API_KEY_REQUIRED", "This tenant requires an API key"))
+				return
+			}
+		}
+
+		switch {
+		case errors.Is(err, service.ErrInvalidAPIKey):
+			abortTenant(c, service.NewError(service.ErrUnauthorized, "INVALID_API_KEY", "API key is not valid"))
+			return
+		case errors.Is(err, service.ErrTenantNotFound):
+			abortTenant(c, service.NewError(service.ErrNotFound, "UNKNOWN_TENANT", "Tenant not found"))
+			return
+		case err != nil:
+			abortTenant(c, service.NewError(service.ErrUnavailable, "TENANT_LOOKUP_FAILED", "Failed to resolve tenant").WithCause(err))
+			return
+		}
+
+		c.Set(tenantContextKey, t)
+		c.Request = c.Request.WithContext(tenant.WithID(ctx, t.ID))
+		c.Next()
+	}
+}
+
+// TenantFromContext returns the tenant TenantMiddleware resolved, or nil on
+// routes it does not guard.
+func TenantFromContext(c *gin.Context) *models.Tenant {
+	if v, ok := c.Get(tenantContextKey); ok {
+		if t, ok := v.(*models.Tenant); ok {
+			return t
+		}
+	}
+	return nil
+}
+
+
+func abortTenant(c *gin.Context, err *service.Error) {
+	c.Error(err)
+	c.Abort()
+}
@@ -1,18 +1,49 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
-	
+	"gorm.io/gorm"
 )
 
+// Tenant is one game hosted on the service, with its own users, board,
+// rate limit and cache keys. Requests name it with an API key or, when it
+// has none, the X-Tenant-ID header.
+type Tenant struct {
+	ID   string `gorm:"primaryKey;column:id;type:varchar(64)" json:"id"`
+	Name string `gorm:"column:name;type:varchar(255);not null" json:"name"`
+	// APIKeyHash is the SHA-256 of the tenant's API key; the key itself is
+	// only shown when it is issued. APIKeyPrefix identifies it in listings.
+	APIKeyHash     *string   `gorm:"column:api_key_hash;type:varchar(64)" json:"-"`
+	APIKeyPrefix   string    `gorm:"column:api_key_prefix;type:varchar(16);not null" json:"api_key_prefix,omitempty"`
+	RateLimitRPS   float64   `gorm:"column:rate_limit_rps;not null" json:"rate_limit_rps"`
+	RateLimitBurst int       `gorm:"column:rate_limit_burst;not null" json:"rate_limit_burst"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
  
 type User struct {
+	TenantID  string    `gorm:"primaryKey;column:tenant_id;type:varchar(64)" json:"tenant_id"`
 	ID        string    `gorm:"primaryKey;column:id" json:"id"`
 	Username  string    `gorm:"column:username;uniqueIndex:idx_users_username;type:varchar(255)" json:"username"`
 	Rating    int32     `gorm:"column:rating;index:idx_users_rating" json:"rating"` // Range: 100-5000
+	Metadata  UserMetadata `gorm:"column:metadata;type:jsonb;not null;default:'{}'" json:"metadata,omitempty"`
 	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	// DeletedAt is set while the user is deactivated. GORM then leaves the
+	// row out of every query that does not ask for it with Unscoped.
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
+	// ErasedAt is set once the user's personal data has been erased.
+	ErasedAt *time.Time `gorm:"column:erased_at" json:"-"`
 }
 
  
@@ -21,11 +52,69 @@ func (User) TableName() string {
 }
 
 
+// UserMetadata is free-form data a client keeps on a user, stored as a JSON
+// object.
+type UserMetadata map[string]interface{}
+
+
+func (m UserMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+
+func (m *UserMetadata) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into UserMetadata", src)
+	}
+	meta := UserMetadata{}
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return err
+	}
+	if len(meta) == 0 {
+		meta = nil
+	}
+	*m = meta
+	return nil
+}
+
+// Clone returns a copy that can be changed without changing m. Nested values
+// are shared.
+func (m UserMetadata) Clone() UserMetadata {
+	if m == nil {
+		return nil
+	}
+	out := make(UserMetadata, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+
 type UserDTO struct {
 	ID       string `json:"id"`
 	Username string `json:"username"`
 	Rating   int32  `json:"rating"`
 	Rank     int64  `json:"rank"` 
+	Metadata UserMetadata `json:"metadata,omitempty"`
+	// RanksAsOf is when the rank served with the user was current.
+	RanksAsOf *time.Time `json:"ranks_as_of,omitempty"`
 }
 
 
@@ -50,6 +139,10 @@ type LeaderboardResponse struct {
 	Page       int                `json:"page"`
 	PageSize   int                `json:"page_size"`
 	HasMore    bool               `json:"has_more"`
+	Version    int64              `json:"version"`
+	// RanksAsOf is when the ranks on the page were current. Pages served
+	// from the materialized rank table trail rating changes slightly.
+	RanksAsOf time.Time `json:"ranks_as_of"`
 }
 
 
@@ -60,3 +153,191 @@ type RankUpdateEvent struct {
 	NewRank  int64     `json:"new_rank"`
 	Timestamp time.Time `json:"timestamp"`
 }
+
+
+type RatingChange struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	OldRating int32     `json:"old_rating"`
+	NewRating int32     `json:"new_rating"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+
+type WatchType string
+
+const (
+	WatchTypeRival         WatchType = "rival"
+	WatchTypeRankThreshold WatchType = "rank_threshold"
+)
+
+
+type Watch struct {
+	ID        string    `gorm:"primaryKey;column:id" json:"id"`
+	TenantID  string    `gorm:"column:tenant_id;type:varchar(64);not null" json:"-"`
+	WatcherID string    `gorm:"column:watcher_id;index:idx_watches_watcher;type:varchar(255);not null" json:"watcher_id"`
+	Type      WatchType `gorm:"column:type;type:varchar(32);not null" json:"type"`
+	TargetID  string    `gorm:"column:target_id;index:idx_watches_target;type:varchar(255)" json:"target_id,omitempty"`
+	Threshold int64     `gorm:"column:threshold" json:"threshold,omitempty"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+
+func (Watch) TableName() string {
+	return "watches"
+}
+
+
+type NotificationKind string
+
+const (
+	NotificationOvertaken         NotificationKind = "overtaken"
+	NotificationLeftRankThreshold NotificationKind = "left_rank_threshold"
+)
+
+
+type Notification struct {
+	TenantID    string           `json:"tenant_id"`
+	WatchID     string           `json:"watch_id"`
+	RecipientID string           `json:"recipient_id"`
+	Kind        NotificationKind `json:"kind"`
+	ActorID     string           `json:"actor_id,omitempty"`
+	Rank        int64            `json:"rank,omitempty"`
+	Threshold   int64            `json:"threshold,omitempty"`
+	Timestamp   time.Time        `json:"timestamp"`
+}
+
+
+type LeaderboardChange struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement;column:version" json:"version"`
+	TenantID  string    `gorm:"column:tenant_id;type:varchar(64);not null" json:"-"`
+	UserID    string    `gorm:"column:user_id;type:varchar(255);not null" json:"user_id"`
+	OldRating int32     `gorm:"column:old_rating;not null" json:"old_rating"`
+	NewRating int32     `gorm:"column:new_rating;not null" json:"new_rating"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+
+func (LeaderboardChange) TableName() string {
+	return "leaderboard_changes"
+}
+
+
+type LeaderboardChangesResponse struct {
+	Since       int64              `json:"since"`
+	Version     int64              `json:"version"`
+	FullRefetch bool               `json:"full_refetch"`
+	Changes     []LeaderboardEntry `json:"changes"`
+}
+
+
+type CacheRebuildState string
+
+const (
+	CacheRebuildIdle      CacheRebuildState = "idle"
+	CacheRebuildRunning   CacheRebuildState = "running"
+	CacheRebuildCompleted CacheRebuildState = "completed"
+	CacheRebuildFailed    CacheRebuildState = "failed"
+)
+
+// CacheRebuildStatus reports progress of a cache rebuild. Processed counts
+// users whose cached user, rank and leaderboard rows have been rewritten.
+type CacheRebuildStatus struct {
+	State      CacheRebuildState `json:"state"`
+	Tenant     string            `json:"tenant,omitempty"`
+	Processed  int64             `json:"processed"`
+	Total      int64             `json:"total"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+
+type UserAuditAction string
+
+const (
+	UserAuditDeactivated UserAuditAction = "deactivated"
+	UserAuditReactivated UserAuditAction = "reactivated"
+	UserAuditErased      UserAuditAction = "erased"
+)
+
+// UserAuditEntry records one deactivation, reactivation or erasure. It holds
+// no username so it can outlive an erasure.
+type UserAuditEntry struct {
+	ID        int64           `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	TenantID  string          `gorm:"column:tenant_id;type:varchar(64);not null" json:"-"`
+	UserID    string          `gorm:"column:user_id;type:varchar(255);not null" json:"user_id"`
+	Action    UserAuditAction `gorm:"column:action;type:varchar(32);not null" json:"action"`
+	Actor     string          `gorm:"column:actor;type:varchar(255);not null" json:"actor"`
+	Reason    string          `gorm:"column:reason;not null" json:"reason,omitempty"`
+	CreatedAt time.Time       `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+
+func (UserAuditEntry) TableName() string {
+	return "user_audit_log"
+}
+
+
+// ImportReport summarizes a bulk user import. Rows are inserted, updated,
+// left unchanged because they already matched, or rejected; Errors lists the
+// rejected rows, up to a limit. IndexRebuildStarted is set when the import
+// changed enough rows to rebuild the rank indexes, in the background.
+type ImportReport struct {
+	Format              string           `json:"format"`
+	Processed           int64            `json:"processed"`
+	Inserted            int64            `json:"inserted"`
+	Updated             int64            `json:"updated"`
+	Unchanged           int64            `json:"unchanged"`
+	Rejected            int64            `json:"rejected"`
+	Errors              []ImportRowError `json:"errors"`
+	ErrorsTruncated     bool             `json:"errors_truncated"`
+	IndexRebuildStarted bool             `json:"index_rebuild_started"`
+	StartedAt           time.Time        `json:"started_at"`
+	FinishedAt          time.Time        `json:"finished_at"`
+}
+
+
+type ImportRowError struct {
+	Line     int64  `json:"line"`
+	UserID   string `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Error    string `json:"error"`
+}
+
+
+// UserSearchHit is one username search result. Highlight is the username with
+// the matched part wrapped in <mark></mark>.
+type UserSearchHit struct {
+	ID        string  `json:"id"`
+	Username  string  `json:"username"`
+	Rating    int32   `json:"rating"`
+	Rank      int64   `json:"rank"`
+	Score     float64 `json:"score"`
+	Highlight string  `json:"highlight"`
+}
+
+
+// UserListResponse is one page of GET /users.
+type UserListResponse struct {
+	Users    []UserDTO `json:"users"`
+	Total    int64     `json:"total"`
+	Page     int       `json:"page"`
+	PageSize int       `json:"page_size"`
+	HasMore  bool      `json:"has_more"`
+	Sort     string    `json:"sort"`
+	Order    string    `json:"order"`
+}
+
+
+type UserSearchResponse struct {
+	Query    string          `json:"query"`
+	Mode     string          `json:"mode"`
+	Hits     []UserSearchHit `json:"hits"`
+	Total    int64           `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+	HasMore  bool            `json:"has_more"`
+	// RanksAsOf is when the ranks of the hits were current.
+	RanksAsOf time.Time `json:"ranks_as_of"`
+}
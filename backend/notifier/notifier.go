@@ -0,0 +1,76 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+	"leaderboard-system/models"
+)
+
+
+type Notifier interface {
+	Notify(ctx context.Context, n models.Notification) error
+}
+
+
+type LogNotifier struct {
+	logger *zap.Logger
+}
+
+
+func NewLogNotifier(logger *zap.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+
+func (ln *LogNotifier) Notify(ctx context.Context, n models.Notification) error {
+	ln.logger.Info("Watch notification",
+		zap.String("tenant_id", n.TenantID),
+		zap.String("watch_id", n.WatchID),
+		zap.String("recipient_id", n.RecipientID),
+		zap.String("kind", string(n.Kind)),
+		zap.String("actor_id", n.ActorID),
+		zap.Int64("rank", n.Rank),
+		zap.Int64("threshold", n.Threshold),
+	)
+	return nil
+}
+
+// MemoryNotifier records notifications in order so tests can assert on them.
+type MemoryNotifier struct {
+	mu    sync.Mutex
+	items []models.Notification
+}
+
+
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+
+func (mn *MemoryNotifier) Notify(ctx context.Context, n models.Notification) error {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	mn.items = append(mn.items, n)
+	return nil
+}
+
+
+func (mn *MemoryNotifier) Notifications() []models.Notification {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	out := make([]models.Notification, len(mn.items))
+	copy(out, mn.items)
+	return out
+}
+
+
+func (mn *MemoryNotifier) Reset() {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	mn.items = nil
+}
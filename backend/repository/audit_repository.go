@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"leaderboard-system/models"
+	"leaderboard-system/tenant"
+)
+
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+
+func (r *AuditRepository) AppendEntry(ctx context.Context, entry *models.UserAuditEntry) error {
+	entry.TenantID = tenant.ID(ctx)
+	if err := conn(ctx, r.db).Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	return nil
+}
+
+// GetEntriesByUser returns the audit trail of userID, oldest first.
+func (r *AuditRepository) GetEntriesByUser(ctx context.Context, userID string) ([]models.UserAuditEntry, error) {
+	var entries []models.UserAuditEntry
+	if err := scoped(ctx, conn(ctx, r.db)).
+		Where("user_id = ?", userID).
+		Order("id ASC").
+		Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to get audit entries: %w", err)
+	}
+	return entries, nil
+}
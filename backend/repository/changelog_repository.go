@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"leaderboard-system/models"
+	"leaderboard-system/tenant"
+)
+
+// ChangeLogRepository keeps one log for every tenant. Versions are assigned
+// across all of them, so a tenant sees gaps; each reads only its own changes.
+type ChangeLogRepository struct {
+	db    *gorm.DB
+	reads ReadRouter
+}
+
+
+func NewChangeLogRepository(db *gorm.DB, reads ReadRouter) *ChangeLogRepository {
+	return &ChangeLogRepository{db: db, reads: reads}
+}
+
+
+func (r *ChangeLogRepository) reader(ctx context.Context) *gorm.DB {
+	return scoped(ctx, readDB(ctx, r.db, r.reads))
+}
+
+// changeLogLockKey is the first half of the advisory lock AppendChange takes
+// per tenant; the tenant's hash is the second.
+const changeLogLockKey = 0x4c424348
+
+// AppendChange records change under the next version as the unit of work ctx
+// is part of commits; change.Version is set by then. Versions are drawn from a
+// sequence when the row is inserted, not when it commits, so a change could
+// otherwise become visible after a later one was read, and a client that
+// polled past the later version would never see it. The row is inserted in a
+// BeforeCommit step under the tenant's lock, which is held until the commit,
+// so a tenant's changes commit in version order: whoever sees version V has
+// seen every change of the tenant before it. The lock only spans the insert
+// and the commit, not the rest of the unit of work.
+func (r *ChangeLogRepository) AppendChange(ctx context.Context, change *models.LeaderboardChange) error {
+	change.TenantID = tenant.ID(ctx)
+	return inTx(ctx, r.db, func(ctx context.Context) error {
+		return BeforeCommit(ctx, func(ctx context.Context) error {
+			if err := conn(ctx, r.db).
+				Exec("SELECT pg_advisory_xact_lock(?, hashtext(?))", changeLogLockKey, change.TenantID).Error; err != nil {
+				return fmt.Errorf("failed to lock leaderboard change log: %w", err)
+			}
+			if err := conn(ctx, r.db).Create(change).Error; err != nil {
+				return fmt.Errorf("failed to append leaderboard change: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+
+func (r *ChangeLogRepository) LatestVersion(ctx context.Context) (int64, error) {
+	var version int64
+	if err := r.reader(ctx).
+		Model(&models.LeaderboardChange{}).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("failed to get leaderboard version: %w", err)
+	}
+	return version, nil
+}
+
+// OldestVersion returns the version the tenant ctx acts for was last pruned
+// up to: its changes before it may be gone, and all from it on are still
+// there. It is 0 while the tenant was never pruned.
+func (r *ChangeLogRepository) OldestVersion(ctx context.Context) (int64, error) {
+	var version int64
+	if err := readDB(ctx, r.db, r.reads).
+		Raw("SELECT COALESCE(MAX(pruned_before), 0) FROM leaderboard_change_floors WHERE tenant_id = ?", tenant.ID(ctx)).
+		Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("failed to get oldest leaderboard version: %w", err)
+	}
+	return version, nil
+}
+
+
+func (r *ChangeLogRepository) GetChangesSince(ctx context.Context, since int64, limit int) ([]models.LeaderboardChange, error) {
+	var changes []models.LeaderboardChange
+	if err := r.reader(ctx).
+		Where("version > ?", since).
+		Order("version ASC").
+		Limit(limit).
+		Find(&changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard changes: %w", err)
+	}
+	return changes, nil
+}
+
+// Prune deletes the changes of the tenant ctx acts for except its newest
+// retain, and moves the tenant's OldestVersion past them. Other tenants keep
+// their history however busy this one is.
+func (r *ChangeLogRepository) Prune(ctx context.Context, retain int) error {
+	id := tenant.ID(ctx)
+	if err := conn(ctx, r.db).Exec(`
+		WITH cutoff AS (
+		  SELECT version FROM leaderboard_changes
+		  WHERE tenant_id = @tenant ORDER BY version DESC OFFSET @retain LIMIT 1
+		), pruned AS (
+		  DELETE FROM leaderboard_changes
+		  WHERE tenant_id = @tenant AND version <= (SELECT version FROM cutoff)
+		  RETURNING version
+		)
+		INSERT INTO leaderboard_change_floors (tenant_id, pruned_before)
+		SELECT @tenant, MAX(version) + 1 FROM pruned HAVING COUNT(*) > 0
+		ON CONFLICT (tenant_id) DO UPDATE
+		SET pruned_before = GREATEST(leaderboard_change_floors.pruned_before, EXCLUDED.pruned_before)`,
+		map[string]interface{}{"tenant": id, "retain": retain}).Error; err != nil {
+		return fmt.Errorf("failed to prune leaderboard changes: %w", err)
+	}
+	return nil
+}
+
+// GetRecentUserIDs returns the users whose rating changed most recently,
+// newest first, each at most once.
+func (r *ChangeLogRepository) GetRecentUserIDs(ctx context.Context, limit int) ([]string, error) {
+	var ids []string
+	if err := r.reader(ctx).
+		Model(&models.LeaderboardChange{}).
+		Select("user_id").
+		Where("user_id <> ''").
+		Group("user_id").
+		Order("MAX(version) DESC").
+		Limit(limit).
+		Pluck("user_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to get recently changed users: %w", err)
+	}
+	return ids, nil
+}
+
+// DeleteUserChanges removes every change recorded for userID, for erasure.
+func (r *ChangeLogRepository) DeleteUserChanges(ctx context.Context, userID string) error {
+	if err := scoped(ctx, conn(ctx, r.db)).
+		Where("user_id = ?", userID).
+		Delete(&models.LeaderboardChange{}).Error; err != nil {
+		return fmt.Errorf("failed to delete leaderboard changes of user: %w", err)
+	}
+	return nil
+}
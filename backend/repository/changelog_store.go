@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"leaderboard-system/models"
+	"leaderboard-system/tenant"
+)
+
+// ChangeLogStore is the leaderboard change log the services depend on.
+// ChangeLogRepository implements it on Postgres and MemoryChangeLog in
+// process. Versions are shared by all tenants; each reads, and is pruned, only
+// by its own changes.
+type ChangeLogStore interface {
+	AppendChange(ctx context.Context, change *models.LeaderboardChange) error
+	LatestVersion(ctx context.Context) (int64, error)
+	OldestVersion(ctx context.Context) (int64, error)
+	GetChangesSince(ctx context.Context, since int64, limit int) ([]models.LeaderboardChange, error)
+	Prune(ctx context.Context, retain int) error
+	GetRecentUserIDs(ctx context.Context, limit int) ([]string, error)
+	DeleteUserChanges(ctx context.Context, userID string) error
+}
+
+// MemoryChangeLog keeps the change log in process, next to a MemoryUserStore.
+// Like ChangeLogRepository it appends a change as the unit of work commits,
+// and changes are visible as soon as they are appended, so they are always
+// seen in version order.
+type MemoryChangeLog struct {
+	mu      sync.RWMutex
+	changes []models.LeaderboardChange
+	version int64
+	// floors are the versions each tenant was pruned up to.
+	floors map[string]int64
+}
+
+
+func NewMemoryChangeLog() *MemoryChangeLog {
+	return &MemoryChangeLog{floors: make(map[string]int64)}
+}
+
+
+func (l *MemoryChangeLog) AppendChange(ctx context.Context, change *models.LeaderboardChange) error {
+	change.TenantID = tenant.ID(ctx)
+	return BeforeCommit(ctx, func(ctx context.Context) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.version++
+		change.Version = l.version
+		l.changes = append(l.changes, *change)
+		return nil
+	})
+}
+
+
+func (l *MemoryChangeLog) LatestVersion(ctx context.Context) (int64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	id := tenant.ID(ctx)
+	for i := len(l.changes) - 1; i >= 0; i-- {
+		if l.changes[i].TenantID == id {
+			return l.changes[i].Version, nil
+		}
+	}
+	return 0, nil
+}
+
+
+func (l *MemoryChangeLog) OldestVersion(ctx context.Context) (int64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.floors[tenant.ID(ctx)], nil
+}
+
+
+func (l *MemoryChangeLog) GetChangesSince(ctx context.Context, since int64, limit int) ([]models.LeaderboardChange, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	id := tenant.ID(ctx)
+	i := sort.Search(len(l.changes), func(i int) bool { return l.changes[i].Version > since })
+	var out []models.LeaderboardChange
+	for ; i < len(l.changes) && len(out) < limit; i++ {
+		if l.changes[i].TenantID == id {
+			out = append(out, l.changes[i])
+		}
+	}
+	return out, nil
+}
+
+
+func (l *MemoryChangeLog) Prune(ctx context.Context, retain int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := tenant.ID(ctx)
+
+	// Walk back from the newest, keeping the tenant's newest retain changes.
+	drop := make(map[int]bool)
+	seen := 0
+	for i := len(l.changes) - 1; i >= 0; i-- {
+		if l.changes[i].TenantID != id {
+			continue
+		}
+		if seen++; seen > retain {
+			drop[i] = true
+			if l.changes[i].Version+1 > l.floors[id] {
+				l.floors[id] = l.changes[i].Version + 1
+			}
+		}
+	}
+	if len(drop) == 0 {
+		return nil
+	}
+	kept := make([]models.LeaderboardChange, 0, len(l.changes)-len(drop))
+	for i, ch := range l.changes {
+		if !drop[i] {
+			kept = append(kept, ch)
+		}
+	}
+	l.changes = kept
+	return nil
+}
+
+
+func (l *MemoryChangeLog) GetRecentUserIDs(ctx context.Context, limit int) ([]string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	id := tenant.ID(ctx)
+	seen := make(map[string]bool)
+	var ids []string
+	for i := len(l.changes) - 1; i >= 0 && len(ids) < limit; i-- {
+		ch := l.changes[i]
+		if ch.TenantID != id || ch.UserID == "" || seen[ch.UserID] {
+			continue
+		}
+		seen[ch.UserID] = true
+		ids = append(ids, ch.UserID)
+	}
+	return ids, nil
+}
+
+
+func (l *MemoryChangeLog) DeleteUserChanges(ctx context.Context, userID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := tenant.ID(ctx)
+	kept := l.changes[:0]
+	for _, ch := range l.changes {
+		if ch.TenantID != id || ch.UserID != userID {
+			kept = append(kept, ch)
+		}
+	}
+	l.changes = kept
+	return nil
+}
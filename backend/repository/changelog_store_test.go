@@ -0,0 +1,58 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+// Pruning a busy tenant must leave a quiet tenant's history, and its oldest
+// version, alone.
+func TestMemoryChangeLogPrunesPerTenant(t *testing.T) {
+	busy := tenant.WithID(context.Background(), "busy")
+	quiet := tenant.WithID(context.Background(), "quiet")
+	log := repository.NewMemoryChangeLog()
+
+	appendChanges := func(ctx context.Context, n int) {
+		for i := 0; i < n; i++ {
+			if err := log.AppendChange(ctx, &models.LeaderboardChange{UserID: "u", NewRating: 1000}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	appendChanges(quiet, 3)
+	appendChanges(busy, 10)
+
+	if err := log.Prune(busy, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Prune(quiet, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		ctx    context.Context
+		oldest int64
+		kept   int
+	}{
+		// The busy tenant's versions are 4 to 13; 10 to 13 are kept.
+		{name: "busy", ctx: busy, oldest: 10, kept: 4},
+		{name: "quiet", ctx: quiet, oldest: 0, kept: 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			oldest, err := log.OldestVersion(tc.ctx)
+			if err != nil || oldest != tc.oldest {
+				t.Fatalf("OldestVersion = %d, %v, want %d", oldest, err, tc.oldest)
+			}
+			changes, err := log.GetChangesSince(tc.ctx, 0, 100)
+			if err != nil || len(changes) != tc.kept {
+				t.Fatalf("GetChangesSince(0) = %d changes, %v, want %d", len(changes), err, tc.kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,687 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"leaderboard-system/models"
+	"leaderboard-system/tenant"
+)
+
+// MemoryUserStore keeps users in process, one board per tenant, sorted in
+// leaderboard order. It mirrors UserRepository, quirks included: usernames are
+// unique as written but looked up case-insensitively, and username order is
+// byte-wise, which is what Postgres does under the C collation.
+type MemoryUserStore struct {
+	// txMu runs units of work one at a time, and writes outside one wait for
+	// it the way a single statement waits on a transaction's row locks.
+	txMu sync.Mutex
+
+	mu     sync.RWMutex
+	boards map[string]*memoryBoard
+	now    func() time.Time
+}
+
+// memoryBoard holds one tenant's users. byID holds every user; ordered only
+// the active ones.
+type memoryBoard struct {
+	byID    map[string]*models.User
+	ordered []*models.User
+}
+
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{
+		boards: make(map[string]*memoryBoard),
+		now:    time.Now,
+	}
+}
+
+// board returns the board of the tenant ctx acts for, empty when it has no
+// users. Must be called with mu held.
+func (s *MemoryUserStore) board(ctx context.Context) *memoryBoard {
+	if b, ok := s.boards[tenant.ID(ctx)]; ok {
+		return b
+	}
+	return &memoryBoard{}
+}
+
+// boardFor is board for writes, creating the board on first use. Must be
+// called with mu held for writing.
+func (s *MemoryUserStore) boardFor(ctx context.Context) *memoryBoard {
+	id := tenant.ID(ctx)
+	b, ok := s.boards[id]
+	if !ok {
+		b = &memoryBoard{byID: make(map[string]*models.User)}
+		s.boards[id] = b
+	}
+	return b
+}
+
+// leaderboardLess orders by rating descending, then username ascending.
+func leaderboardLess(a, b *models.User) bool {
+	if a.Rating != b.Rating {
+		return a.Rating > b.Rating
+	}
+	return a.Username < b.Username
+}
+
+// position returns where user sits, or would sit, in the ordered slice. Must
+// be called with mu held.
+func (b *memoryBoard) position(user *models.User) int {
+	return sort.Search(len(b.ordered), func(i int) bool {
+		return !leaderboardLess(b.ordered[i], user)
+	})
+}
+
+// countAbove must be called with mu held.
+func (b *memoryBoard) countAbove(rating int32) int64 {
+	return int64(sort.Search(len(b.ordered), func(i int) bool {
+		return b.ordered[i].Rating <= rating
+	}))
+}
+
+// pageBounds applies offset and limit the way GORM does: a negative limit
+// means no limit and a non-positive offset means none.
+func pageBounds(n, offset, limit int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > n {
+		offset = n
+	}
+	end := n
+	if limit >= 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return offset, end
+}
+
+
+// active returns the active user with userID. Must be called with mu held.
+func (b *memoryBoard) active(userID string) (*models.User, bool) {
+	u, ok := b.byID[userID]
+	if !ok || u.DeletedAt.Valid {
+		return nil, false
+	}
+	return u, true
+}
+
+// insert and remove keep ordered sorted. Must be called with mu held.
+func (b *memoryBoard) insert(u *models.User) {
+	i := b.position(u)
+	b.ordered = append(b.ordered, nil)
+	copy(b.ordered[i+1:], b.ordered[i:])
+	b.ordered[i] = u
+}
+
+
+func (b *memoryBoard) remove(u *models.User) {
+	i := b.position(u)
+	b.ordered = append(b.ordered[:i], b.ordered[i+1:]...)
+}
+
+
+func copyUsers(users []*models.User) []models.User {
+	out := make([]models.User, len(users))
+	for i, u := range users {
+		out[i] = *u
+	}
+	return out
+}
+
+
+func (s *MemoryUserStore) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if unitOfWorkFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	uow := &unitOfWork{}
+	s.txMu.Lock()
+	saved := s.snapshot()
+	txCtx := context.WithValue(ctx, unitOfWorkKey{}, uow)
+	err := fn(txCtx)
+	if err == nil {
+		err = uow.committing(txCtx)
+	}
+	if err != nil {
+		s.restore(saved)
+	}
+	s.txMu.Unlock()
+	if err != nil {
+		return err
+	}
+	uow.committed(ctx)
+	return nil
+}
+
+// snapshot copies every board, so a unit of work that fails can be rolled
+// back by restoring it. Must be called with txMu held.
+func (s *MemoryUserStore) snapshot() map[string]*memoryBoard {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	saved := make(map[string]*memoryBoard, len(s.boards))
+	for id, b := range s.boards {
+		c := &memoryBoard{byID: make(map[string]*models.User, len(b.byID))}
+		for userID, u := range b.byID {
+			user := *u
+			user.Metadata = u.Metadata.Clone()
+			c.byID[userID] = &user
+		}
+		c.ordered = make([]*models.User, len(b.ordered))
+		for i, u := range b.ordered {
+			c.ordered[i] = c.byID[u.ID]
+		}
+		saved[id] = c
+	}
+	return saved
+}
+
+
+func (s *MemoryUserStore) restore(saved map[string]*memoryBoard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.boards = saved
+}
+
+// autocommit makes a write outside a unit of work wait for the one in
+// flight, so it neither lands inside a unit of work that is then rolled back
+// nor slips past a lock one holds. The returned func releases it.
+func (s *MemoryUserStore) autocommit(ctx context.Context) func() {
+	if unitOfWorkFrom(ctx) != nil {
+		return func() {}
+	}
+	s.txMu.Lock()
+	return s.txMu.Unlock
+}
+
+// LockUser and LockUsername hold what they lock by holding txMu, which the
+// unit of work they run in already does until it ends. Outside one they
+// release it at once, as UserRepository's do.
+func (s *MemoryUserStore) LockUser(ctx context.Context, userID string) (*models.User, error) {
+	return s.GetUserByID(ctx, userID)
+}
+
+
+func (s *MemoryUserStore) LockUsername(ctx context.Context, username string) error {
+	return nil
+}
+
+
+func (s *MemoryUserStore) CreateUser(ctx context.Context, user *models.User) error {
+	defer s.autocommit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.boardFor(ctx)
+
+	if _, ok := b.byID[user.ID]; ok {
+		return fmt.Errorf("failed to create user: %w", ErrDuplicateUser)
+	}
+	for _, u := range b.byID {
+		if u.Username == user.Username {
+			return fmt.Errorf("failed to create user: %w", ErrDuplicateUser)
+		}
+	}
+
+	user.TenantID = tenant.ID(ctx)
+	now := s.now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = now
+	}
+
+	stored := *user
+	stored.Metadata = user.Metadata.Clone()
+	b.insert(&stored)
+	b.byID[stored.ID] = &stored
+	return nil
+}
+
+
+func (s *MemoryUserStore) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	u, ok := b.active(userID)
+	if !ok {
+		return nil, nil
+	}
+	user := *u
+	return &user, nil
+}
+
+// GetUserByUsername matches case-insensitively. If several users differ only
+// in case, the one with the smallest id wins, as with GORM's First.
+func (s *MemoryUserStore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	return findByUsername(b.ordered, username), nil
+}
+
+
+func findByUsername(users []*models.User, username string) *models.User {
+	want := strings.ToLower(username)
+	var found *models.User
+	for _, u := range users {
+		if strings.ToLower(u.Username) != want {
+			continue
+		}
+		if found == nil || u.ID < found.ID {
+			found = u
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	user := *found
+	return &user
+}
+
+// UpdateUserRating is a no-op for an unknown user, like an UPDATE matching no
+// rows.
+func (s *MemoryUserStore) UpdateUserRating(ctx context.Context, userID string, newRating int32) error {
+	defer s.autocommit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.boardFor(ctx)
+
+	u, ok := b.active(userID)
+	if !ok {
+		return nil
+	}
+
+	b.remove(u)
+	u.Rating = newRating
+	u.UpdatedAt = s.now()
+	b.insert(u)
+	return nil
+}
+
+
+func (s *MemoryUserStore) UpdateUserProfile(ctx context.Context, userID, username string, metadata models.UserMetadata) error {
+	defer s.autocommit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.boardFor(ctx)
+
+	u, ok := b.active(userID)
+	if !ok {
+		return nil
+	}
+	for _, other := range b.byID {
+		if other != u && other.Username == username {
+			return fmt.Errorf("failed to update user: %w", ErrDuplicateUser)
+		}
+	}
+
+	b.remove(u)
+	u.Username = username
+	u.Metadata = metadata.Clone()
+	u.UpdatedAt = s.now()
+	b.insert(u)
+	return nil
+}
+
+
+func (s *MemoryUserStore) ListUsers(ctx context.Context, q UserListQuery) ([]models.User, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	var selected []*models.User
+	for _, u := range b.ordered {
+		if q.matches(u) {
+			selected = append(selected, u)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return q.less(selected[i], selected[j]) })
+
+	start, end := pageBounds(len(selected), q.Offset, q.Limit)
+	return copyUsers(selected[start:end]), int64(len(selected)), nil
+}
+
+
+func (s *MemoryUserStore) GetLeaderboard(ctx context.Context, offset, limit int) ([]models.User, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	start, end := pageBounds(len(b.ordered), offset, limit)
+	return copyUsers(b.ordered[start:end]), int64(len(b.ordered)), nil
+}
+
+
+func (s *MemoryUserStore) GetLeaderboardAfter(ctx context.Context, cursor *LeaderboardCursor, limit int) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	offset := 0
+	if cursor != nil {
+		after := &models.User{Rating: cursor.Rating, Username: cursor.Username}
+		offset = sort.Search(len(b.ordered), func(i int) bool {
+			return leaderboardLess(after, b.ordered[i])
+		})
+	}
+
+	start, end := pageBounds(len(b.ordered), offset, limit)
+	return copyUsers(b.ordered[start:end]), nil
+}
+
+
+func (s *MemoryUserStore) GetRankedUsers(ctx context.Context, ids []string, ranges []RatingRange, limit int) ([]models.LeaderboardEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	entries := []models.LeaderboardEntry{}
+	for _, u := range b.ordered {
+		if limit >= 0 && len(entries) >= limit {
+			break
+		}
+
+		match := wanted[u.ID]
+		for _, rr := range ranges {
+			if u.Rating >= rr.Min && u.Rating <= rr.Max {
+				match = true
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+
+		entries = append(entries, models.LeaderboardEntry{
+			Rank:     b.countAbove(u.Rating) + 1,
+			Username: u.Username,
+			Rating:   u.Rating,
+		})
+	}
+	return entries, nil
+}
+
+// CalculateRank returns 0 for a user that is unknown or deactivated,
+// matching UserRepository.
+func (s *MemoryUserStore) CalculateRank(ctx context.Context, userID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	u, ok := b.active(userID)
+	if !ok {
+		return 0, nil
+	}
+	return b.countAbove(u.Rating) + 1, nil
+}
+
+
+func (s *MemoryUserStore) CountUsersAbove(ctx context.Context, rating int32) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	return b.countAbove(rating), nil
+}
+
+
+func (s *MemoryUserStore) RanksInRange(ctx context.Context, minRating, maxRating int32) (map[int32]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	ranks := make(map[int32]int64)
+	for _, u := range b.ordered[b.countAbove(maxRating-1):] {
+		if u.Rating < minRating {
+			break
+		}
+		if _, ok := ranks[u.Rating]; !ok {
+			ranks[u.Rating] = b.countAbove(u.Rating) + 1
+		}
+	}
+	return ranks, nil
+}
+
+
+func (s *MemoryUserStore) GetUserCount(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	return int64(len(b.ordered)), nil
+}
+
+
+func (s *MemoryUserStore) GetUserByIDUnscoped(ctx context.Context, userID string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	u, ok := b.byID[userID]
+	if !ok {
+		return nil, nil
+	}
+	user := *u
+	return &user, nil
+}
+
+
+func (s *MemoryUserStore) GetUserByUsernameUnscoped(ctx context.Context, username string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.board(ctx)
+
+	users := make([]*models.User, 0, len(b.byID))
+	for _, u := range b.byID {
+		users = append(users, u)
+	}
+	return findByUsername(users, username), nil
+}
+
+
+func (s *MemoryUserStore) DeactivateUser(ctx context.Context, userID string) (bool, error) {
+	defer s.autocommit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.boardFor(ctx)
+
+	u, ok := b.active(userID)
+	if !ok {
+		return false, nil
+	}
+	b.remove(u)
+	u.DeletedAt = gorm.DeletedAt{Time: s.now(), Valid: true}
+	return true, nil
+}
+
+
+func (s *MemoryUserStore) ReactivateUser(ctx context.Context, userID string) (bool, error) {
+	defer s.autocommit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.boardFor(ctx)
+
+	u, ok := b.byID[userID]
+	if !ok || !u.DeletedAt.Valid || u.ErasedAt != nil {
+		return false, nil
+	}
+	u.DeletedAt = gorm.DeletedAt{}
+	u.UpdatedAt = s.now()
+	b.insert(u)
+	return true, nil
+}
+
+
+func (s *MemoryUserStore) EraseUser(ctx context.Context, userID, username string) (bool, error) {
+	defer s.autocommit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.boardFor(ctx)
+
+	u, ok := b.byID[userID]
+	if !ok || u.ErasedAt != nil {
+		return false, nil
+	}
+	for _, other := range b.byID {
+		if other != u && other.Username == username {
+			return false, fmt.Errorf("failed to erase user: %w", ErrDuplicateUser)
+		}
+	}
+
+	now := s.now()
+	if !u.DeletedAt.Valid {
+		b.remove(u)
+		u.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	}
+	u.Username = username
+	u.Metadata = nil
+	u.ErasedAt = &now
+	u.UpdatedAt = now
+	return true, nil
+}
+
+// ImportUsers applies the rejections UserRepository's import does, in the
+// same order, and reports them in line order.
+func (s *MemoryUserStore) ImportUsers(ctx context.Context, rows []ImportRow, within func(ctx context.Context, result *ImportBatchResult) error) (*ImportBatchResult, error) {
+	if unitOfWorkFrom(ctx) != nil {
+		return nil, errImportInUnitOfWork
+	}
+
+	var result *ImportBatchResult
+	err := s.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = s.importBatch(ctx, rows)
+		if err != nil {
+			return err
+		}
+		return within(ctx, result)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import users: %w", err)
+	}
+	return result, nil
+}
+
+
+func (s *MemoryUserStore) importBatch(ctx context.Context, rows []ImportRow) (*ImportBatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.boardFor(ctx)
+
+	byName := make(map[string][]*models.User, len(b.byID))
+	for _, u := range b.byID {
+		name := strings.ToLower(u.Username)
+		byName[name] = append(byName[name], u)
+	}
+
+	staged := make([]ImportRow, len(rows))
+	copy(staged, rows)
+	for i := range staged {
+		row := &staged[i]
+		if users := byName[strings.ToLower(row.Username)]; row.MatchUsername && len(users) > 0 {
+			row.ID = users[0].ID
+		}
+	}
+	sort.Slice(staged, func(i, j int) bool { return staged[i].Line < staged[j].Line })
+
+	result := &ImportBatchResult{}
+	reject := func(reason string, rejected func(row *ImportRow) bool) {
+		kept := staged[:0]
+		for i := range staged {
+			if rejected(&staged[i]) {
+				result.Rejected = append(result.Rejected, ImportRejection{Line: staged[i].Line, Reason: reason})
+				continue
+			}
+			kept = append(kept, staged[i])
+		}
+		staged = kept
+	}
+	reject("user is deactivated", func(row *ImportRow) bool {
+		u, ok := b.byID[row.ID]
+		return ok && u.DeletedAt.Valid
+	})
+	reject("username is taken by another user", func(row *ImportRow) bool {
+		for _, u := range byName[strings.ToLower(row.Username)] {
+			if u.ID != row.ID {
+				return true
+			}
+		}
+		return false
+	})
+	seen := make(map[string]bool, len(staged))
+	reject("user appears earlier in the import", func(row *ImportRow) bool {
+		if seen[row.ID] {
+			return true
+		}
+		seen[row.ID] = true
+		return false
+	})
+
+	now := s.now()
+	for _, row := range staged {
+		u, ok := b.byID[row.ID]
+		if !ok {
+			user := &models.User{
+				TenantID:  tenant.ID(ctx),
+				ID:        row.ID,
+				Username:  row.Username,
+				Rating:    row.Rating,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			b.insert(user)
+			b.byID[user.ID] = user
+			result.Inserted++
+			continue
+		}
+		if u.Username == row.Username && u.Rating == row.Rating {
+			continue
+		}
+
+		oldRating := u.Rating
+		b.remove(u)
+		u.Username = row.Username
+		u.Rating = row.Rating
+		u.UpdatedAt = now
+		b.insert(u)
+		result.Updated++
+		result.UpdatedIDs = append(result.UpdatedIDs, u.ID)
+		if oldRating != row.Rating {
+			result.RatingChanges = append(result.RatingChanges, ImportRatingChange{
+				ID:        u.ID,
+				Username:  u.Username,
+				OldRating: oldRating,
+				NewRating: row.Rating,
+			})
+		}
+	}
+
+	// The unique index Postgres would trip over.
+	taken := make(map[string]bool, len(b.byID))
+	for _, u := range b.byID {
+		if taken[u.Username] {
+			return nil, ErrDuplicateUser
+		}
+		taken[u.Username] = true
+	}
+	return result, nil
+}
+
+// RebuildRankIndexes has nothing to rebuild in process.
+func (s *MemoryUserStore) RebuildRankIndexes(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,14 @@
+package repository_test
+
+import (
+	"testing"
+
+	"leaderboard-system/repository"
+	"leaderboard-system/repository/storetest"
+)
+
+func TestMemoryUserStoreConformance(t *testing.T) {
+	storetest.Run(t, func() (repository.UserStore, error) {
+		return repository.NewMemoryUserStore(), nil
+	})
+}
@@ -0,0 +1,461 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"leaderboard-system/models"
+	"leaderboard-system/tenant"
+)
+
+// RankState says how far the user_ranks table has caught up. AsOf is nil
+// until the first full recompute; the table must not be read before then.
+// The table is synced for every tenant at once, but each tenant has its own
+// Version, the last of its changes applied; Version and Total are those of
+// one tenant.
+type RankState struct {
+	Version      int64      `json:"version"`
+	Total        int64      `json:"total"`
+	AsOf         *time.Time `json:"as_of"`
+	RecomputedAt *time.Time `json:"recomputed_at"`
+}
+
+// Ready reports whether the table holds ranks that may be served.
+func (st RankState) Ready() bool {
+	return st.AsOf != nil
+}
+
+// RankSyncResult describes one ApplyChanges call.
+type RankSyncResult struct {
+	State RankState
+	// Busy is set when another instance held the table, and nothing was done.
+	Busy bool
+	// NeedsRecompute is set when the changes cannot be applied one by one:
+	// the table was never computed, the log was pruned past it, or a bulk
+	// change was recorded. Nothing was applied.
+	NeedsRecompute bool
+	Applied        int
+	Users          int
+	// Tenants are those whose ranks changed.
+	Tenants  []string
+	CaughtUp bool
+}
+
+
+type RankRepository struct {
+	db    *gorm.DB
+	reads ReadRouter
+}
+
+
+func NewRankRepository(db *gorm.DB, reads ReadRouter) *RankRepository {
+	return &RankRepository{db: db, reads: reads}
+}
+
+
+func (r *RankRepository) reader(ctx context.Context) *gorm.DB {
+	return readDB(ctx, r.db, r.reads)
+}
+
+const rankStateQuery = `SELECT as_of, recomputed_at FROM user_ranks_state WHERE id = 1`
+
+// GetState returns the table's state with the version and total of the tenant
+// ctx acts for; its total is its last position.
+func (r *RankRepository) GetState(ctx context.Context) (RankState, error) {
+	var st RankState
+	if err := r.reader(ctx).Raw(`
+		SELECT (SELECT COALESCE(MAX(version), 0) FROM user_ranks_versions WHERE tenant_id = @tenant) AS version,
+		       s.as_of, s.recomputed_at,
+		       (SELECT COALESCE(MAX(position), 0) FROM user_ranks WHERE tenant_id = @tenant) AS total
+		FROM user_ranks_state s WHERE s.id = 1`, map[string]interface{}{"tenant": tenant.ID(ctx)}).
+		Scan(&st).Error; err != nil {
+		return RankState{}, fmt.Errorf("failed to get rank state: %w", err)
+	}
+	return st, nil
+}
+
+// GetRankPage returns limit entries in leaderboard order starting after
+// offset, with the state they are current as of. Both are read from the same
+// connection, the state first, so the rows are never older than the state.
+func (r *RankRepository) GetRankPage(ctx context.Context, offset, limit int) ([]models.LeaderboardEntry, RankState, error) {
+	ctx = WithPinnedReads(ctx)
+
+	st, err := r.GetState(ctx)
+	if err != nil || !st.Ready() {
+		return nil, st, err
+	}
+
+	var entries []models.LeaderboardEntry
+	if err := scoped(ctx, r.reader(ctx)).
+		Table("user_ranks").
+		Select("username, rating, rank").
+		Where("position > ? AND position <= ?", offset, offset+limit).
+		Order("position ASC").
+		Scan(&entries).Error; err != nil {
+		return nil, st, fmt.Errorf("failed to get rank page: %w", err)
+	}
+	return entries, st, nil
+}
+
+// GetUserRank returns the materialized rank of userID, or 0 when the table
+// does not rank the user (yet).
+func (r *RankRepository) GetUserRank(ctx context.Context, userID string) (int64, error) {
+	var rank int64
+	if err := scoped(ctx, r.reader(ctx)).
+		Table("user_ranks").
+		Select("rank").
+		Where("user_id = ?", userID).
+		Scan(&rank).Error; err != nil {
+		return 0, fmt.Errorf("failed to get materialized rank: %w", err)
+	}
+	return rank, nil
+}
+
+// GetUserRanks returns the materialized ranks of userIDs in one query. Users
+// the table does not rank (yet) are missing from the map.
+func (r *RankRepository) GetUserRanks(ctx context.Context, userIDs []string) (map[string]int64, error) {
+	var rows []struct {
+		UserID string
+		Rank   int64
+	}
+	if err := scoped(ctx, r.reader(ctx)).
+		Table("user_ranks").
+		Select("user_id, rank").
+		Where("user_id IN ?", userIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get materialized ranks: %w", err)
+	}
+	ranks := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		ranks[row.UserID] = row.Rank
+	}
+	return ranks, nil
+}
+
+// lockState locks the state row for the rest of tx. It reports false when
+// another transaction holds it, so instances never queue up behind each other.
+func lockState(tx *gorm.DB) (RankState, bool, error) {
+	var st RankState
+	res := tx.Raw(rankStateQuery + " FOR UPDATE SKIP LOCKED").Scan(&st)
+	if res.Error != nil {
+		return RankState{}, false, fmt.Errorf("failed to lock rank state: %w", res.Error)
+	}
+	return st, res.RowsAffected > 0, nil
+}
+
+// ApplyChanges applies up to limit leaderboard changes, each recorded after
+// its tenant's version, one user at a time. Each user is taken out of the
+// order at its old place and put back at its new one; only the rows between
+// the two places shift.
+//
+// A tenant's changes commit in version order (see AppendChange), so none
+// becomes visible after a later one of its tenant was applied. Changes of
+// different tenants can, which is why every tenant keeps its own version: a
+// change committed after another tenant's later one is still applied.
+func (r *RankRepository) ApplyChanges(ctx context.Context, limit int) (RankSyncResult, error) {
+	var res RankSyncResult
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		st, locked, err := lockState(tx)
+		if err != nil {
+			return err
+		}
+		res.State = st
+		if !locked {
+			res.Busy = true
+			return nil
+		}
+		if !st.Ready() {
+			res.NeedsRecompute = true
+			return nil
+		}
+
+		var prunedPast bool
+		if err := tx.Raw(`
+			SELECT EXISTS (
+			  SELECT 1 FROM leaderboard_change_floors f
+			  LEFT JOIN user_ranks_versions v ON v.tenant_id = f.tenant_id
+			  WHERE f.pruned_before > COALESCE(v.version, 0) + 1)`).Scan(&prunedPast).Error; err != nil {
+			return fmt.Errorf("failed to get oldest leaderboard versions: %w", err)
+		}
+		if prunedPast {
+			res.NeedsRecompute = true
+			return nil
+		}
+
+		var changes []models.LeaderboardChange
+		if err := tx.Raw(`
+			SELECT c.version, c.tenant_id, c.user_id, c.created_at
+			FROM leaderboard_changes c
+			LEFT JOIN user_ranks_versions v ON v.tenant_id = c.tenant_id
+			WHERE c.version > COALESCE(v.version, 0)
+			ORDER BY c.version ASC
+			LIMIT ?`, limit).
+			Scan(&changes).Error; err != nil {
+			return fmt.Errorf("failed to get leaderboard changes: %w", err)
+		}
+		res.CaughtUp = len(changes) < limit
+
+		type rankedUser struct{ tenantID, userID string }
+		var (
+			seen = make(map[rankedUser]bool, len(changes))
+			// versions are the last change applied per tenant.
+			versions = make(map[string]int64)
+		)
+		for _, ch := range changes {
+			if ch.UserID == "" {
+				res.NeedsRecompute = true
+				return nil
+			}
+			if _, ok := versions[ch.TenantID]; !ok {
+				res.Tenants = append(res.Tenants, ch.TenantID)
+			}
+			versions[ch.TenantID] = ch.Version
+
+			key := rankedUser{ch.TenantID, ch.UserID}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if err := applyUserRank(tx, ch.TenantID, ch.UserID); err != nil {
+				return err
+			}
+		}
+		for _, id := range res.Tenants {
+			if err := tx.Exec(`
+				INSERT INTO user_ranks_versions (tenant_id, version) VALUES (?, ?)
+				ON CONFLICT (tenant_id) DO UPDATE SET version = EXCLUDED.version`, id, versions[id]).Error; err != nil {
+				return fmt.Errorf("failed to update rank version: %w", err)
+			}
+		}
+		res.Applied = len(changes)
+		res.Users = len(seen)
+
+		// Caught up, the table reflects every change made before this
+		// transaction started. Otherwise only those up to the last one applied.
+		asOf := "now()"
+		var args []interface{}
+		if !res.CaughtUp {
+			asOf = "GREATEST(as_of, ?)"
+			args = append(args, changes[len(changes)-1].CreatedAt)
+		}
+
+		if err := tx.Raw(`
+			UPDATE user_ranks_state SET as_of = `+asOf+`
+			WHERE id = 1
+			RETURNING as_of, recomputed_at`, args...).
+			Scan(&res.State).Error; err != nil {
+			return fmt.Errorf("failed to update rank state: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return RankSyncResult{}, err
+	}
+	return res, nil
+}
+
+// applyUserRank moves userID from its place in its tenant's part of
+// user_ranks to where its current row in users puts it, or out of the table
+// when it left the board.
+func applyUserRank(tx *gorm.DB, tenantID, userID string) error {
+	var old struct {
+		Rating   int32
+		Position int64
+	}
+	found := tx.Raw("SELECT rating, position FROM user_ranks WHERE tenant_id = ? AND user_id = ?", tenantID, userID).Scan(&old)
+	if found.Error != nil {
+		return fmt.Errorf("failed to get materialized rank: %w", found.Error)
+	}
+	hadRank := found.RowsAffected > 0
+
+	var cur struct {
+		Username string
+		Rating   int32
+	}
+	found = tx.Raw("SELECT username, rating FROM users WHERE tenant_id = ? AND id = ? AND deleted_at IS NULL", tenantID, userID).Scan(&cur)
+	if found.Error != nil {
+		return fmt.Errorf("failed to get user: %w", found.Error)
+	}
+	ranked := found.RowsAffected > 0
+
+	if !hadRank && !ranked {
+		return nil
+	}
+
+	if hadRank {
+		if err := tx.Exec("DELETE FROM user_ranks WHERE tenant_id = ? AND user_id = ?", tenantID, userID).Error; err != nil {
+			return fmt.Errorf("failed to remove materialized rank: %w", err)
+		}
+	}
+
+	// For every other row: was it after the user's old place, is it after
+	// the new one, and was and is it rated below the user. Its position moves
+	// by the difference of the first pair and its rank by that of the second.
+	afterOld, belowOld := "FALSE", "FALSE"
+	afterNew, belowNew := "FALSE", "FALSE"
+	args := map[string]interface{}{"tenant_id": tenantID}
+
+	if hadRank {
+		afterOld, belowOld = "position > @old_position", "rating < @old_rating"
+		args["old_position"], args["old_rating"] = old.Position, old.Rating
+	}
+	if ranked {
+		afterNew, belowNew = "(rating < @rating OR (rating = @rating AND username > @username))", "rating < @rating"
+		args["rating"], args["username"] = cur.Rating, cur.Username
+	}
+
+	// Only rows rated between the two places can move.
+	bound := "rating <= @hi"
+	switch {
+	case hadRank && ranked:
+		lo, hi := old.Rating, cur.Rating
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		bound = "rating BETWEEN @lo AND @hi"
+		args["lo"], args["hi"] = lo, hi
+	case hadRank:
+		args["hi"] = old.Rating
+	default:
+		args["hi"] = cur.Rating
+	}
+
+	set := fmt.Sprintf("position = position + (%s)::int - (%s)::int, rank = rank + (%s)::int - (%s)::int",
+		afterNew, afterOld, belowNew, belowOld)
+	where := fmt.Sprintf("tenant_id = @tenant_id AND %s AND ((%s) <> (%s) OR (%s) <> (%s))",
+		bound, afterNew, afterOld, belowNew, belowOld)
+
+	if err := tx.Exec("UPDATE user_ranks SET "+set+" WHERE "+where, args).Error; err != nil {
+		return fmt.Errorf("failed to shift materialized ranks: %w", err)
+	}
+
+	if !ranked {
+		return nil
+	}
+
+	// The rows before the new place now hold positions 1..n, so the user
+	// takes n+1; its rank is one past the last row rated above it.
+	var before, above int64
+	if err := tx.Raw(`
+		SELECT position FROM user_ranks
+		WHERE tenant_id = ? AND rating >= ? AND (rating > ? OR username < ?)
+		ORDER BY rating ASC, username DESC LIMIT 1`, tenantID, cur.Rating, cur.Rating, cur.Username).
+		Scan(&before).Error; err != nil {
+		return fmt.Errorf("failed to place materialized rank: %w", err)
+	}
+	if err := tx.Raw(`
+		SELECT position FROM user_ranks
+		WHERE tenant_id = ? AND rating > ?
+		ORDER BY rating ASC, username DESC LIMIT 1`, tenantID, cur.Rating).
+		Scan(&above).Error; err != nil {
+		return fmt.Errorf("failed to place materialized rank: %w", err)
+	}
+
+	if err := tx.Exec(
+		"INSERT INTO user_ranks (tenant_id, user_id, username, rating, rank, position) VALUES (?, ?, ?, ?, ?, ?)",
+		tenantID, userID, cur.Username, cur.Rating, above+1, before+1).Error; err != nil {
+		return fmt.Errorf("failed to insert materialized rank: %w", err)
+	}
+	return nil
+}
+
+// Recompute rebuilds user_ranks from users with window functions, one
+// partition per tenant, and marks it current as of the snapshot it read. Rows
+// that did not change are not rewritten. It returns the tenants whose ranks
+// changed, and reports false when another instance held the table.
+func (r *RankRepository) Recompute(ctx context.Context) ([]string, bool, error) {
+	locked := false
+	var tenants []string
+
+	// Repeatable read gives one snapshot for the users and the log versions
+	// they correspond to, however long the statements below take.
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SET LOCAL statement_timeout = 0").Error; err != nil {
+			return fmt.Errorf("failed to lift statement timeout: %w", err)
+		}
+
+		var ok bool
+		var err error
+		if _, ok, err = lockState(tx); err != nil || !ok {
+			return err
+		}
+		locked = true
+
+
+		var upserted, removed []string
+		if err := tx.Raw(`
+			WITH changed AS (
+			INSERT INTO user_ranks (tenant_id, user_id, username, rating, rank, position)
+			SELECT tenant_id, id, username, rating,
+			       RANK() OVER (PARTITION BY tenant_id ORDER BY rating DESC),
+			       ROW_NUMBER() OVER (PARTITION BY tenant_id ORDER BY rating DESC, username ASC)
+			FROM users
+			WHERE deleted_at IS NULL
+			ON CONFLICT (tenant_id, user_id) DO UPDATE SET
+			  username = EXCLUDED.username,
+			  rating = EXCLUDED.rating,
+			  rank = EXCLUDED.rank,
+			  position = EXCLUDED.position
+			WHERE (user_ranks.username, user_ranks.rating, user_ranks.rank, user_ranks.position)
+			  IS DISTINCT FROM (EXCLUDED.username, EXCLUDED.rating, EXCLUDED.rank, EXCLUDED.position)
+			RETURNING tenant_id)
+			SELECT DISTINCT tenant_id FROM changed`).Scan(&upserted).Error; err != nil {
+			return fmt.Errorf("failed to recompute ranks: %w", err)
+		}
+
+		if err := tx.Raw(`
+			WITH changed AS (
+			DELETE FROM user_ranks r
+			WHERE NOT EXISTS (
+			  SELECT 1 FROM users u
+			  WHERE u.tenant_id = r.tenant_id AND u.id = r.user_id AND u.deleted_at IS NULL)
+			RETURNING tenant_id)
+			SELECT DISTINCT tenant_id FROM changed`).Scan(&removed).Error; err != nil {
+			return fmt.Errorf("failed to remove stale ranks: %w", err)
+		}
+
+		seen := make(map[string]bool)
+		for _, id := range append(upserted, removed...) {
+			if !seen[id] {
+				seen[id] = true
+				tenants = append(tenants, id)
+			}
+		}
+
+		// The snapshot holds every change of a tenant up to its latest one
+		// in it, since a tenant's changes commit in version order.
+		if err := tx.Exec(`
+			INSERT INTO user_ranks_versions (tenant_id, version)
+			SELECT tenant_id, MAX(version) FROM leaderboard_changes GROUP BY tenant_id
+			ON CONFLICT (tenant_id) DO UPDATE
+			SET version = GREATEST(user_ranks_versions.version, EXCLUDED.version)`).Error; err != nil {
+			return fmt.Errorf("failed to update rank versions: %w", err)
+		}
+		if err := tx.Exec(`
+			UPDATE user_ranks_state SET
+			  as_of = now(),
+			  recomputed_at = now()
+			WHERE id = 1`).Error; err != nil {
+			return fmt.Errorf("failed to update rank state: %w", err)
+		}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, false, err
+	}
+	return tenants, locked, nil
+}
+
+// renameRankedUser updates the username copied into user_ranks along with
+// users, so an erased username is not served until the next sync.
+func renameRankedUser(tx *gorm.DB, tenantID, userID, username string) error {
+	if err := tx.Exec("UPDATE user_ranks SET username = ? WHERE tenant_id = ? AND user_id = ?", username, tenantID, userID).Error; err != nil {
+		return fmt.Errorf("failed to rename ranked user: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/gorm"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+// Versions are shared by tenants but only commit in order within one. A
+// tenant's change that draws the lower version and commits after another
+// tenant's later change was applied must still be applied.
+func TestApplyChangesInterleavedTenants(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	for _, stmt := range []string{
+		"TRUNCATE users, user_ranks, leaderboard_changes, leaderboard_change_floors, user_ranks_versions CASCADE",
+		"UPDATE user_ranks_state SET as_of = NULL, recomputed_at = NULL WHERE id = 1",
+		`INSERT INTO users (tenant_id, id, username, rating, created_at, updated_at) VALUES
+			('default', 'a1', 'a1', 1000, now(), now()), ('default', 'a2', 'a2', 2000, now(), now()),
+			('storetest-other', 'b1', 'b1', 1000, now(), now()), ('storetest-other', 'b2', 'b2', 2000, now(), now())`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ranks := repository.NewRankRepository(db, nil)
+	if _, _, err := ranks.Recompute(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a1's change draws its version first but stays uncommitted while b1's
+	// later one commits and is applied.
+	slow := db.Begin()
+	defer slow.Rollback()
+	for _, stmt := range []string{
+		"UPDATE users SET rating = 3000 WHERE tenant_id = 'default' AND id = 'a1'",
+		"INSERT INTO leaderboard_changes (tenant_id, user_id, old_rating, new_rating) VALUES ('default', 'a1', 1000, 3000)",
+	} {
+		if err := slow.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE users SET rating = 3000 WHERE tenant_id = 'storetest-other' AND id = 'b1'").Error; err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO leaderboard_changes (tenant_id, user_id, old_rating, new_rating) VALUES ('storetest-other', 'b1', 1000, 3000)").Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ranks.ApplyChanges(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := slow.Commit().Error; err != nil {
+		t.Fatal(err)
+	}
+	res, err := ranks.ApplyChanges(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NeedsRecompute {
+		t.Fatal("ApplyChanges asked for a recompute")
+	}
+
+	cases := []struct {
+		tenant, user string
+		rank         int64
+	}{
+		{"default", "a1", 1},
+		{"default", "a2", 2},
+		{"storetest-other", "b1", 1},
+		{"storetest-other", "b2", 2},
+	}
+	for _, tc := range cases {
+		rank, err := ranks.GetUserRank(tenant.WithID(ctx, tc.tenant), tc.user)
+		if err != nil || rank != tc.rank {
+			t.Errorf("GetUserRank(%s/%s) = %d, %v, want %d", tc.tenant, tc.user, rank, err, tc.rank)
+		}
+	}
+}
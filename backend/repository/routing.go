@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ReadRouter picks the connection a read goes to, typically a replica that is
+// not lagging too far behind. database.Replicas implements it.
+type ReadRouter interface {
+	Reader() *gorm.DB
+}
+
+type routingKey int
+
+const (
+	primaryReadsKey routingKey = iota
+	pinnedReadsKey
+)
+
+type readPin struct {
+	mu sync.Mutex
+	db *gorm.DB
+}
+
+// WithPrimaryReads sends every read made with ctx to the primary, for paths
+// that must see their own writes.
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey, true)
+}
+
+// ReadsFromPrimary reports whether ctx was marked with WithPrimaryReads.
+func ReadsFromPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryReadsKey).(bool)
+	return primary
+}
+
+// WithPinnedReads sends every read made with ctx to whichever connection the
+// first one picked. Reads that must agree with each other, like a version and
+// the rows it describes, then come from one replica's consistent history.
+func WithPinnedReads(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(pinnedReadsKey).(*readPin); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, pinnedReadsKey, &readPin{})
+}
+
+// readDB returns the connection a read with ctx should use. Reads in a unit
+// of work go through its transaction.
+func readDB(ctx context.Context, primary *gorm.DB, router ReadRouter) *gorm.DB {
+	if unitOfWorkFrom(ctx) != nil || router == nil || ReadsFromPrimary(ctx) {
+		return conn(ctx, primary)
+	}
+
+	pick := func() *gorm.DB {
+		if db := router.Reader(); db != nil {
+			return db
+		}
+		return primary
+	}
+
+	if pin, ok := ctx.Value(pinnedReadsKey).(*readPin); ok {
+		pin.mu.Lock()
+		defer pin.mu.Unlock()
+		if pin.db == nil {
+			pin.db = pick()
+		}
+		return pin.db.WithContext(ctx)
+	}
+	return pick().WithContext(ctx)
+}
@@ -0,0 +1,685 @@
+// Package storetest checks that a repository.UserStore behaves like the
+// Postgres-backed UserRepository, so in-memory and database stores can be
+// swapped without changing service behaviour.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+// Run checks the store returned by newStore against the UserStore contract,
+// each property as its own subtest. newStore is called once per property and
+// must return an empty store; for UserRepository that means truncating the
+// users table first.
+func Run(t *testing.T, newStore func() (repository.UserStore, error)) {
+	cases := []struct {
+		name string
+		run  func(ctx context.Context, s repository.UserStore) error
+	}{
+		{"lookup", testLookup},
+		{"unique", testUnique},
+		{"leaderboard order", testLeaderboardOrder},
+		{"leaderboard walk", testLeaderboardWalk},
+		{"ranks", testRanks},
+		{"update rating", testUpdateRating},
+		{"ranked users", testRankedUsers},
+		{"deactivation", testDeactivation},
+		{"erasure", testErasure},
+		{"tenants", testTenants},
+		{"unit of work", testUnitOfWork},
+		{"rollback", testRollback},
+		{"username lock", testUsernameLock},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			store, err := newStore()
+			if err != nil {
+				t.Fatalf("new store: %v", err)
+			}
+			if err := c.run(context.Background(), store); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// fixture is a board with ties at 2000 and 1500:
+//
+//	1 carol 2500, 2 alice 2000, 2 bob 2000, 4 dave 1500, 4 erin 1500, 6 frank 1000
+var fixture = []models.User{
+	{ID: "u-bob", Username: "bob", Rating: 2000},
+	{ID: "u-alice", Username: "alice", Rating: 2000},
+	{ID: "u-frank", Username: "frank", Rating: 1000},
+	{ID: "u-carol", Username: "carol", Rating: 2500},
+	{ID: "u-erin", Username: "erin", Rating: 1500},
+	{ID: "u-dave", Username: "dave", Rating: 1500},
+}
+
+var fixtureOrder = []string{"carol", "alice", "bob", "dave", "erin", "frank"}
+
+
+func seed(ctx context.Context, s repository.UserStore) error {
+	for _, u := range fixture {
+		user := u
+		if err := s.CreateUser(ctx, &user); err != nil {
+			return fmt.Errorf("seed %s: %w", u.Username, err)
+		}
+	}
+	return nil
+}
+
+
+func usernames(users []models.User) []string {
+	names := make([]string, len(users))
+	for i, u := range users {
+		names[i] = u.Username
+	}
+	return names
+}
+
+
+func expect(what string, got, want interface{}) error {
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("%s = %v, want %v", what, got, want)
+	}
+	return nil
+}
+
+
+func testLookup(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+
+	user, err := s.GetUserByID(ctx, "u-alice")
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Username != "alice" || user.Rating != 2000 {
+		return fmt.Errorf("GetUserByID(u-alice) = %+v", user)
+	}
+	if user.CreatedAt.IsZero() || user.UpdatedAt.IsZero() {
+		return errors.New("CreateUser did not set timestamps")
+	}
+
+	user, err = s.GetUserByUsername(ctx, "ALICE")
+	if err != nil {
+		return err
+	}
+	if user == nil || user.ID != "u-alice" {
+		return fmt.Errorf("GetUserByUsername(ALICE) = %+v, want u-alice", user)
+	}
+
+	if user, err := s.GetUserByID(ctx, "missing"); err != nil || user != nil {
+		return fmt.Errorf("GetUserByID(missing) = %+v, %v, want nil, nil", user, err)
+	}
+	if user, err := s.GetUserByUsername(ctx, "missing"); err != nil || user != nil {
+		return fmt.Errorf("GetUserByUsername(missing) = %+v, %v, want nil, nil", user, err)
+	}
+
+	count, err := s.GetUserCount(ctx)
+	if err != nil {
+		return err
+	}
+	return expect("GetUserCount", count, int64(len(fixture)))
+}
+
+
+func testUnique(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+
+	if err := s.CreateUser(ctx, &models.User{ID: "u-alice", Username: "alice2", Rating: 100}); err == nil {
+		return errors.New("CreateUser accepted a duplicate id")
+	}
+	if err := s.CreateUser(ctx, &models.User{ID: "u-alice2", Username: "alice", Rating: 100}); err == nil {
+		return errors.New("CreateUser accepted a duplicate username")
+	}
+	if err := s.CreateUser(ctx, &models.User{ID: "u-bob", Username: "bob2", Rating: 100}); !errors.Is(err, repository.ErrDuplicateUser) {
+		return fmt.Errorf("CreateUser of a duplicate id: got %v, want ErrDuplicateUser", err)
+	}
+
+	count, err := s.GetUserCount(ctx)
+	if err != nil {
+		return err
+	}
+	return expect("GetUserCount after rejected creates", count, int64(len(fixture)))
+}
+
+
+func testLeaderboardOrder(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+
+	users, total, err := s.GetLeaderboard(ctx, 0, 100)
+	if err != nil {
+		return err
+	}
+	if err := expect("GetLeaderboard order", usernames(users), fixtureOrder); err != nil {
+		return err
+	}
+	if err := expect("GetLeaderboard total", total, int64(len(fixture))); err != nil {
+		return err
+	}
+
+	users, total, err = s.GetLeaderboard(ctx, 2, 3)
+	if err != nil {
+		return err
+	}
+	if err := expect("GetLeaderboard(2, 3)", usernames(users), fixtureOrder[2:5]); err != nil {
+		return err
+	}
+	if err := expect("GetLeaderboard(2, 3) total", total, int64(len(fixture))); err != nil {
+		return err
+	}
+
+	users, _, err = s.GetLeaderboard(ctx, 10, 3)
+	if err != nil {
+		return err
+	}
+	return expect("GetLeaderboard past the end", len(users), 0)
+}
+
+
+func testLeaderboardWalk(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+
+	var walked []string
+	var cursor *repository.LeaderboardCursor
+	for i := 0; i < len(fixture); i++ {
+		users, err := s.GetLeaderboardAfter(ctx, cursor, 2)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			break
+		}
+		walked = append(walked, usernames(users)...)
+		last := users[len(users)-1]
+		cursor = &repository.LeaderboardCursor{Rating: last.Rating, Username: last.Username}
+	}
+	return expect("GetLeaderboardAfter walk", walked, fixtureOrder)
+}
+
+
+func testRanks(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+
+	want := map[string]int64{
+		"u-carol": 1, "u-alice": 2, "u-bob": 2, "u-dave": 4, "u-erin": 4, "u-frank": 6,
+		// An unknown user has no rank.
+		"missing": 0,
+	}
+	for id, rank := range want {
+		got, err := s.CalculateRank(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := expect("CalculateRank("+id+")", got, rank); err != nil {
+			return err
+		}
+	}
+
+	for rating, count := range map[int32]int64{3000: 0, 2500: 0, 2000: 1, 1999: 3, 1000: 5, 0: 6} {
+		got, err := s.CountUsersAbove(ctx, rating)
+		if err != nil {
+			return err
+		}
+		if err := expect(fmt.Sprintf("CountUsersAbove(%d)", rating), got, count); err != nil {
+			return err
+		}
+	}
+
+	windows := []struct {
+		min, max int32
+		want     map[int32]int64
+	}{
+		{1000, 2500, map[int32]int64{2000: 2, 1500: 4, 1000: 6}},
+		{1500, 2001, map[int32]int64{2000: 2, 1500: 4}},
+		{1001, 1500, map[int32]int64{}},
+	}
+	for _, w := range windows {
+		got, err := s.RanksInRange(ctx, w.min, w.max)
+		if err != nil {
+			return err
+		}
+		if err := expect(fmt.Sprintf("RanksInRange(%d, %d)", w.min, w.max), got, w.want); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+
+func testUpdateRating(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+
+	before, err := s.GetUserByID(ctx, "u-frank")
+	if err != nil {
+		return err
+	}
+	if err := s.UpdateUserRating(ctx, "u-frank", 2000); err != nil {
+		return err
+	}
+
+	users, _, err := s.GetLeaderboard(ctx, 0, 100)
+	if err != nil {
+		return err
+	}
+	order := []string{"carol", "alice", "bob", "frank", "dave", "erin"}
+	if err := expect("GetLeaderboard after update", usernames(users), order); err != nil {
+		return err
+	}
+
+	after, err := s.GetUserByID(ctx, "u-frank")
+	if err != nil {
+		return err
+	}
+	if after.Rating != 2000 || after.UpdatedAt.Before(before.UpdatedAt) {
+		return fmt.Errorf("GetUserByID after update = %+v", after)
+	}
+
+	rank, err := s.CalculateRank(ctx, "u-frank")
+	if err != nil {
+		return err
+	}
+	if err := expect("CalculateRank(u-frank) after update", rank, int64(2)); err != nil {
+		return err
+	}
+
+	if err := s.UpdateUserRating(ctx, "missing", 1234); err != nil {
+		return fmt.Errorf("UpdateUserRating(missing) = %v, want nil", err)
+	}
+	count, err := s.GetUserCount(ctx)
+	if err != nil {
+		return err
+	}
+	return expect("GetUserCount after updating a missing user", count, int64(len(fixture)))
+}
+
+
+func testRankedUsers(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+
+	entries, err := s.GetRankedUsers(ctx, []string{"u-frank"}, []repository.RatingRange{{Min: 1500, Max: 2000}}, 100)
+	if err != nil {
+		return err
+	}
+	want := []models.LeaderboardEntry{
+		{Rank: 2, Username: "alice", Rating: 2000},
+		{Rank: 2, Username: "bob", Rating: 2000},
+		{Rank: 4, Username: "dave", Rating: 1500},
+		{Rank: 4, Username: "erin", Rating: 1500},
+		{Rank: 6, Username: "frank", Rating: 1000},
+	}
+	if err := expect("GetRankedUsers", entries, want); err != nil {
+		return err
+	}
+
+	entries, err = s.GetRankedUsers(ctx, []string{"u-frank"}, []repository.RatingRange{{Min: 1500, Max: 2000}}, 2)
+	if err != nil {
+		return err
+	}
+	if err := expect("GetRankedUsers limit 2", entries, want[:2]); err != nil {
+		return err
+	}
+
+	entries, err = s.GetRankedUsers(ctx, []string{}, nil, 100)
+	if err != nil {
+		return err
+	}
+	return expect("GetRankedUsers with nothing requested", len(entries), 0)
+}
+
+
+func testDeactivation(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+
+	if ok, err := s.DeactivateUser(ctx, "u-alice"); err != nil || !ok {
+		return fmt.Errorf("DeactivateUser(u-alice) = %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := s.DeactivateUser(ctx, "u-alice"); err != nil || ok {
+		return fmt.Errorf("DeactivateUser(u-alice) again = %v, %v, want false, nil", ok, err)
+	}
+
+	users, total, err := s.GetLeaderboard(ctx, 0, 100)
+	if err != nil {
+		return err
+	}
+	if err := expect("GetLeaderboard without alice", usernames(users), []string{"carol", "bob", "dave", "erin", "frank"}); err != nil {
+		return err
+	}
+	if err := expect("GetLeaderboard total without alice", total, int64(len(fixture)-1)); err != nil {
+		return err
+	}
+
+	// Her slot is freed: frank moves up from 6th.
+	rank, err := s.CalculateRank(ctx, "u-frank")
+	if err != nil {
+		return err
+	}
+	if err := expect("CalculateRank(u-frank) without alice", rank, int64(5)); err != nil {
+		return err
+	}
+	above, err := s.CountUsersAbove(ctx, 1999)
+	if err != nil {
+		return err
+	}
+	if err := expect("CountUsersAbove(1999) without alice", above, int64(2)); err != nil {
+		return err
+	}
+
+	if user, err := s.GetUserByID(ctx, "u-alice"); err != nil || user != nil {
+		return fmt.Errorf("GetUserByID(u-alice) = %+v, %v, want nil, nil", user, err)
+	}
+	if user, err := s.GetUserByUsername(ctx, "alice"); err != nil || user != nil {
+		return fmt.Errorf("GetUserByUsername(alice) = %+v, %v, want nil, nil", user, err)
+	}
+	user, err := s.GetUserByIDUnscoped(ctx, "u-alice")
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.DeletedAt.Valid || user.ErasedAt != nil {
+		return fmt.Errorf("GetUserByIDUnscoped(u-alice) = %+v, want deactivated", user)
+	}
+	if user, err := s.GetUserByUsernameUnscoped(ctx, "ALICE"); err != nil || user == nil || user.ID != "u-alice" {
+		return fmt.Errorf("GetUserByUsernameUnscoped(ALICE) = %+v, %v, want u-alice", user, err)
+	}
+
+	// The username stays reserved while she is deactivated.
+	if err := s.CreateUser(ctx, &models.User{ID: "u-alice2", Username: "alice", Rating: 100}); err == nil {
+		return errors.New("CreateUser reused a deactivated user's username")
+	}
+
+	if err := s.UpdateUserRating(ctx, "u-alice", 4000); err != nil {
+		return err
+	}
+	if ok, err := s.ReactivateUser(ctx, "u-alice"); err != nil || !ok {
+		return fmt.Errorf("ReactivateUser(u-alice) = %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := s.ReactivateUser(ctx, "u-alice"); err != nil || ok {
+		return fmt.Errorf("ReactivateUser(u-alice) again = %v, %v, want false, nil", ok, err)
+	}
+
+	// The rating update was ignored while she was deactivated.
+	users, _, err = s.GetLeaderboard(ctx, 0, 100)
+	if err != nil {
+		return err
+	}
+	return expect("GetLeaderboard after reactivation", usernames(users), fixtureOrder)
+}
+
+
+func testErasure(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+
+	if ok, err := s.EraseUser(ctx, "u-bob", "erased-1"); err != nil || !ok {
+		return fmt.Errorf("EraseUser(u-bob) = %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := s.EraseUser(ctx, "u-bob", "erased-2"); err != nil || ok {
+		return fmt.Errorf("EraseUser(u-bob) again = %v, %v, want false, nil", ok, err)
+	}
+
+	user, err := s.GetUserByIDUnscoped(ctx, "u-bob")
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Username != "erased-1" || !user.DeletedAt.Valid || user.ErasedAt == nil {
+		return fmt.Errorf("GetUserByIDUnscoped(u-bob) = %+v, want erased as erased-1", user)
+	}
+	if user, err := s.GetUserByUsernameUnscoped(ctx, "bob"); err != nil || user != nil {
+		return fmt.Errorf("GetUserByUsernameUnscoped(bob) = %+v, %v, want nil, nil", user, err)
+	}
+	if ok, err := s.ReactivateUser(ctx, "u-bob"); err != nil || ok {
+		return fmt.Errorf("ReactivateUser(u-bob) = %v, %v, want false, nil", ok, err)
+	}
+
+	// A deactivated user can still be erased.
+	if _, err := s.DeactivateUser(ctx, "u-dave"); err != nil {
+		return err
+	}
+	if ok, err := s.EraseUser(ctx, "u-dave", "erased-3"); err != nil || !ok {
+		return fmt.Errorf("EraseUser(u-dave) = %v, %v, want true, nil", ok, err)
+	}
+
+	// Erased usernames are free again.
+	if err := s.CreateUser(ctx, &models.User{ID: "u-bob2", Username: "bob", Rating: 1000}); err != nil {
+		return fmt.Errorf("CreateUser reusing an erased username: %w", err)
+	}
+
+	users, total, err := s.GetLeaderboard(ctx, 0, 100)
+	if err != nil {
+		return err
+	}
+	if err := expect("GetLeaderboard after erasures", usernames(users), []string{"carol", "alice", "erin", "bob", "frank"}); err != nil {
+		return err
+	}
+	return expect("GetLeaderboard total after erasures", total, int64(len(fixture)-1))
+}
+
+
+func testTenants(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+	other := tenant.WithID(ctx, "storetest-other")
+
+	if user, err := s.GetUserByID(other, "u-alice"); err != nil || user != nil {
+		return fmt.Errorf("GetUserByID(u-alice) in another tenant = %+v, %v, want nil, nil", user, err)
+	}
+	if user, err := s.GetUserByUsername(other, "alice"); err != nil || user != nil {
+		return fmt.Errorf("GetUserByUsername(alice) in another tenant = %+v, %v, want nil, nil", user, err)
+	}
+
+	// Ids and usernames are only unique within a tenant.
+	if err := s.CreateUser(other, &models.User{ID: "u-alice", Username: "alice", Rating: 100}); err != nil {
+		return fmt.Errorf("CreateUser(u-alice) in another tenant: %w", err)
+	}
+	if err := s.UpdateUserRating(other, "u-frank", 4000); err != nil {
+		return err
+	}
+
+	users, total, err := s.GetLeaderboard(other, 0, 100)
+	if err != nil {
+		return err
+	}
+	if err := expect("GetLeaderboard in another tenant", usernames(users), []string{"alice"}); err != nil {
+		return err
+	}
+	if err := expect("GetLeaderboard total in another tenant", total, int64(1)); err != nil {
+		return err
+	}
+	rank, err := s.CalculateRank(other, "u-alice")
+	if err != nil {
+		return err
+	}
+	if err := expect("CalculateRank(u-alice) in another tenant", rank, int64(1)); err != nil {
+		return err
+	}
+
+	users, _, err = s.GetLeaderboard(ctx, 0, 100)
+	if err != nil {
+		return err
+	}
+	if err := expect("GetLeaderboard after writes to another tenant", usernames(users), fixtureOrder); err != nil {
+		return err
+	}
+	user, err := s.GetUserByID(ctx, "u-alice")
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Rating != 2000 || user.TenantID != tenant.ID(ctx) {
+		return fmt.Errorf("GetUserByID(u-alice) = %+v, want untouched", user)
+	}
+	return nil
+}
+
+// testUnitOfWork checks that locks read like lookups and commit hooks wait
+// for the commit.
+func testUnitOfWork(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+
+	var hooked []string
+	err := s.InTx(ctx, func(ctx context.Context) error {
+		user, err := s.LockUser(ctx, "u-alice")
+		if err != nil {
+			return err
+		}
+		if err := expect("LockUser", user != nil && user.Username == "alice", true); err != nil {
+			return err
+		}
+		missing, err := s.LockUser(ctx, "u-nobody")
+		if err != nil {
+			return err
+		}
+		if err := expect("LockUser of a missing user", missing, (*models.User)(nil)); err != nil {
+			return err
+		}
+		if err := s.LockUsername(ctx, "ALICE"); err != nil {
+			return err
+		}
+		if err := s.UpdateUserRating(ctx, "u-alice", 3000); err != nil {
+			return err
+		}
+
+		repository.AfterCommit(ctx, func(context.Context) { hooked = append(hooked, "outer") })
+		// A nested unit of work joins this one.
+		return s.InTx(ctx, func(ctx context.Context) error {
+			repository.AfterCommit(ctx, func(context.Context) { hooked = append(hooked, "inner") })
+			return expect("hooks run before commit", len(hooked), 0)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if err := expect("hooks after commit", hooked, []string{"outer", "inner"}); err != nil {
+		return err
+	}
+	user, err := s.GetUserByID(ctx, "u-alice")
+	if err != nil {
+		return err
+	}
+	return expect("rating after commit", user.Rating, int32(3000))
+}
+
+// testRollback checks that a unit of work whose fn fails leaves no write
+// behind and runs none of its commit hooks.
+func testRollback(ctx context.Context, s repository.UserStore) error {
+	if err := seed(ctx, s); err != nil {
+		return err
+	}
+
+	var hooked []string
+	errRollback := errors.New("rollback")
+	err := s.InTx(ctx, func(ctx context.Context) error {
+		repository.AfterCommit(ctx, func(context.Context) { hooked = append(hooked, "rolled back") })
+		if err := s.UpdateUserRating(ctx, "u-alice", 3000); err != nil {
+			return err
+		}
+		if err := s.UpdateUserProfile(ctx, "u-bob", "robert", models.UserMetadata{"team": "red"}); err != nil {
+			return err
+		}
+		if _, err := s.DeactivateUser(ctx, "u-carol"); err != nil {
+			return err
+		}
+		if err := s.CreateUser(ctx, &models.User{ID: "u-gina", Username: "gina", Rating: 1200}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		return fmt.Errorf("InTx: got %v, want the error of fn", err)
+	}
+	if err := expect("hooks after rollback", len(hooked), 0); err != nil {
+		return err
+	}
+
+	users, total, err := s.GetLeaderboard(ctx, 0, -1)
+	if err != nil {
+		return err
+	}
+	if err := expect("leaderboard after rollback", usernames(users), fixtureOrder); err != nil {
+		return err
+	}
+	if err := expect("count after rollback", total, int64(len(fixture))); err != nil {
+		return err
+	}
+	alice, err := s.GetUserByID(ctx, "u-alice")
+	if err != nil {
+		return err
+	}
+	if err := expect("rating after rollback", alice.Rating, int32(2000)); err != nil {
+		return err
+	}
+	bob, err := s.GetUserByID(ctx, "u-bob")
+	if err != nil {
+		return err
+	}
+	if err := expect("metadata after rollback", len(bob.Metadata), 0); err != nil {
+		return err
+	}
+	gina, err := s.GetUserByIDUnscoped(ctx, "u-gina")
+	if err != nil {
+		return err
+	}
+	return expect("user created in a rolled back unit of work", gina, (*models.User)(nil))
+}
+
+// testUsernameLock checks that a username locked in one unit of work, in any
+// case, is not locked in another until the first ends.
+func testUsernameLock(ctx context.Context, s repository.UserStore) error {
+	locked := make(chan struct{})
+	done := make(chan error, 1)
+	err := s.InTx(ctx, func(txCtx context.Context) error {
+		if err := s.LockUsername(txCtx, "gina"); err != nil {
+			return err
+		}
+		// The second unit of work starts from ctx, so it does not join this one.
+		go func() {
+			done <- s.InTx(ctx, func(ctx context.Context) error {
+				if err := s.LockUsername(ctx, "GINA"); err != nil {
+					return err
+				}
+				close(locked)
+				return nil
+			})
+		}()
+		select {
+		case <-locked:
+			return errors.New("second unit of work locked a username the first holds")
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return <-done
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"leaderboard-system/tenant"
+)
+
+// scoped limits every query built on db to the rows of the tenant ctx acts
+// for. The result can be reused for several queries like a fresh session.
+// Raw SQL and joins must still name the tenant themselves.
+func scoped(ctx context.Context, db *gorm.DB) *gorm.DB {
+	return db.Where("tenant_id = ?", tenant.ID(ctx)).Session(&gorm.Session{})
+}
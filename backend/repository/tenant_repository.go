@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"leaderboard-system/models"
+)
+
+
+type TenantRepository struct {
+	db *gorm.DB
+}
+
+
+func NewTenantRepository(db *gorm.DB) *TenantRepository {
+	return &TenantRepository{db: db}
+}
+
+
+func (r *TenantRepository) CreateTenant(ctx context.Context, t *models.Tenant) error {
+	if err := r.db.WithContext(ctx).Create(t).Error; err != nil {
+		return fmt.Errorf("failed to create tenant: %w", err)
+	}
+	return nil
+}
+
+// GetTenant returns nil and no error when the tenant does not exist.
+func (r *TenantRepository) GetTenant(ctx context.Context, id string) (*models.Tenant, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+// GetTenantByAPIKeyHash returns the tenant whose API key hashes to hash, or
+// nil when there is none.
+func (r *TenantRepository) GetTenantByAPIKeyHash(ctx context.Context, hash string) (*models.Tenant, error) {
+	return r.first(ctx, "api_key_hash = ?", hash)
+}
+
+
+func (r *TenantRepository) first(ctx context.Context, query string, arg interface{}) (*models.Tenant, error) {
+	var t models.Tenant
+	if err := r.db.WithContext(ctx).Where(query, arg).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	return &t, nil
+}
+
+
+func (r *TenantRepository) ListTenants(ctx context.Context) ([]models.Tenant, error) {
+	var tenants []models.Tenant
+	if err := r.db.WithContext(ctx).Order("id ASC").Find(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+// UpdateTenant sets the given columns of tenant id and reports whether it
+// exists.
+func (r *TenantRepository) UpdateTenant(ctx context.Context, id string, updates map[string]interface{}) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&models.Tenant{}).
+		Where("id = ?", id).
+		Updates(updates)
+	if res.Error != nil {
+		return false, fmt.Errorf("failed to update tenant: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
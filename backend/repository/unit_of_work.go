@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+	"leaderboard-system/database"
+)
+
+// unitOfWork is the transaction a context runs in, with the hooks waiting for
+// it to commit. Repositories pick the transaction up from the context, so
+// service code passes ctx as usual and every call made with it joins.
+type unitOfWork struct {
+	tx *gorm.DB
+
+	mu    sync.Mutex
+	hooks []func(ctx context.Context)
+	// last are the BeforeCommit steps, run in order once fn has returned.
+	last []func(ctx context.Context) error
+}
+
+type unitOfWorkKey struct{}
+
+
+func unitOfWorkFrom(ctx context.Context) *unitOfWork {
+	uow, _ := ctx.Value(unitOfWorkKey{}).(*unitOfWork)
+	return uow
+}
+
+// inTx runs fn in a transaction on db. Every repository call made with the
+// context fn is given, reads included, goes through the transaction; a call
+// made in a unit of work already joins it instead of starting another. fn's
+// error rolls the transaction back and is returned.
+//
+// A transaction that fails transiently, like a deadlock victim, is run again
+// from the start under db's retry policy, so fn must not act outside the
+// transaction except through AfterCommit. A failed commit is not retried:
+// it may have committed.
+func inTx(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error) error {
+	if unitOfWorkFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	policy := database.RetryPolicyOf(db)
+	for attempt := 1; ; attempt++ {
+		uow := &unitOfWork{}
+		var fnErr error
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			uow.tx = tx
+			txCtx := context.WithValue(ctx, unitOfWorkKey{}, uow)
+			fnErr = fn(txCtx)
+			if fnErr == nil {
+				fnErr = uow.committing(txCtx)
+			}
+			return fnErr
+		})
+		if err == nil {
+			uow.committed(ctx)
+			return nil
+		}
+		if err != fnErr || attempt >= policy.Attempts || !database.Retryable(err) {
+			return err
+		}
+		if werr := policy.Backoff(ctx, attempt); werr != nil {
+			return err
+		}
+	}
+}
+
+// AfterCommit runs fn once the unit of work ctx is part of commits, or at
+// once when ctx is not in one. A rollback drops fn. Side effects others can
+// observe, like cache invalidation and events, go here so they never announce
+// a write that did not happen. fn gets a context outside the transaction.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	uow := unitOfWorkFrom(ctx)
+	if uow == nil {
+		fn(ctx)
+		return
+	}
+
+	uow.mu.Lock()
+	defer uow.mu.Unlock()
+	uow.hooks = append(uow.hooks, fn)
+}
+
+// BeforeCommit runs fn as the last step of the unit of work ctx is part of,
+// after the function given to InTx returns and right before the commit, or
+// at once when ctx is not in one. fn's error rolls the unit of work back.
+// Locks fn takes are held only from there to the commit, so writes that must
+// commit in the order they are made, like change log versions, lock here.
+func BeforeCommit(ctx context.Context, fn func(ctx context.Context) error) error {
+	uow := unitOfWorkFrom(ctx)
+	if uow == nil {
+		return fn(ctx)
+	}
+
+	uow.mu.Lock()
+	defer uow.mu.Unlock()
+	uow.last = append(uow.last, fn)
+	return nil
+}
+
+// committing runs the BeforeCommit steps in the transaction, stopping at the
+// first that fails.
+func (uow *unitOfWork) committing(ctx context.Context) error {
+	for {
+		uow.mu.Lock()
+		if len(uow.last) == 0 {
+			uow.mu.Unlock()
+			return nil
+		}
+		fn := uow.last[0]
+		uow.last = uow.last[1:]
+		uow.mu.Unlock()
+
+		if err := fn(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+
+func (uow *unitOfWork) committed(ctx context.Context) {
+	uow.mu.Lock()
+	hooks := uow.hooks
+	uow.hooks = nil
+	uow.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn(ctx)
+	}
+}
+
+// conn returns the connection a write with ctx should use: its unit of
+// work's transaction, or else db.
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if uow := unitOfWorkFrom(ctx); uow != nil && uow.tx != nil {
+		return uow.tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
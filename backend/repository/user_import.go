@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+	"leaderboard-system/tenant"
+)
+
+// UserImporter bulk loads users. UserRepository implements it with COPY and
+// MemoryUserStore in process.
+type UserImporter interface {
+	// ImportUsers upserts one batch into the tenant ctx acts for, in a unit
+	// of work of its own. within runs in it once the rows are written, with
+	// the batch's result, and its error rolls the batch back. A batch that
+	// fails transiently is run again whole, within included.
+	ImportUsers(ctx context.Context, rows []ImportRow, within func(ctx context.Context, result *ImportBatchResult) error) (*ImportBatchResult, error)
+	// RebuildRankIndexes rebuilds what a large import leaves bloated.
+	RebuildRankIndexes(ctx context.Context) error
+}
+
+var _ UserImporter = (*UserRepository)(nil)
+var _ UserImporter = (*MemoryUserStore)(nil)
+
+var errImportInUnitOfWork = errors.New("bulk import cannot join a unit of work")
+
+// ImportRow is one validated row of a bulk import. A row without a user id in
+// the source gets a fresh ID and MatchUsername, so it updates the user with
+// that username if there is one.
+type ImportRow struct {
+	Line          int64
+	ID            string
+	Username      string
+	Rating        int32
+	MatchUsername bool
+}
+
+
+type ImportRejection struct {
+	Line   int64
+	Reason string
+}
+
+// ImportBatchResult reports one batch. UpdatedIDs are the existing users the
+// batch changed, whose cache entries are now stale, and RatingChanges those
+// of them whose rating moved.
+type ImportBatchResult struct {
+	Inserted      int64
+	Updated       int64
+	UpdatedIDs    []string
+	RatingChanges []ImportRatingChange
+	Rejected      []ImportRejection
+}
+
+// ImportRatingChange is an existing user's rating before and after a batch.
+type ImportRatingChange struct {
+	ID        string
+	Username  string
+	OldRating int32
+	NewRating int32
+}
+
+// importRejections move rows that cannot be upserted out of the staging table,
+// returning their lines. Those checking users are given the tenant imported
+// into as $1. Usernames are compared case-insensitively, like the existence
+// check in UserService.CreateUser.
+var importRejections = []struct {
+	reason    string
+	sql       string
+	withUsers bool
+}{
+	{"user is deactivated", `
+		DELETE FROM user_import i USING users u
+		WHERE u.tenant_id = $1 AND u.id = i.id AND u.deleted_at IS NOT NULL
+		RETURNING i.line`, true},
+	{"username is taken by another user", `
+		DELETE FROM user_import i USING users u
+		WHERE u.tenant_id = $1 AND LOWER(u.username) = LOWER(i.username) AND u.id <> i.id
+		RETURNING i.line`, true},
+	{"user appears earlier in the import", `
+		DELETE FROM user_import i
+		WHERE EXISTS (SELECT 1 FROM user_import j WHERE j.id = i.id AND j.line < i.line)
+		RETURNING i.line`, false},
+}
+
+// ImportUsers COPYs rows into a temporary table, rejects those that would
+// violate a constraint, and inserts the rest or, on an id conflict, updates
+// the username and rating. COPY needs the pgx connection itself, so the unit
+// of work runs on one pinned connection, under the usual retry policy; for
+// the same reason it cannot join a unit of work ctx is already in.
+func (r *UserRepository) ImportUsers(ctx context.Context, rows []ImportRow, within func(ctx context.Context, result *ImportBatchResult) error) (*ImportBatchResult, error) {
+	if unitOfWorkFrom(ctx) != nil {
+		return nil, errImportInUnitOfWork
+	}
+
+	var result *ImportBatchResult
+	err := r.db.WithContext(ctx).Connection(func(db *gorm.DB) error {
+		pinned, ok := db.Statement.ConnPool.(*sql.Conn)
+		if !ok {
+			return fmt.Errorf("bulk import needs a pinned connection, got %T", db.Statement.ConnPool)
+		}
+		return inTx(ctx, db, func(ctx context.Context) error {
+			err := pinned.Raw(func(driverConn interface{}) error {
+				c, ok := driverConn.(*stdlib.Conn)
+				if !ok {
+					return fmt.Errorf("bulk import needs a pgx connection, got %T", driverConn)
+				}
+				var err error
+				result, err = importBatch(ctx, c.Conn(), tenant.ID(ctx), rows)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			return within(ctx, result)
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import users: %w", err)
+	}
+	return result, nil
+}
+
+// importBatch runs on the connection of the transaction ImportUsers opened,
+// so everything it does commits or rolls back with the unit of work.
+func importBatch(ctx context.Context, conn *pgx.Conn, tenantID string, rows []ImportRow) (*ImportBatchResult, error) {
+	if _, err := conn.Exec(ctx, `
+		CREATE TEMP TABLE user_import (
+			line BIGINT NOT NULL,
+			id VARCHAR(255) NOT NULL,
+			username VARCHAR(255) NOT NULL,
+			rating INTEGER NOT NULL,
+			match_username BOOLEAN NOT NULL
+		) ON COMMIT DROP`); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.CopyFrom(ctx,
+		pgx.Identifier{"user_import"},
+		[]string{"line", "id", "username", "rating", "match_username"},
+		pgx.CopyFromSlice(len(rows), func(i int) ([]interface{}, error) {
+			row := rows[i]
+			return []interface{}{row.Line, row.ID, row.Username, row.Rating, row.MatchUsername}, nil
+		}),
+	); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Exec(ctx, `
+		UPDATE user_import i SET id = u.id
+		FROM users u
+		WHERE u.tenant_id = $1 AND i.match_username AND LOWER(u.username) = LOWER(i.username)`, tenantID); err != nil {
+		return nil, err
+	}
+
+	result := &ImportBatchResult{}
+	for _, rej := range importRejections {
+		var args []interface{}
+		if rej.withUsers {
+			args = append(args, tenantID)
+		}
+		lines, err := queryLines(ctx, conn, rej.sql, args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			result.Rejected = append(result.Rejected, ImportRejection{Line: line, Reason: rej.reason})
+		}
+	}
+
+	// Rows that already match are not touched, so they keep their updated_at
+	// and are not reported as updated. The users being updated are locked
+	// first, so the ratings read as old are the ones the upsert replaces.
+	upserted, err := conn.Query(ctx, `
+		WITH old AS (
+			SELECT u.id, u.rating FROM users u JOIN user_import i ON u.id = i.id
+			WHERE u.tenant_id = $1
+			FOR UPDATE OF u
+		)
+		INSERT INTO users (tenant_id, id, username, rating, created_at, updated_at)
+		SELECT $1, id, username, rating, now(), now() FROM user_import
+		ON CONFLICT (tenant_id, id) DO UPDATE
+		SET username = EXCLUDED.username, rating = EXCLUDED.rating, updated_at = EXCLUDED.updated_at
+		WHERE users.username <> EXCLUDED.username OR users.rating <> EXCLUDED.rating
+		RETURNING id, username, rating, (xmax = 0) AS inserted,
+			COALESCE((SELECT old.rating FROM old WHERE old.id = users.id), rating) AS old_rating`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for upserted.Next() {
+		var change ImportRatingChange
+		var inserted bool
+		if err := upserted.Scan(&change.ID, &change.Username, &change.NewRating, &inserted, &change.OldRating); err != nil {
+			upserted.Close()
+			return nil, err
+		}
+		if inserted {
+			result.Inserted++
+			continue
+		}
+		result.Updated++
+		result.UpdatedIDs = append(result.UpdatedIDs, change.ID)
+		if change.OldRating != change.NewRating {
+			result.RatingChanges = append(result.RatingChanges, change)
+		}
+	}
+	upserted.Close()
+	if err := upserted.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+
+func queryLines(ctx context.Context, conn *pgx.Conn, sql string, args ...interface{}) ([]int64, error) {
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []int64
+	for rows.Next() {
+		var line int64
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// RebuildRankIndexes rebuilds the indexes ranks and pages are read from, and
+// refreshes planner statistics, after a bulk load has bloated them. Reads and
+// writes continue meanwhile, but on a large table it takes minutes.
+func (r *UserRepository) RebuildRankIndexes(ctx context.Context) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for reindex: %w", err)
+	}
+	defer conn.Close()
+
+	// A rebuild can take far longer than the statement timeout meant for
+	// request queries. RESET restores the connection's default before it
+	// goes back to the pool.
+	if _, err := conn.ExecContext(ctx, "SET statement_timeout = 0"); err != nil {
+		return fmt.Errorf("failed to lift statement timeout: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "RESET statement_timeout")
+
+	for _, stmt := range []string{
+		"REINDEX INDEX CONCURRENTLY idx_users_rating",
+		"REINDEX INDEX CONCURRENTLY idx_users_rating_username",
+		"ANALYZE users",
+	} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to rebuild rank indexes: %s: %w", stmt, err)
+		}
+	}
+	return nil
+}
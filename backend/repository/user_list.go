@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"leaderboard-system/models"
+)
+
+type UserSort string
+
+const (
+	SortByCreatedAt UserSort = "created_at"
+	SortByRating    UserSort = "rating"
+	SortByUsername  UserSort = "username"
+)
+
+// UserListQuery filters and orders active users. Zero fields do not filter.
+// Users that sort equal are ordered by ID, so pages do not overlap.
+type UserListQuery struct {
+	// UsernamePrefix matches case-insensitively.
+	UsernamePrefix string
+	MinRating      int32
+	MaxRating      int32
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+
+	Sort   UserSort
+	Desc   bool
+	Offset int
+	Limit  int
+}
+
+// matches reports whether u passes the query's filters.
+func (q UserListQuery) matches(u *models.User) bool {
+	switch {
+	case q.UsernamePrefix != "" && !strings.HasPrefix(strings.ToLower(u.Username), strings.ToLower(q.UsernamePrefix)):
+		return false
+	case q.MinRating != 0 && u.Rating < q.MinRating:
+		return false
+	case q.MaxRating != 0 && u.Rating > q.MaxRating:
+		return false
+	case !q.CreatedAfter.IsZero() && !u.CreatedAt.After(q.CreatedAfter):
+		return false
+	case !q.CreatedBefore.IsZero() && !u.CreatedAt.Before(q.CreatedBefore):
+		return false
+	}
+	return true
+}
+
+// less orders a before b by the query's sort.
+func (q UserListQuery) less(a, b *models.User) bool {
+	cmp := 0
+	switch q.Sort {
+	case SortByRating:
+		cmp = compareInt(int64(a.Rating), int64(b.Rating))
+	case SortByUsername:
+		cmp = strings.Compare(a.Username, b.Username)
+	default:
+		cmp = compareInt(a.CreatedAt.UnixNano(), b.CreatedAt.UnixNano())
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.ID, b.ID)
+	}
+	if q.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// ListUsers returns one page of the active users the query selects and how
+// many it selects in all.
+func (r *UserRepository) ListUsers(ctx context.Context, q UserListQuery) ([]models.User, int64, error) {
+	db := r.reader(ctx).Model(&models.User{})
+	if q.UsernamePrefix != "" {
+		db = db.Where("LOWER(username) LIKE ?", escapeLike(strings.ToLower(q.UsernamePrefix))+"%")
+	}
+	if q.MinRating != 0 {
+		db = db.Where("rating >= ?", q.MinRating)
+	}
+	if q.MaxRating != 0 {
+		db = db.Where("rating <= ?", q.MaxRating)
+	}
+	if !q.CreatedAfter.IsZero() {
+		db = db.Where("created_at > ?", q.CreatedAfter)
+	}
+	if !q.CreatedBefore.IsZero() {
+		db = db.Where("created_at < ?", q.CreatedBefore)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	column := "created_at"
+	switch q.Sort {
+	case SortByRating:
+		column = "rating"
+	case SortByUsername:
+		column = "username"
+	}
+	dir := "ASC"
+	if q.Desc {
+		dir = "DESC"
+	}
+
+	var users []models.User
+	if err := db.
+		Order(fmt.Sprintf("%s %s, id %s", column, dir, dir)).
+		Offset(q.Offset).
+		Limit(q.Limit).
+		Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, total, nil
+}
@@ -4,33 +4,86 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"leaderboard-system/models"
+	"leaderboard-system/tenant"
 )
 
  
 type UserRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	reads ReadRouter
 }
 
- 
-func NewUserRepository(db *gorm.DB) *UserRepository {
-	return &UserRepository{db: db}
+// NewUserRepository writes to db and reads through reads, which may be nil
+// to read from db as well.
+func NewUserRepository(db *gorm.DB, reads ReadRouter) *UserRepository {
+	return &UserRepository{db: db, reads: reads}
+}
+
+
+// reader and writer are limited to the users of the tenant ctx acts for.
+func (r *UserRepository) reader(ctx context.Context) *gorm.DB {
+	return scoped(ctx, readDB(ctx, r.db, r.reads))
+}
+
+
+func (r *UserRepository) writer(ctx context.Context) *gorm.DB {
+	return scoped(ctx, conn(ctx, r.db))
 }
 
  
 func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
-	if err := r.db.WithContext(ctx).Create(user).Error; err != nil {
+	user.TenantID = tenant.ID(ctx)
+	if err := conn(ctx, r.db).Create(user).Error; err != nil {
+		if isUniqueViolation(err) {
+			err = ErrDuplicateUser
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 	return nil
 }
 
+
+func (r *UserRepository) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return inTx(ctx, r.db, fn)
+}
+
+
+func (r *UserRepository) LockUser(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	if err := r.writer(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", userID).
+		First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock user: %w", err)
+	}
+	return &user, nil
+}
+
+// LockUsername takes a transaction-scoped advisory lock keyed by tenant and
+// lowercased username. The two-int form keeps it apart from the migration
+// lock's bigint key. Outside a transaction the lock is released at once.
+func (r *UserRepository) LockUsername(ctx context.Context, username string) error {
+	if err := conn(ctx, r.db).
+		Exec("SELECT pg_advisory_xact_lock(hashtext(?), hashtext(?))", tenant.ID(ctx), strings.ToLower(username)).Error; err != nil {
+		return fmt.Errorf("failed to lock username: %w", err)
+	}
+	return nil
+}
+
  
 func (r *UserRepository) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
 	var user models.User
-	if err := r.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+	if err := r.reader(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -42,7 +95,7 @@ func (r *UserRepository) GetUserByID(ctx context.Context, userID string) (*model
  
 func (r *UserRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
 	var user models.User
-	if err := r.db.WithContext(ctx).
+	if err := r.reader(ctx).
 		Where("LOWER(username) = LOWER(?)", username).
 		First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -55,7 +108,7 @@ func (r *UserRepository) GetUserByUsername(ctx context.Context, username string)
 
  
 func (r *UserRepository) UpdateUserRating(ctx context.Context, userID string, newRating int32) error {
-	if err := r.db.WithContext(ctx).
+	if err := r.writer(ctx).
 		Model(&models.User{}).
 		Where("id = ?", userID).
 		Update("rating", newRating).Error; err != nil {
@@ -64,18 +117,46 @@ func (r *UserRepository) UpdateUserRating(ctx context.Context, userID string, ne
 	return nil
 }
 
+// UpdateUserProfile sets the username and metadata of an active user. The
+// username copied into user_ranks changes in the same transaction; the user's
+// place among equally rated users follows at the next rank sync.
+func (r *UserRepository) UpdateUserProfile(ctx context.Context, userID, username string, metadata models.UserMetadata) error {
+	tenantID := tenant.ID(ctx)
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		res := tx.
+			Model(&models.User{}).
+			Where("tenant_id = ? AND id = ?", tenantID, userID).
+			Updates(map[string]interface{}{
+				"username": username,
+				"metadata": metadata,
+			})
+		if res.Error != nil {
+			err := res.Error
+			if isUniqueViolation(err) {
+				err = ErrDuplicateUser
+			}
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		return renameRankedUser(tx, tenantID, userID, username)
+	})
+}
+
  
 func (r *UserRepository) GetLeaderboard(ctx context.Context, offset, limit int) ([]models.User, int64, error) {
+	db := r.reader(ctx)
 	var users []models.User
 	var total int64
 
  
-	if err := r.db.WithContext(ctx).Model(&models.User{}).Count(&total).Error; err != nil {
+	if err := db.Model(&models.User{}).Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count users: %w", err)
 	}
 
  
-	if err := r.db.WithContext(ctx).
+	if err := db.
 		Order("rating DESC, username ASC").
 		Offset(offset).
 		Limit(limit).
@@ -88,19 +169,24 @@ func (r *UserRepository) GetLeaderboard(ctx context.Context, offset, limit int)
 
  
 func (r *UserRepository) CalculateRank(ctx context.Context, userID string) (int64, error) {
+	db := r.reader(ctx)
 	var rank int64
  
 	var targetRating int32
-	if err := r.db.WithContext(ctx).
+	res := db.
 		Model(&models.User{}).
 		Where("id = ?", userID).
 		Select("rating").
-		Scan(&targetRating).Error; err != nil {
-		return 0, fmt.Errorf("failed to get user rating: %w", err)
+		Scan(&targetRating)
+	if res.Error != nil {
+		return 0, fmt.Errorf("failed to get user rating: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return 0, nil
 	}
 
  
-	if err := r.db.WithContext(ctx).
+	if err := db.
 		Model(&models.User{}).
 		Where("rating > ?", targetRating).
 		Count(&rank).Error; err != nil {
@@ -109,10 +195,99 @@ func (r *UserRepository) CalculateRank(ctx context.Context, userID string) (int6
 
 	return rank + 1, nil
 }
+
+func (r *UserRepository) CountUsersAbove(ctx context.Context, rating int32) (int64, error) {
+	var count int64
+	if err := r.reader(ctx).
+		Model(&models.User{}).
+		Where("rating > ?", rating).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count users above rating: %w", err)
+	}
+	return count, nil
+}
+
+// RanksInRange counts the users at or above maxRating once and adds the
+// window's own counts, summed down from the top, so it costs one query
+// however many ratings the window holds.
+func (r *UserRepository) RanksInRange(ctx context.Context, minRating, maxRating int32) (map[int32]int64, error) {
+	var rows []struct {
+		Rating int32
+		Rank   int64
+	}
+	if err := r.reader(ctx).
+		Table("users AS u").
+		Select("u.rating, (SELECT COUNT(*) FROM users o WHERE o.tenant_id = u.tenant_id AND o.rating >= ? AND o.deleted_at IS NULL) + SUM(COUNT(*)) OVER (ORDER BY u.rating DESC) - COUNT(*) + 1 AS rank", maxRating).
+		Where("u.deleted_at IS NULL AND u.rating >= ? AND u.rating < ?", minRating, maxRating).
+		Group("u.tenant_id, u.rating").
+		Order("u.rating DESC").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to rank ratings in range: %w", err)
+	}
+
+	ranks := make(map[int32]int64, len(rows))
+	for _, row := range rows {
+		ranks[row.Rating] = row.Rank
+	}
+	return ranks, nil
+}
+
+
+type RatingRange struct {
+	Min int32
+	Max int32
+}
+
+// GetRankedUsers returns users whose id is in ids or whose rating falls in any
+// of the inclusive ranges, in leaderboard order with their current rank.
+func (r *UserRepository) GetRankedUsers(ctx context.Context, ids []string, ranges []RatingRange, limit int) ([]models.LeaderboardEntry, error) {
+	var entries []models.LeaderboardEntry
+
+	cond := r.db.Where("u.id IN ?", ids)
+	for _, rr := range ranges {
+		cond = cond.Or("u.rating BETWEEN ? AND ?", rr.Min, rr.Max)
+	}
+
+	if err := r.reader(ctx).
+		Table("users AS u").
+		Select("u.username, u.rating, (SELECT COUNT(*) FROM users o WHERE o.tenant_id = u.tenant_id AND o.rating > u.rating AND o.deleted_at IS NULL) + 1 AS rank").
+		Where("u.deleted_at IS NULL").
+		Where(cond).
+		Order("u.rating DESC, u.username ASC").
+		Limit(limit).
+		Scan(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to get ranked users: %w", err)
+	}
+	return entries, nil
+}
+
+
+type LeaderboardCursor struct {
+	Rating   int32
+	Username string
+}
+
+// GetLeaderboardAfter returns up to limit users in leaderboard order, starting
+// after cursor, or from the top when cursor is nil. Unlike an offset it stays
+// cheap however deep into the board the caller walks.
+func (r *UserRepository) GetLeaderboardAfter(ctx context.Context, cursor *LeaderboardCursor, limit int) ([]models.User, error) {
+	var users []models.User
+
+	query := r.reader(ctx).Order("rating DESC, username ASC").Limit(limit)
+	if cursor != nil {
+		query = query.Where("rating < ? OR (rating = ? AND username > ?)", cursor.Rating, cursor.Rating, cursor.Username)
+	}
+
+	if err := query.Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to walk leaderboard: %w", err)
+	}
+	return users, nil
+}
+
  
 func (r *UserRepository) GetUsersByRating(ctx context.Context, rating int32) ([]models.User, error) {
 	var users []models.User
-	if err := r.db.WithContext(ctx).
+	if err := r.reader(ctx).
 		Where("rating = ?", rating).
 		Order("username ASC").
 		Find(&users).Error; err != nil {
@@ -124,7 +299,7 @@ func (r *UserRepository) GetUsersByRating(ctx context.Context, rating int32) ([]
  
 func (r *UserRepository) SearchUserByUsername(ctx context.Context, username string, limit int) ([]models.User, error) {
 	var users []models.User
-	if err := r.db.WithContext(ctx).
+	if err := r.reader(ctx).
 		Where("LOWER(username) LIKE LOWER(?)", username+"%").
 		Limit(limit).
 		Order("username ASC").
@@ -137,7 +312,7 @@ func (r *UserRepository) SearchUserByUsername(ctx context.Context, username stri
  
 func (r *UserRepository) GetAllUsers(ctx context.Context) ([]models.User, error) {
 	var users []models.User
-	if err := r.db.WithContext(ctx).
+	if err := r.reader(ctx).
 		Order("rating DESC, username ASC").
 		Find(&users).Error; err != nil {
 		return nil, fmt.Errorf("failed to get all users: %w", err)
@@ -145,17 +320,96 @@ func (r *UserRepository) GetAllUsers(ctx context.Context) ([]models.User, error)
 	return users, nil
 }
 
- 
-func (r *UserRepository) DeleteUser(ctx context.Context, userID string) error {
-	if err := r.db.WithContext(ctx).Delete(&models.User{}, "id = ?", userID).Error; err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+// GetUserByIDUnscoped is GetUserByID including deactivated and erased users.
+func (r *UserRepository) GetUserByIDUnscoped(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	if err := r.reader(ctx).Unscoped().Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	return nil
+	return &user, nil
+}
+
+// GetUserByUsernameUnscoped is GetUserByUsername including deactivated users,
+// whose usernames stay reserved until they are erased.
+func (r *UserRepository) GetUserByUsernameUnscoped(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	if err := r.reader(ctx).
+		Unscoped().
+		Where("LOWER(username) = LOWER(?)", username).
+		First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user by username: %w", err)
+	}
+	return &user, nil
+}
+
+// DeactivateUser soft-deletes an active user and reports whether it did.
+func (r *UserRepository) DeactivateUser(ctx context.Context, userID string) (bool, error) {
+	res := r.writer(ctx).Delete(&models.User{}, "id = ?", userID)
+	if res.Error != nil {
+		return false, fmt.Errorf("failed to deactivate user: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// ReactivateUser restores a deactivated user that has not been erased and
+// reports whether it did.
+func (r *UserRepository) ReactivateUser(ctx context.Context, userID string) (bool, error) {
+	res := r.writer(ctx).
+		Unscoped().
+		Model(&models.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL AND erased_at IS NULL", userID).
+		Update("deleted_at", nil)
+	if res.Error != nil {
+		return false, fmt.Errorf("failed to reactivate user: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// EraseUser replaces the username of a user not yet erased with username,
+// clears its metadata and deactivates it for good. It reports whether it did. The username copied
+// into user_ranks is replaced in the same transaction.
+func (r *UserRepository) EraseUser(ctx context.Context, userID, username string) (bool, error) {
+	now := time.Now().UTC()
+	tenantID := tenant.ID(ctx)
+	erased := false
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		res := tx.
+			Unscoped().
+			Model(&models.User{}).
+			Where("tenant_id = ? AND id = ? AND erased_at IS NULL", tenantID, userID).
+			Updates(map[string]interface{}{
+				"username":   username,
+				"metadata":   models.UserMetadata{},
+				"erased_at":  now,
+				"deleted_at": gorm.Expr("COALESCE(deleted_at, ?)", now),
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to erase user: %w", res.Error)
+		}
+		erased = res.RowsAffected > 0
+		if !erased {
+			return nil
+		}
+		return renameRankedUser(tx, tenantID, userID, username)
+	})
+	if err != nil {
+		return false, err
+	}
+	return erased, nil
 }
 
  
 func (r *UserRepository) BulkCreateUsers(ctx context.Context, users []models.User) error {
-	if err := r.db.WithContext(ctx).CreateInBatches(users, 100).Error; err != nil {
+	for i := range users {
+		users[i].TenantID = tenant.ID(ctx)
+	}
+	if err := conn(ctx, r.db).CreateInBatches(users, 100).Error; err != nil {
 		return fmt.Errorf("failed to bulk create users: %w", err)
 	}
 	return nil
@@ -164,8 +418,14 @@ func (r *UserRepository) BulkCreateUsers(ctx context.Context, users []models.Use
  
 func (r *UserRepository) GetUserCount(ctx context.Context) (int64, error) {
 	var count int64
-	if err := r.db.WithContext(ctx).Model(&models.User{}).Count(&count).Error; err != nil {
+	if err := r.reader(ctx).Model(&models.User{}).Count(&count).Error; err != nil {
 		return 0, fmt.Errorf("failed to count users: %w", err)
 	}
 	return count, nil
 }
+
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,51 @@
+package repository_test
+
+import (
+	"os"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"leaderboard-system/config"
+	"leaderboard-system/database"
+	"leaderboard-system/repository"
+	"leaderboard-system/repository/storetest"
+)
+
+// openTestDB opens and migrates the scratch Postgres database
+// TEST_DATABASE_URL names, or skips the test when it is not set. Tests empty
+// its tables, so never point it at real data.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := database.Open(&config.DatabaseConfig{DatabaseURL: dsn, MaxOpenConns: 10, MaxIdleConns: 2}, logger.Silent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		t.Cleanup(func() { sqlDB.Close() })
+	}
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	// Tests write to a second tenant, which users reference.
+	if err := db.Exec("INSERT INTO tenants (id, name) VALUES ('storetest-other', 'storetest') ON CONFLICT (id) DO NOTHING").Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestUserRepositoryConformance(t *testing.T) {
+	db := openTestDB(t)
+
+	storetest.Run(t, func() (repository.UserStore, error) {
+		if err := db.Exec("TRUNCATE users, user_ranks").Error; err != nil {
+			return nil, err
+		}
+		return repository.NewUserRepository(db, nil), nil
+	})
+}
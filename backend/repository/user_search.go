@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"leaderboard-system/models"
+)
+
+type SearchMode string
+
+const (
+	SearchPrefix    SearchMode = "prefix"
+	SearchSubstring SearchMode = "substring"
+	SearchFuzzy     SearchMode = "fuzzy"
+)
+
+// UsernameSearch matches usernames case-insensitively. Fuzzy matches are
+// those pg_trgm considers similar, at its default threshold of 0.3.
+type UsernameSearch struct {
+	Query  string
+	Mode   SearchMode
+	Offset int
+	Limit  int
+}
+
+// UsernameMatch is a matching user with the trigram similarity of its
+// username to the query, between 0 and 1.
+type UsernameMatch struct {
+	ID       string
+	Username string
+	Rating   int32
+	Score    float64
+}
+
+// UsernameSearcher is implemented by stores that can search usernames
+// themselves. Stores that cannot are searched through an in-memory index.
+type UsernameSearcher interface {
+	// UsernameSearchAvailable reports whether SearchUsernames can run, which
+	// for Postgres means pg_trgm is installed.
+	UsernameSearchAvailable(ctx context.Context) (bool, error)
+	// SearchUsernames returns one page of active users matching the search,
+	// most similar first, and the number of matches.
+	SearchUsernames(ctx context.Context, search UsernameSearch) ([]UsernameMatch, int64, error)
+}
+
+var _ UsernameSearcher = (*UserRepository)(nil)
+
+
+func (r *UserRepository) UsernameSearchAvailable(ctx context.Context) (bool, error) {
+	var ok bool
+	if err := r.reader(ctx).
+		Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')").
+		Scan(&ok).Error; err != nil {
+		return false, fmt.Errorf("failed to check for pg_trgm: %w", err)
+	}
+	return ok, nil
+}
+
+
+func (r *UserRepository) SearchUsernames(ctx context.Context, search UsernameSearch) ([]UsernameMatch, int64, error) {
+	query := strings.ToLower(search.Query)
+	db := r.reader(ctx)
+
+	matching := func() *gorm.DB {
+		q := db.Model(&models.User{})
+		switch search.Mode {
+		case SearchPrefix:
+			return q.Where("LOWER(username) LIKE ?", escapeLike(query)+"%")
+		case SearchSubstring:
+			return q.Where("LOWER(username) LIKE ?", "%"+escapeLike(query)+"%")
+		default:
+			return q.Where("LOWER(username) % ?", query)
+		}
+	}
+
+	var total int64
+	if err := matching().Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count username matches: %w", err)
+	}
+
+	var matches []UsernameMatch
+	if err := matching().
+		Select("id, username, rating, similarity(LOWER(username), ?) AS score", query).
+		Order("score DESC, username ASC").
+		Offset(search.Offset).
+		Limit(search.Limit).
+		Scan(&matches).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to search usernames: %w", err)
+	}
+	return matches, total, nil
+}
+
+// escapeLike makes s match literally in a LIKE pattern. Usernames may
+// contain the _ wildcard.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
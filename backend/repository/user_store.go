@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"leaderboard-system/models"
+)
+
+// ErrDuplicateUser is returned when a write would give two users of a tenant
+// the same ID or username.
+var ErrDuplicateUser = errors.New("duplicate key value violates unique constraint")
+
+// UserStore is the user storage the services depend on. UserRepository
+// implements it on Postgres and MemoryUserStore in process; both order the
+// leaderboard by rating descending, then username ascending, and rank ties
+// the same way, so services behave identically on either.
+type UserStore interface {
+	// InTx runs fn as one unit of work: every call made with the context fn
+	// is given commits or rolls back together, and AfterCommit hooks run
+	// only once it commits. Calls inside a unit of work join it.
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// LockUser is GetUserByID holding the user's row until the unit of work
+	// ends, so its read-then-write cannot interleave with another.
+	LockUser(ctx context.Context, userID string) (*models.User, error)
+	// LockUsername holds username, in any case, until the unit of work ends,
+	// whether or not a user has it, so a check that it is free stays true
+	// until the write that takes it.
+	LockUsername(ctx context.Context, username string) error
+
+	CreateUser(ctx context.Context, user *models.User) error
+	// GetUserByID and GetUserByUsername return nil and no error when the user
+	// does not exist. Usernames match case-insensitively.
+	GetUserByID(ctx context.Context, userID string) (*models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	UpdateUserRating(ctx context.Context, userID string, newRating int32) error
+	// UpdateUserProfile returns ErrDuplicateUser when another user has
+	// username exactly.
+	UpdateUserProfile(ctx context.Context, userID, username string, metadata models.UserMetadata) error
+	ListUsers(ctx context.Context, q UserListQuery) ([]models.User, int64, error)
+
+	GetLeaderboard(ctx context.Context, offset, limit int) ([]models.User, int64, error)
+	GetLeaderboardAfter(ctx context.Context, cursor *LeaderboardCursor, limit int) ([]models.User, error)
+	GetRankedUsers(ctx context.Context, ids []string, ranges []RatingRange, limit int) ([]models.LeaderboardEntry, error)
+
+	// CalculateRank returns 0 for a user that is not active.
+	CalculateRank(ctx context.Context, userID string) (int64, error)
+	CountUsersAbove(ctx context.Context, rating int32) (int64, error)
+	// RanksInRange returns the rank of every rating an active user holds in
+	// [minRating, maxRating), in one read.
+	RanksInRange(ctx context.Context, minRating, maxRating int32) (map[int32]int64, error)
+	GetUserCount(ctx context.Context) (int64, error)
+
+	// Deactivated users are left out of every method above. The Unscoped
+	// lookups still find them, and erased users too.
+	GetUserByIDUnscoped(ctx context.Context, userID string) (*models.User, error)
+	GetUserByUsernameUnscoped(ctx context.Context, username string) (*models.User, error)
+	// DeactivateUser, ReactivateUser and EraseUser report whether the user
+	// was in a state the change applies to. An erased user stays deactivated
+	// and cannot be reactivated.
+	DeactivateUser(ctx context.Context, userID string) (bool, error)
+	ReactivateUser(ctx context.Context, userID string) (bool, error)
+	EraseUser(ctx context.Context, userID, username string) (bool, error)
+}
+
+var _ UserStore = (*UserRepository)(nil)
+var _ UserStore = (*MemoryUserStore)(nil)
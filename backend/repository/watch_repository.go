@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"leaderboard-system/models"
+	"leaderboard-system/tenant"
+)
+
+
+type WatchRepository struct {
+	db *gorm.DB
+}
+
+
+func NewWatchRepository(db *gorm.DB) *WatchRepository {
+	return &WatchRepository{db: db}
+}
+
+// WatchMatch is a watch together with the current rating of the user whose
+// position decides whether it fires.
+type WatchMatch struct {
+	models.Watch
+	SubjectRating int32 `gorm:"column:subject_rating"`
+}
+
+
+// scoped is limited to the watches of the tenant ctx acts for. Queries
+// joining users name the tenant of both tables themselves.
+func (r *WatchRepository) scoped(ctx context.Context) *gorm.DB {
+	return scoped(ctx, conn(ctx, r.db))
+}
+
+
+func (r *WatchRepository) CreateWatch(ctx context.Context, watch *models.Watch) error {
+	watch.TenantID = tenant.ID(ctx)
+	if err := conn(ctx, r.db).Create(watch).Error; err != nil {
+		return fmt.Errorf("failed to create watch: %w", err)
+	}
+	return nil
+}
+
+
+func (r *WatchRepository) GetWatchesByWatcher(ctx context.Context, watcherID string) ([]models.Watch, error) {
+	var watches []models.Watch
+	if err := r.scoped(ctx).
+		Where("watcher_id = ?", watcherID).
+		Order("created_at ASC").
+		Find(&watches).Error; err != nil {
+		return nil, fmt.Errorf("failed to get watches: %w", err)
+	}
+	return watches, nil
+}
+
+
+func (r *WatchRepository) CountWatchesByWatcher(ctx context.Context, watcherID string) (int64, error) {
+	var count int64
+	if err := r.scoped(ctx).
+		Model(&models.Watch{}).
+		Where("watcher_id = ?", watcherID).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count watches: %w", err)
+	}
+	return count, nil
+}
+
+
+func (r *WatchRepository) DeleteWatch(ctx context.Context, watcherID, watchID string) (bool, error) {
+	res := r.scoped(ctx).
+		Where("id = ? AND watcher_id = ?", watchID, watcherID).
+		Delete(&models.Watch{})
+	if res.Error != nil {
+		return false, fmt.Errorf("failed to delete watch: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// GetRivalWatchesOnTarget returns rival watches on targetID whose watcher's
+// rating lies in [minRating, maxRating).
+func (r *WatchRepository) GetRivalWatchesOnTarget(ctx context.Context, targetID string, minRating, maxRating int32) ([]WatchMatch, error) {
+	var matches []WatchMatch
+	if err := conn(ctx, r.db).
+		Table("watches").
+		Select("watches.*, users.rating AS subject_rating").
+		Joins("JOIN users ON users.tenant_id = watches.tenant_id AND users.id = watches.watcher_id AND users.deleted_at IS NULL").
+		Where("watches.tenant_id = ?", tenant.ID(ctx)).
+		Where("watches.type = ? AND watches.target_id = ?", models.WatchTypeRival, targetID).
+		Where("users.rating >= ? AND users.rating < ?", minRating, maxRating).
+		Scan(&matches).Error; err != nil {
+		return nil, fmt.Errorf("failed to get rival watches on target: %w", err)
+	}
+	return matches, nil
+}
+
+// GetRivalWatchesOfWatcher returns rival watches held by watcherID whose
+// target's rating lies in [minRating, maxRating).
+func (r *WatchRepository) GetRivalWatchesOfWatcher(ctx context.Context, watcherID string, minRating, maxRating int32) ([]WatchMatch, error) {
+	var matches []WatchMatch
+	if err := conn(ctx, r.db).
+		Table("watches").
+		Select("watches.*, users.rating AS subject_rating").
+		Joins("JOIN users ON users.tenant_id = watches.tenant_id AND users.id = watches.target_id AND users.deleted_at IS NULL").
+		Where("watches.tenant_id = ?", tenant.ID(ctx)).
+		Where("watches.type = ? AND watches.watcher_id = ?", models.WatchTypeRival, watcherID).
+		Where("users.rating >= ? AND users.rating < ?", minRating, maxRating).
+		Scan(&matches).Error; err != nil {
+		return nil, fmt.Errorf("failed to get rival watches of watcher: %w", err)
+	}
+	return matches, nil
+}
+
+// GetThresholdWatchesInRange returns rank threshold watches whose watcher's
+// rating lies in [minRating, maxRating).
+func (r *WatchRepository) GetThresholdWatchesInRange(ctx context.Context, minRating, maxRating int32) ([]WatchMatch, error) {
+	var matches []WatchMatch
+	if err := conn(ctx, r.db).
+		Table("watches").
+		Select("watches.*, users.rating AS subject_rating").
+		Joins("JOIN users ON users.tenant_id = watches.tenant_id AND users.id = watches.watcher_id AND users.deleted_at IS NULL").
+		Where("watches.tenant_id = ?", tenant.ID(ctx)).
+		Where("watches.type = ?", models.WatchTypeRankThreshold).
+		Where("users.rating >= ? AND users.rating < ?", minRating, maxRating).
+		Scan(&matches).Error; err != nil {
+		return nil, fmt.Errorf("failed to get threshold watches: %w", err)
+	}
+	return matches, nil
+}
+
+
+func (r *WatchRepository) GetThresholdWatchesOfWatcher(ctx context.Context, watcherID string) ([]models.Watch, error) {
+	var watches []models.Watch
+	if err := r.scoped(ctx).
+		Where("type = ? AND watcher_id = ?", models.WatchTypeRankThreshold, watcherID).
+		Find(&watches).Error; err != nil {
+		return nil, fmt.Errorf("failed to get threshold watches: %w", err)
+	}
+	return watches, nil
+}
+
+// DeleteWatchesOfUser removes every watch userID holds or is the target of.
+func (r *WatchRepository) DeleteWatchesOfUser(ctx context.Context, userID string) error {
+	if err := r.scoped(ctx).
+		Where("watcher_id = ? OR target_id = ?", userID, userID).
+		Delete(&models.Watch{}).Error; err != nil {
+		return fmt.Errorf("failed to delete watches of user: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"leaderboard-system/models"
+	"leaderboard-system/tenant"
+)
+
+// WatchStore is the watch storage the services depend on. WatchRepository
+// implements it on Postgres and MemoryWatchStore in process. The lookups
+// returning WatchMatch only see watches whose subject is an active user.
+type WatchStore interface {
+	CreateWatch(ctx context.Context, watch *models.Watch) error
+	GetWatchesByWatcher(ctx context.Context, watcherID string) ([]models.Watch, error)
+	CountWatchesByWatcher(ctx context.Context, watcherID string) (int64, error)
+	DeleteWatch(ctx context.Context, watcherID, watchID string) (bool, error)
+	GetRivalWatchesOnTarget(ctx context.Context, targetID string, minRating, maxRating int32) ([]WatchMatch, error)
+	GetRivalWatchesOfWatcher(ctx context.Context, watcherID string, minRating, maxRating int32) ([]WatchMatch, error)
+	GetThresholdWatchesInRange(ctx context.Context, minRating, maxRating int32) ([]WatchMatch, error)
+	GetThresholdWatchesOfWatcher(ctx context.Context, watcherID string) ([]models.Watch, error)
+	DeleteWatchesOfUser(ctx context.Context, userID string) error
+}
+
+var _ WatchStore = (*WatchRepository)(nil)
+var _ WatchStore = (*MemoryWatchStore)(nil)
+
+// MemoryWatchStore keeps watches in process and reads ratings from users,
+// the way WatchRepository joins the users table.
+type MemoryWatchStore struct {
+	users UserStore
+
+	mu      sync.RWMutex
+	watches []models.Watch
+}
+
+
+func NewMemoryWatchStore(users UserStore) *MemoryWatchStore {
+	return &MemoryWatchStore{users: users}
+}
+
+
+func (s *MemoryWatchStore) CreateWatch(ctx context.Context, watch *models.Watch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	watch.TenantID = tenant.ID(ctx)
+	if watch.CreatedAt.IsZero() {
+		watch.CreatedAt = time.Now()
+	}
+	s.watches = append(s.watches, *watch)
+	return nil
+}
+
+// tenantWatches returns the watches of the tenant ctx acts for that keep
+// says to.
+func (s *MemoryWatchStore) tenantWatches(ctx context.Context, keep func(w *models.Watch) bool) []models.Watch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id := tenant.ID(ctx)
+	var out []models.Watch
+	for i := range s.watches {
+		if w := &s.watches[i]; w.TenantID == id && keep(w) {
+			out = append(out, *w)
+		}
+	}
+	return out
+}
+
+
+func (s *MemoryWatchStore) GetWatchesByWatcher(ctx context.Context, watcherID string) ([]models.Watch, error) {
+	return s.tenantWatches(ctx, func(w *models.Watch) bool { return w.WatcherID == watcherID }), nil
+}
+
+
+func (s *MemoryWatchStore) CountWatchesByWatcher(ctx context.Context, watcherID string) (int64, error) {
+	return int64(len(s.tenantWatches(ctx, func(w *models.Watch) bool { return w.WatcherID == watcherID }))), nil
+}
+
+
+func (s *MemoryWatchStore) DeleteWatch(ctx context.Context, watcherID, watchID string) (bool, error) {
+	n := s.remove(ctx, func(w *models.Watch) bool { return w.ID == watchID && w.WatcherID == watcherID })
+	return n > 0, nil
+}
+
+
+func (s *MemoryWatchStore) GetRivalWatchesOnTarget(ctx context.Context, targetID string, minRating, maxRating int32) ([]WatchMatch, error) {
+	watches := s.tenantWatches(ctx, func(w *models.Watch) bool {
+		return w.Type == models.WatchTypeRival && w.TargetID == targetID
+	})
+	return s.match(ctx, watches, func(w *models.Watch) string { return w.WatcherID }, minRating, maxRating)
+}
+
+
+func (s *MemoryWatchStore) GetRivalWatchesOfWatcher(ctx context.Context, watcherID string, minRating, maxRating int32) ([]WatchMatch, error) {
+	watches := s.tenantWatches(ctx, func(w *models.Watch) bool {
+		return w.Type == models.WatchTypeRival && w.WatcherID == watcherID
+	})
+	return s.match(ctx, watches, func(w *models.Watch) string { return w.TargetID }, minRating, maxRating)
+}
+
+
+func (s *MemoryWatchStore) GetThresholdWatchesInRange(ctx context.Context, minRating, maxRating int32) ([]WatchMatch, error) {
+	watches := s.tenantWatches(ctx, func(w *models.Watch) bool { return w.Type == models.WatchTypeRankThreshold })
+	return s.match(ctx, watches, func(w *models.Watch) string { return w.WatcherID }, minRating, maxRating)
+}
+
+
+func (s *MemoryWatchStore) GetThresholdWatchesOfWatcher(ctx context.Context, watcherID string) ([]models.Watch, error) {
+	return s.tenantWatches(ctx, func(w *models.Watch) bool {
+		return w.Type == models.WatchTypeRankThreshold && w.WatcherID == watcherID
+	}), nil
+}
+
+
+func (s *MemoryWatchStore) DeleteWatchesOfUser(ctx context.Context, userID string) error {
+	s.remove(ctx, func(w *models.Watch) bool { return w.WatcherID == userID || w.TargetID == userID })
+	return nil
+}
+
+// match pairs each watch with the rating of its subject, keeping those whose
+// subject is active and rated in [minRating, maxRating).
+func (s *MemoryWatchStore) match(ctx context.Context, watches []models.Watch, subject func(w *models.Watch) string, minRating, maxRating int32) ([]WatchMatch, error) {
+	var matches []WatchMatch
+	for i := range watches {
+		user, err := s.users.GetUserByID(ctx, subject(&watches[i]))
+		if err != nil {
+			return nil, err
+		}
+		if user == nil || user.Rating < minRating || user.Rating >= maxRating {
+			continue
+		}
+		matches = append(matches, WatchMatch{Watch: watches[i], SubjectRating: user.Rating})
+	}
+	return matches, nil
+}
+
+// remove deletes the watches of the tenant ctx acts for that drop says to,
+// and returns how many it deleted.
+func (s *MemoryWatchStore) remove(ctx context.Context, drop func(w *models.Watch) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := tenant.ID(ctx)
+	kept := s.watches[:0]
+	for i := range s.watches {
+		if w := &s.watches[i]; w.TenantID != id || !drop(w) {
+			kept = append(kept, *w)
+		}
+	}
+	n := len(s.watches) - len(kept)
+	s.watches = kept
+	return n
+}
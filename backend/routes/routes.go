@@ -1,54 +1,127 @@
 package routes
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"leaderboard-system/cache"
+	"leaderboard-system/config"
 	"leaderboard-system/controller"
+	"leaderboard-system/database"
 	"leaderboard-system/middleware"
+	"leaderboard-system/notifier"
 	"leaderboard-system/repository"
 	"leaderboard-system/service"
 	"gorm.io/gorm"
 )
 
- 
-func SetupRoutes(router *gin.Engine, db *gorm.DB, cacheManager *cache.CacheManager, logger *zap.Logger) {
+// SetupRoutes wires the services and registers every route. Background
+// workers it starts stop when ctx is done.
+func SetupRoutes(ctx context.Context, router *gin.Engine, db *gorm.DB, replicas *database.Replicas, cacheStore cache.Cache, cfg *config.Config, logger *zap.Logger) {
 	 
 	router.Use(middleware.CORSMiddleware())
 	router.Use(middleware.LoggingMiddleware(logger))
-	router.Use(middleware.RateLimitMiddleware())
+	router.Use(middleware.ErrorMiddleware(logger))
+	// Every route, admin and health included, is limited per client IP
+	// before anything else is done for the request.
+	router.Use(middleware.IPRateLimitMiddleware(float64(cfg.Server.RateLimitRPS), cfg.Server.RateLimitBurst, logger))
+	router.NoRoute(middleware.NoRouteHandler)
 
  
-	userRepo := repository.NewUserRepository(db)
-	userService := service.NewUserService(userRepo, cacheManager, logger)
+	userRepo := repository.NewUserRepository(db, replicas)
+	changeLogRepo := repository.NewChangeLogRepository(db, replicas)
+	changeLogService := service.NewChangeLogService(changeLogRepo, userRepo, logger)
+	userService := service.NewUserService(userRepo, changeLogService, cacheStore, logger)
+	userService.SetReplicaLag(replicas.MaxLag())
+	if cfg.Ranks.Materialized {
+		rankRepo := repository.NewRankRepository(db, replicas)
+		userService.SetRankTable(rankRepo)
+		go service.NewRankWorker(userService, rankRepo, &cfg.Ranks, logger).Run(ctx)
+	}
 	userCtrl := controller.NewUserController(userService, logger)
 
+	watchRepo := repository.NewWatchRepository(db)
+	watchService := service.NewWatchService(watchRepo, userRepo, notifier.NewLogNotifier(logger), logger)
+	userService.AddRatingListener(watchService)
+	watchCtrl := controller.NewWatchController(watchService, logger)
+
+	accountService := service.NewAccountService(userService, watchRepo, repository.NewAuditRepository(db), logger)
+	accountCtrl := controller.NewAccountController(accountService, logger)
+
+	warmer := service.NewCacheWarmer(userService, service.WarmOptionsFromConfig(&cfg.Cache), logger)
+	importService := service.NewImportService(userService, userRepo, service.DefaultImportBatchSize, logger)
+	adminCtrl := controller.NewAdminController(warmer, importService, logger)
+	healthCtrl := controller.NewHealthController(userService, db, replicas, logger)
+
+	tenantService := service.NewTenantService(repository.NewTenantRepository(db), logger)
+	tenantCtrl := controller.NewTenantController(tenantService, logger)
+	// Every game is a tenant with its own users, board and rate limit.
+	tenantScoped := []gin.HandlerFunc{middleware.TenantMiddleware(tenantService, false), middleware.RateLimitMiddleware(logger)}
+
  
-	router.GET("/health", userCtrl.Health)
+	router.GET("/health", healthCtrl.Health)
+
+	router.GET("/metrics", healthCtrl.Metrics)
 
  
-	users := router.Group("/users")
+	users := router.Group("/users", tenantScoped...)
 	{
 		 
 		users.POST("", userCtrl.CreateUser)
 
+		users.GET("", userCtrl.ListUsers)
+
 		 
 		users.GET("/:user_id", userCtrl.GetUser)
 
 		 
 		users.PUT("/:user_id/rating", userCtrl.UpdateRating)
 
+		users.PATCH("/:user_id", userCtrl.UpdateUser)
+
+		users.DELETE("/:user_id", accountCtrl.DeleteUser)
+
 	 
 		users.GET("/:user_id/leaderboard-context", userCtrl.GetLeaderboardAroundUser)
 
 	 
 		users.GET("/search", userCtrl.SearchUser)
+
+		users.POST("/:user_id/watches", watchCtrl.CreateWatch)
+		users.GET("/:user_id/watches", watchCtrl.ListWatches)
+		users.DELETE("/:user_id/watches/:watch_id", watchCtrl.DeleteWatch)
 	}
 
 	 
-	leaderboard := router.Group("/leaderboard")
+	leaderboard := router.Group("/leaderboard", tenantScoped...)
 	{
 		 
 		leaderboard.GET("", userCtrl.GetLeaderboard)
+
+		leaderboard.GET("/changes", userCtrl.GetLeaderboardChanges)
+	}
+
+	adminAuth := middleware.AdminAuthMiddleware(cfg.Admin.Token)
+	tenants := router.Group("/admin/tenants", adminAuth)
+	{
+		tenants.POST("", tenantCtrl.CreateTenant)
+		tenants.GET("", tenantCtrl.ListTenants)
+		tenants.GET("/:tenant_id", tenantCtrl.GetTenant)
+		tenants.PATCH("/:tenant_id", tenantCtrl.UpdateTenant)
+		tenants.POST("/:tenant_id/api-key", tenantCtrl.RotateAPIKey)
+	}
+
+	// Admins act for the tenant named by X-Tenant-ID without its API key.
+	admin := router.Group("/admin", adminAuth, middleware.TenantMiddleware(tenantService, true))
+	{
+		admin.POST("/cache/rebuild", adminCtrl.RebuildCache)
+		admin.GET("/cache/rebuild", adminCtrl.RebuildStatus)
+
+		admin.POST("/import", adminCtrl.ImportUsers)
+
+		admin.DELETE("/users/:user_id", accountCtrl.AdminDeleteUser)
+		admin.POST("/users/:user_id/reactivate", accountCtrl.ReactivateUser)
+		admin.GET("/users/:user_id/audit", accountCtrl.AuditLog)
 	}
 }
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+)
+
+const (
+	// ActorSelf and ActorAdmin are who the audit log says acted.
+	ActorSelf  = "self"
+	ActorAdmin = "admin"
+
+	// ErasedUsernamePrefix starts the username an erased user is given.
+	ErasedUsernamePrefix = "erased-"
+)
+
+var (
+	ErrUserNotFound       = NewError(ErrNotFound, "USER_NOT_FOUND", "user not found")
+	ErrUserNotDeactivated = NewError(ErrConflict, "USER_NOT_DEACTIVATED", "user is not deactivated")
+)
+
+// AccountService deactivates, reactivates and erases users. A deactivated
+// user drops off every board and search and frees its rank, but keeps its
+// row and username. Erasure replaces the username and purges the user's
+// rating history, watches and cache entries for good.
+type AccountService struct {
+	users     *UserService
+	watchRepo repository.WatchStore
+	auditRepo *repository.AuditRepository
+	logger    *zap.Logger
+}
+
+
+func NewAccountService(users *UserService, watchRepo repository.WatchStore, auditRepo *repository.AuditRepository, logger *zap.Logger) *AccountService {
+	return &AccountService{
+		users:     users,
+		watchRepo: watchRepo,
+		auditRepo: auditRepo,
+		logger:    logger,
+	}
+}
+
+
+func (s *AccountService) Deactivate(ctx context.Context, userID, actor, reason string) error {
+	ctx = repository.WithPrimaryReads(ctx)
+
+	err := s.users.repo.InTx(ctx, func(ctx context.Context) error {
+		user, err := s.users.repo.LockUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return ErrUserNotFound
+		}
+
+		ok, err := s.users.repo.DeactivateUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrUserNotFound
+		}
+
+		if err := s.audit(ctx, userID, models.UserAuditDeactivated, actor, reason); err != nil {
+			return err
+		}
+		return s.leftBoard(ctx, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("User deactivated", zap.String("user_id", userID), zap.String("actor", actor))
+	return nil
+}
+
+
+func (s *AccountService) Reactivate(ctx context.Context, userID, actor, reason string) (*models.UserDTO, int64, error) {
+	ctx = repository.WithPrimaryReads(ctx)
+
+	var user *models.User
+	err := s.users.repo.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.users.repo.GetUserByIDUnscoped(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.ErasedAt != nil {
+			return ErrUserNotFound
+		}
+
+		ok, err := s.users.repo.ReactivateUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrUserNotDeactivated
+		}
+
+		if err := s.audit(ctx, userID, models.UserAuditReactivated, actor, reason); err != nil {
+			return err
+		}
+		// Rejoining the board is recorded like a new user.
+		if err := s.users.changeLog.Record(ctx, userID, 0, user.Rating); err != nil {
+			return err
+		}
+
+		rejoined := *user
+		repository.AfterCommit(ctx, func(ctx context.Context) {
+			s.users.invalidateMembership(ctx, userID)
+			s.users.search.index(ctx).put(&rejoined)
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	asOf := s.users.RanksAsOf(ctx)
+	rank, err := s.users.GetUserRank(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to calculate rank", zap.Error(err))
+	}
+
+	s.logger.Info("User reactivated", zap.String("user_id", userID), zap.String("actor", actor))
+	return &models.UserDTO{
+		ID:        user.ID,
+		Username:  user.Username,
+		Rating:    user.Rating,
+		RanksAsOf: &asOf,
+	}, rank, nil
+}
+
+// Erase works on active and deactivated users alike. The new username, the
+// purge of the user's watches and rating history and the audit entry commit
+// together, so an erasure never reports done with personal data left behind.
+// The audit entries are kept; they hold no username.
+func (s *AccountService) Erase(ctx context.Context, userID, actor, reason string) error {
+	ctx = repository.WithPrimaryReads(ctx)
+
+	err := s.users.repo.InTx(ctx, func(ctx context.Context) error {
+		user, err := s.users.repo.GetUserByIDUnscoped(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.ErasedAt != nil {
+			return ErrUserNotFound
+		}
+
+		ok, err := s.users.repo.EraseUser(ctx, userID, ErasedUsernamePrefix+uuid.NewString())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrUserNotFound
+		}
+
+		if err := s.watchRepo.DeleteWatchesOfUser(ctx, userID); err != nil {
+			return err
+		}
+		if err := s.users.changeLog.ForgetUser(ctx, userID); err != nil {
+			return err
+		}
+
+		if err := s.audit(ctx, userID, models.UserAuditErased, actor, reason); err != nil {
+			return err
+		}
+		// Recorded even if the user was already deactivated: the purge above
+		// dropped the change that told clients it left.
+		return s.leftBoard(ctx, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("User erased", zap.String("user_id", userID), zap.String("actor", actor))
+	return nil
+}
+
+
+func (s *AccountService) AuditLog(ctx context.Context, userID string) ([]models.UserAuditEntry, error) {
+	return s.auditRepo.GetEntriesByUser(ctx, userID)
+}
+
+
+func (s *AccountService) leftBoard(ctx context.Context, user *models.User) error {
+	if err := s.users.changeLog.Record(ctx, user.ID, user.Rating, 0); err != nil {
+		return err
+	}
+	repository.AfterCommit(ctx, func(ctx context.Context) {
+		s.users.invalidateMembership(ctx, user.ID)
+		s.users.search.index(ctx).remove(user.ID)
+	})
+	return nil
+}
+
+
+func (s *AccountService) audit(ctx context.Context, userID string, action models.UserAuditAction, actor, reason string) error {
+	entry := &models.UserAuditEntry{
+		UserID: userID,
+		Action: action,
+		Actor:  actor,
+		Reason: reason,
+	}
+	return s.auditRepo.AppendEntry(ctx, entry)
+}
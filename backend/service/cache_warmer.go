@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"leaderboard-system/config"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+const (
+	DefaultRebuildBatchSize = 500
+)
+
+var ErrRebuildRunning = NewError(ErrConflict, "REBUILD_RUNNING", "cache rebuild already running")
+
+
+type WarmOptions struct {
+	// Pages is how many leaderboard pages of the default size to preload on
+	// startup, along with the users and ranks on them. Zero disables warm-up.
+	Pages int
+	// HotUsers is how many of the most recently rated users to preload.
+	HotUsers int
+	// BatchSize and Pause throttle a rebuild: users are read and cached
+	// BatchSize at a time, sleeping Pause between batches.
+	BatchSize int
+	Pause     time.Duration
+}
+
+
+func WarmOptionsFromConfig(cfg *config.CacheConfig) WarmOptions {
+	return WarmOptions{
+		Pages:     cfg.WarmupPages,
+		HotUsers:  cfg.WarmupHotUsers,
+		BatchSize: cfg.RebuildBatchSize,
+		Pause:     cfg.RebuildPause,
+	}
+}
+
+// CacheWarmer fills the cache from Postgres so a restarted Redis or a flushed
+// cache does not send every early request to the database. It warms the
+// tenant the context acts for; one rebuild runs at a time across tenants.
+type CacheWarmer struct {
+	users  *UserService
+	opts   WarmOptions
+	logger *zap.Logger
+
+	mu     sync.Mutex
+	status models.CacheRebuildStatus
+}
+
+
+func NewCacheWarmer(users *UserService, opts WarmOptions, logger *zap.Logger) *CacheWarmer {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultRebuildBatchSize
+	}
+	if opts.Pause < 0 {
+		opts.Pause = 0
+	}
+	return &CacheWarmer{
+		users:  users,
+		opts:   opts,
+		logger: logger,
+		status: models.CacheRebuildStatus{State: models.CacheRebuildIdle},
+	}
+}
+
+// WarmUp preloads the top leaderboard pages with their users and ranks, then
+// the users whose rating changed most recently.
+func (w *CacheWarmer) WarmUp(ctx context.Context) error {
+	if w.opts.Pages <= 0 {
+		return nil
+	}
+	start := time.Now()
+
+	warmed, err := w.walk(ctx, int64(w.opts.Pages*DefaultLeaderboardPageSize), 0, nil)
+	if err != nil {
+		return err
+	}
+
+	hot := 0
+	if w.opts.HotUsers > 0 {
+		ids, err := w.users.changeLog.RecentUserIDs(ctx, w.opts.HotUsers)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			user, err := w.users.loadUser(ctx, id)
+			if err != nil {
+				return err
+			}
+			if user == nil {
+				continue
+			}
+			if _, err := w.users.GetUserRank(ctx, id); err != nil {
+				return err
+			}
+			hot++
+		}
+	}
+
+	w.logger.Info("Cache warmed",
+		zap.String("tenant_id", tenant.ID(ctx)),
+		zap.Int64("leaderboard_users", warmed),
+		zap.Int("hot_users", hot),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return nil
+}
+
+// StartRebuild runs Rebuild in the background. Progress is reported by
+// Status.
+func (w *CacheWarmer) StartRebuild(ctx context.Context) (models.CacheRebuildStatus, error) {
+	if err := w.begin(ctx); err != nil {
+		return w.Status(), err
+	}
+
+	go func() {
+		if err := w.run(tenant.Detach(ctx)); err != nil {
+			w.logger.Error("Cache rebuild failed", zap.Error(err))
+		}
+	}()
+
+	return w.Status(), nil
+}
+
+// Rebuild rewrites every cached user, rank and default-size leaderboard page
+// from Postgres, walking the board in throttled batches. Entries are
+// overwritten in place rather than flushed first, so readers keep hitting the
+// cache while it runs.
+func (w *CacheWarmer) Rebuild(ctx context.Context) error {
+	if err := w.begin(ctx); err != nil {
+		return err
+	}
+	return w.run(ctx)
+}
+
+
+func (w *CacheWarmer) Status() models.CacheRebuildStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.status
+}
+
+
+func (w *CacheWarmer) begin(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.status.State == models.CacheRebuildRunning {
+		return ErrRebuildRunning
+	}
+	now := time.Now().UTC()
+	w.status = models.CacheRebuildStatus{State: models.CacheRebuildRunning, Tenant: tenant.ID(ctx), StartedAt: &now}
+	return nil
+}
+
+
+func (w *CacheWarmer) run(ctx context.Context) error {
+	start := time.Now()
+	processed, err := w.walk(ctx, 0, w.opts.Pause, w.progress)
+
+	w.mu.Lock()
+	now := time.Now().UTC()
+	w.status.FinishedAt = &now
+	w.status.Processed = processed
+	if err != nil {
+		w.status.State = models.CacheRebuildFailed
+		w.status.Error = err.Error()
+	} else {
+		w.status.State = models.CacheRebuildCompleted
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	w.logger.Info("Cache rebuilt", zap.Int64("users", processed), zap.Duration("duration", time.Since(start)))
+	return nil
+}
+
+
+func (w *CacheWarmer) progress(processed, total int64) {
+	w.mu.Lock()
+	w.status.Processed = processed
+	w.status.Total = total
+	w.mu.Unlock()
+
+	w.logger.Info("Cache rebuild progress", zap.Int64("processed", processed), zap.Int64("total", total))
+}
+
+// walk reads users in leaderboard order, up to limit or the whole board when
+// limit is 0, and caches each user, its rank and every full page of the
+// default size. Ranks are written under the epoch read before the first batch,
+// so a rating change during the walk retires them like any other cached rank.
+func (w *CacheWarmer) walk(ctx context.Context, limit int64, pause time.Duration, report func(processed, total int64)) (int64, error) {
+	c := w.users.cache
+	ctx = repository.WithPinnedReads(ctx)
+	asOf := time.Now().UTC()
+
+	epoch, err := c.RankEpoch(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read rank epoch: %w", err)
+	}
+	version, err := w.users.changeLog.CurrentVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+	total, err := w.users.repo.GetUserCount(ctx)
+	if err != nil {
+		return 0, err
+	}
+	target := total
+	if limit > 0 && limit < target {
+		target = limit
+	}
+	if report != nil {
+		report(0, target)
+	}
+
+	var (
+		cursor         *repository.LeaderboardCursor
+		processed      int64
+		rank           int64
+		previousRating int32 = -1
+		pageUsers      = make([]models.User, 0, DefaultLeaderboardPageSize)
+		page           = 1
+		delta          time.Duration
+	)
+
+	flushPage := func() error {
+		resp := newLeaderboardPage(pageUsers, page, DefaultLeaderboardPageSize, total, version, asOf)
+		if err := c.SetLeaderboardPage(ctx, GlobalBoard, page, DefaultLeaderboardPageSize, resp, delta); err != nil {
+			return fmt.Errorf("failed to cache leaderboard page %d: %w", page, err)
+		}
+		pageUsers = pageUsers[:0]
+		page++
+		return nil
+	}
+
+	for limit == 0 || processed < limit {
+		size := w.opts.BatchSize
+		if limit > 0 && limit-processed < int64(size) {
+			size = int(limit - processed)
+		}
+
+		queryStart := time.Now()
+		users, err := w.users.repo.GetLeaderboardAfter(ctx, cursor, size)
+		if err != nil {
+			return processed, err
+		}
+		delta = time.Since(queryStart)
+
+		for i := range users {
+			user := &users[i]
+			if err := c.SetUser(ctx, user); err != nil {
+				return processed, fmt.Errorf("failed to cache user: %w", err)
+			}
+
+			processed++
+			if user.Rating != previousRating {
+				rank = processed
+				previousRating = user.Rating
+			}
+			if err := c.SetRank(ctx, epoch, user.ID, rank, delta); err != nil {
+				return processed, fmt.Errorf("failed to cache rank: %w", err)
+			}
+
+			pageUsers = append(pageUsers, *user)
+			if len(pageUsers) == DefaultLeaderboardPageSize {
+				if err := flushPage(); err != nil {
+					return processed, err
+				}
+			}
+		}
+
+		if len(users) < size {
+			// The end of the board; its last page is short.
+			if len(pageUsers) > 0 {
+				if err := flushPage(); err != nil {
+					return processed, err
+				}
+			}
+			break
+		}
+
+		last := users[len(users)-1]
+		cursor = &repository.LeaderboardCursor{Rating: last.Rating, Username: last.Username}
+		if report != nil {
+			report(processed, target)
+		}
+
+		if pause > 0 {
+			select {
+			case <-time.After(pause):
+			case <-ctx.Done():
+				return processed, ctx.Err()
+			}
+		}
+	}
+
+	return processed, nil
+}
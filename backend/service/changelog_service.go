@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"go.uber.org/zap"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+const (
+	ChangeLogRetention    = 10000
+	ChangeLogPruneEvery   = 500
+	MaxChangesPerPoll     = 1000
+	MaxChangedRowsPerPoll = 1000
+)
+
+type ChangeLogService struct {
+	changeRepo repository.ChangeLogStore
+	userRepo   repository.UserStore
+	logger     *zap.Logger
+}
+
+func NewChangeLogService(changeRepo repository.ChangeLogStore, userRepo repository.UserStore, logger *zap.Logger) *ChangeLogService {
+	return &ChangeLogService{
+		changeRepo: changeRepo,
+		userRepo:   userRepo,
+		logger:     logger,
+	}
+}
+
+// Record appends a rating write to the log. The write's version is drawn as
+// the unit of work ctx is part of commits.
+func (s *ChangeLogService) Record(ctx context.Context, userID string, oldRating, newRating int32) error {
+	change := &models.LeaderboardChange{
+		UserID:    userID,
+		OldRating: oldRating,
+		NewRating: newRating,
+	}
+	if err := s.changeRepo.AppendChange(ctx, change); err != nil {
+		return err
+	}
+
+	// Each tenant is pruned by its own writes, so a busy tenant never prunes
+	// a quiet one's history.
+	repository.AfterCommit(ctx, func(ctx context.Context) {
+		if change.Version%ChangeLogPruneEvery != 0 {
+			return
+		}
+		go func(ctx context.Context) {
+			if err := s.changeRepo.Prune(ctx, ChangeLogRetention); err != nil {
+				s.logger.Warn("Failed to prune leaderboard change log", zap.Error(err))
+			}
+		}(tenant.Detach(ctx))
+	})
+	return nil
+}
+
+// RecordBulkChange records that any number of users may have changed at once,
+// as after an import. Clients polling past it reload the board.
+func (s *ChangeLogService) RecordBulkChange(ctx context.Context) error {
+	return s.Record(ctx, "", 0, 0)
+}
+
+// RecordRename records that a user's username changed. Board rows carry no
+// user ID, so a client could not tell which row had the old name; like a user
+// leaving, it makes clients polling past it reload the board.
+func (s *ChangeLogService) RecordRename(ctx context.Context, userID string, rating int32) error {
+	return s.Record(ctx, userID, rating, 0)
+}
+
+// ForgetUser drops every change recorded for userID, for erasure.
+func (s *ChangeLogService) ForgetUser(ctx context.Context, userID string) error {
+	return s.changeRepo.DeleteUserChanges(ctx, userID)
+}
+
+func (s *ChangeLogService) CurrentVersion(ctx context.Context) (int64, error) {
+	return s.changeRepo.LatestVersion(ctx)
+}
+
+func (s *ChangeLogService) RecentUserIDs(ctx context.Context, limit int) ([]string, error) {
+	return s.changeRepo.GetRecentUserIDs(ctx, limit)
+}
+
+// ChangesSince returns the rows whose rank or rating moved after version
+// since. When the log no longer reaches back that far, too much moved to be
+// worth diffing, or a user left the board, FullRefetch is set and the client
+// should reload the board.
+func (s *ChangeLogService) ChangesSince(ctx context.Context, since int64) (*models.LeaderboardChangesResponse, error) {
+	// The log and the ranked rows must come from the same replica.
+	ctx = repository.WithPinnedReads(ctx)
+	latest, err := s.changeRepo.LatestVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.LeaderboardChangesResponse{
+		Since:   since,
+		Version: latest,
+		Changes: []models.LeaderboardEntry{},
+	}
+
+	if since == latest {
+		return resp, nil
+	}
+	if since < 0 || since > latest {
+		resp.FullRefetch = true
+		return resp, nil
+	}
+
+	oldest, err := s.changeRepo.OldestVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if since < oldest-1 {
+		resp.FullRefetch = true
+		return resp, nil
+	}
+
+	changes, err := s.changeRepo.GetChangesSince(ctx, since, MaxChangesPerPoll+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > MaxChangesPerPoll {
+		resp.FullRefetch = true
+		return resp, nil
+	}
+	if len(changes) == 0 {
+		return resp, nil
+	}
+
+	resp.Version = changes[len(changes)-1].Version
+
+	ids := make([]string, 0, len(changes))
+	ranges := make([]repository.RatingRange, 0, len(changes))
+	for _, ch := range changes {
+		// A deactivated user has no row left to send, so the client could
+		// not tell which of its rows to drop. Bulk changes and renames are
+		// recorded the same way.
+		if ch.NewRating == 0 {
+			resp.FullRefetch = true
+			return resp, nil
+		}
+		ids = append(ids, ch.UserID)
+		if rr, ok := displacedRange(ch.OldRating, ch.NewRating); ok {
+			ranges = append(ranges, rr)
+		}
+	}
+
+	entries, err := s.userRepo.GetRankedUsers(ctx, ids, mergeRanges(ranges), MaxChangedRowsPerPoll+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > MaxChangedRowsPerPoll {
+		resp.FullRefetch = true
+		return resp, nil
+	}
+
+	resp.Changes = entries
+	return resp, nil
+}
+
+// displacedRange returns the inclusive rating range of users whose rank is
+// shifted by one user moving from oldRating to newRating.
+func displacedRange(oldRating, newRating int32) (repository.RatingRange, bool) {
+	switch {
+	case newRating > oldRating:
+		return repository.RatingRange{Min: oldRating, Max: newRating - 1}, true
+	case newRating < oldRating:
+		return repository.RatingRange{Min: newRating + 1, Max: oldRating}, true
+	}
+	return repository.RatingRange{}, false
+}
+
+func mergeRanges(ranges []repository.RatingRange) []repository.RatingRange {
+	if len(ranges) == 0 {
+		return ranges
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Min < ranges[j].Min })
+
+	merged := []repository.RatingRange{ranges[0]}
+	for _, rr := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if rr.Min <= last.Max+1 {
+			if rr.Max > last.Max {
+				last.Max = rr.Max
+			}
+			continue
+		}
+		merged = append(merged, rr)
+	}
+	return merged
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"leaderboard-system/cache"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+const (
+	LoadLockTTL      = 2 * time.Second
+	LoadPollInterval = 25 * time.Millisecond
+	LoadTimeout      = 10 * time.Second
+)
+
+// loadSpec describes one cached value. lookup reports a cache hit and how
+// fresh it is, compute loads the value from Postgres, and store writes it
+// back to the cache along with how long compute took.
+type loadSpec struct {
+	key     string
+	lookup  func(ctx context.Context) (interface{}, bool, cache.Freshness)
+	compute func(ctx context.Context) (interface{}, error)
+	store   func(ctx context.Context, v interface{}, delta time.Duration)
+}
+
+// loader coalesces identical cache-miss loads. Concurrent callers in this
+// process share one call through singleflight, and instances share a short
+// lock in the cache so only one of them queries Postgres while the others
+// wait for the value to appear. Hits that are stale or due for an XFetch early
+// refresh are served immediately while a single background refresh runs.
+type loader struct {
+	group  singleflight.Group
+	cache  cache.Cache
+	logger *zap.Logger
+}
+
+
+func newLoader(c cache.Cache, logger *zap.Logger) *loader {
+	return &loader{cache: c, logger: logger}
+}
+
+// load runs the shared call on its own context so one caller giving up does
+// not fail the others. Only callers acting for the same tenant and reading
+// from the same place share a call.
+//
+// Callers reading from the primary, to see their own writes, skip the cache
+// both ways: an entry may have been filled from a replica that has not seen
+// the write, and what they read is newer than what others may be allowed to
+// serve from the cache.
+func (l *loader) load(ctx context.Context, spec loadSpec) (interface{}, error) {
+	primary := repository.ReadsFromPrimary(ctx)
+	key := tenant.ID(ctx) + "/" + spec.key
+	if primary {
+		key = "primary:" + key
+	}
+	ch := l.group.DoChan(key, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(tenant.Detach(ctx), LoadTimeout)
+		defer cancel()
+		if primary {
+			return spec.compute(repository.WithPrimaryReads(loadCtx))
+		}
+
+		return l.loadShared(loadCtx, spec)
+	})
+
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+
+func (l *loader) loadShared(ctx context.Context, spec loadSpec) (interface{}, error) {
+	deadline := time.Now().Add(LoadLockTTL)
+
+	for {
+		if v, ok, fresh := spec.lookup(ctx); ok {
+			if fresh.ShouldRefresh(time.Now()) {
+				l.refresh(ctx, spec)
+			}
+			return v, nil
+		}
+
+		release, acquired, err := l.cache.AcquireLock(ctx, spec.key, LoadLockTTL)
+		if err != nil {
+			l.logger.Warn("Failed to acquire load lock", zap.String("key", spec.key), zap.Error(err))
+			return l.computeAndStore(ctx, spec)
+		}
+
+		if acquired {
+			defer l.release(spec.key, release)
+
+			// Another instance may have filled the cache between our miss
+			// and taking the lock.
+			if v, ok, _ := spec.lookup(ctx); ok {
+				return v, nil
+			}
+			return l.computeAndStore(ctx, spec)
+		}
+
+		// The holder did not publish a value in time; load it ourselves
+		// rather than fail the request.
+		if time.Now().After(deadline) {
+			return l.computeAndStore(ctx, spec)
+		}
+
+		select {
+		case <-time.After(LoadPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// refresh recomputes spec in the background. Readers in this process share
+// one refresh, and an instance that cannot take the lock leaves the refresh to
+// whoever holds it.
+func (l *loader) refresh(ctx context.Context, spec loadSpec) {
+	l.group.DoChan("refresh:"+tenant.ID(ctx)+"/"+spec.key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(tenant.Detach(ctx), LoadTimeout)
+		defer cancel()
+
+		release, acquired, err := l.cache.AcquireLock(ctx, spec.key, LoadLockTTL)
+		if err != nil || !acquired {
+			return nil, err
+		}
+		defer l.release(spec.key, release)
+
+		if _, err := l.computeAndStore(ctx, spec); err != nil {
+			l.logger.Warn("Background refresh failed", zap.String("key", spec.key), zap.Error(err))
+		}
+		return nil, nil
+	})
+}
+
+
+func (l *loader) computeAndStore(ctx context.Context, spec loadSpec) (interface{}, error) {
+	start := time.Now()
+	v, err := spec.compute(ctx)
+	if err != nil {
+		return v, err
+	}
+	spec.store(ctx, v, time.Since(start))
+	return v, nil
+}
+
+
+func (l *loader) release(key string, release func(context.Context) error) {
+	if err := release(context.Background()); err != nil {
+		l.logger.Warn("Failed to release load lock", zap.String("key", key), zap.Error(err))
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"leaderboard-system/cache"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+// fakeSpec is a loadSpec over one cached value. compute returns computed
+// once release is closed, if it is set.
+type fakeSpec struct {
+	cached   interface{}
+	computed interface{}
+	release  chan struct{}
+	computes int32
+	stores   int32
+}
+
+func (f *fakeSpec) spec() loadSpec {
+	return loadSpec{
+		key: "k",
+		lookup: func(ctx context.Context) (interface{}, bool, cache.Freshness) {
+			return f.cached, f.cached != nil, cache.Freshness{}
+		},
+		compute: func(ctx context.Context) (interface{}, error) {
+			atomic.AddInt32(&f.computes, 1)
+			if f.release != nil {
+				<-f.release
+			}
+			return f.computed, nil
+		},
+		store: func(ctx context.Context, v interface{}, delta time.Duration) {
+			atomic.AddInt32(&f.stores, 1)
+		},
+	}
+}
+
+// Primary reads must neither be served from nor fill the cache; replica
+// reads use it as before.
+func TestLoaderPrimaryReadsSkipCache(t *testing.T) {
+	cases := []struct {
+		name     string
+		primary  bool
+		cached   interface{}
+		want     interface{}
+		computes int32
+		stores   int32
+	}{
+		{name: "replica hit", cached: "cached", want: "cached"},
+		{name: "replica miss", want: "computed", computes: 1, stores: 1},
+		{name: "primary hit", primary: true, cached: "cached", want: "computed", computes: 1},
+		{name: "primary miss", primary: true, want: "computed", computes: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.primary {
+				ctx = repository.WithPrimaryReads(ctx)
+			}
+			f := &fakeSpec{cached: tc.cached, computed: "computed"}
+			l := newLoader(cache.NewMemoryCache(100), zap.NewNop())
+
+			got, err := l.load(ctx, f.spec())
+			if err != nil || got != tc.want {
+				t.Fatalf("load = %v, %v, want %v", got, err, tc.want)
+			}
+			if f.computes != tc.computes || f.stores != tc.stores {
+				t.Fatalf("computes, stores = %d, %d, want %d, %d", f.computes, f.stores, tc.computes, tc.stores)
+			}
+		})
+	}
+}
+
+// A primary read must not join a replica load already in flight for the
+// same key, which could return what a lagging replica saw.
+func TestLoaderPrimaryReadDoesNotJoinReplicaLoad(t *testing.T) {
+	l := newLoader(cache.NewMemoryCache(100), zap.NewNop())
+	replica := &fakeSpec{computed: "replica", release: make(chan struct{})}
+	defer close(replica.release)
+
+	go l.load(context.Background(), replica.spec())
+	for atomic.LoadInt32(&replica.computes) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	primary := &fakeSpec{computed: "primary"}
+	ctx, cancel := context.WithTimeout(repository.WithPrimaryReads(context.Background()), time.Second)
+	defer cancel()
+	got, err := l.load(ctx, primary.spec())
+	if err != nil || got != "primary" {
+		t.Fatalf("primary load = %v, %v, want primary", got, err)
+	}
+}
+
+// Concurrent misses on one key share a single load; the same key for
+// another tenant loads on its own.
+func TestLoaderCoalescesMisses(t *testing.T) {
+	l := newLoader(cache.NewMemoryCache(100), zap.NewNop())
+	f := &fakeSpec{computed: "computed", release: make(chan struct{})}
+	ctx := context.Background()
+	other := tenant.WithID(ctx, "other")
+
+	const callers = 20
+	results := make(chan interface{}, callers+1)
+	var wg sync.WaitGroup
+	for i := 0; i <= callers; i++ {
+		c := ctx
+		if i == callers {
+			c = other
+		}
+		wg.Add(1)
+		go func(ctx context.Context) {
+			defer wg.Done()
+			v, err := l.load(ctx, f.spec())
+			if err != nil {
+				t.Error(err)
+			}
+			results <- v
+		}(c)
+	}
+
+	// Both loads are in flight once two computes have started; give any
+	// caller that failed to join time to start a third.
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&f.computes) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(f.release)
+	wg.Wait()
+	close(results)
+
+	if n := atomic.LoadInt32(&f.computes); n != 2 {
+		t.Fatalf("computes = %d, want one per tenant", n)
+	}
+	for v := range results {
+		if v != "computed" {
+			t.Fatalf("load = %v, want computed", v)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+
+	"leaderboard-system/cache"
+	"leaderboard-system/database"
+)
+
+// The kinds of failure a request can end in. errors.Is(err, ErrNotFound)
+// tells the kind of any *Error, and the HTTP layer picks the status from it.
+var (
+	ErrValidation   = errors.New("validation failed")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnavailable  = errors.New("temporarily unavailable")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrInternal     = errors.New("internal error")
+
+	// ErrCanceled is a request its client gave up on. Nothing failed, so it
+	// is neither an outage nor an internal error.
+	ErrCanceled = errors.New("request canceled")
+)
+
+// Error is a failure a caller can act on: its kind, a stable Code clients can
+// switch on, like USER_NOT_FOUND, and a Message fit to show them. Validation
+// errors name the offending fields. Err is the underlying cause, for logs.
+//
+// The sentinel errors of this package are *Errors; wrapping one with
+// fmt.Errorf("%w: ...") adds detail and keeps its kind and code.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError says what is wrong with one field of the input.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+
+func NewError(kind error, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+
+func NewValidationError(code, message string, fields ...FieldError) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message, Fields: fields}
+}
+
+// invalidField reports that field failed validation with err.
+func invalidField(field string, err error) *Error {
+	return NewValidationError("INVALID_"+strings.ToUpper(field), "invalid "+field+": "+err.Error(),
+		FieldError{Field: field, Message: err.Error()})
+}
+
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// WithCause returns a copy of e caused by err.
+func (e *Error) WithCause(err error) *Error {
+	out := *e
+	out.Err = err
+	return &out
+}
+
+// AsError returns the *Error err is or wraps. A request cut short by its
+// client disconnecting is ErrCanceled. Failures that should pass if the
+// client tries again later, like a lost database connection, a deadlock or an
+// open cache breaker, become ErrUnavailable; anything else is ErrInternal.
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, context.Canceled) {
+		return &Error{Kind: ErrCanceled, Code: "REQUEST_CANCELED", Message: "request canceled by the client", Err: err}
+	}
+	if unavailable(err) {
+		return &Error{Kind: ErrUnavailable, Code: "UNAVAILABLE", Message: "service temporarily unavailable, try again", Err: err}
+	}
+	return &Error{Kind: ErrInternal, Code: "INTERNAL", Message: "internal error", Err: err}
+}
+
+
+func unavailable(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, cache.ErrCircuitOpen) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		database.Retryable(err)
+}
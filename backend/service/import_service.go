@@ -0,0 +1,468 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+
+	DefaultImportBatchSize = 5000
+	// MaxImportErrors caps the rejected rows listed in an import report; the
+	// rest are only counted.
+	MaxImportErrors = 1000
+	// MaxImportLineBytes bounds one NDJSON line.
+	MaxImportLineBytes = 1 << 20
+	// RebuildIndexThreshold is how many rows an import must insert or update
+	// before the rank indexes are worth rebuilding. Smaller imports bloat
+	// them too little to matter.
+	RebuildIndexThreshold = 100000
+)
+
+// ErrInvalidImport is returned when the input as a whole cannot be read, as
+// opposed to single rows being rejected.
+var ErrInvalidImport = NewValidationError("INVALID_IMPORT", "invalid import")
+
+// ImportService bulk loads users from CSV or NDJSON. Input is streamed and
+// loaded in batches, each in its own transaction, so memory stays flat and
+// an interrupted import keeps the batches already loaded. Rows carry
+// user_id (optional), username and rating; a row whose user_id, or username
+// when it has none, matches an existing user updates it.
+//
+// Work that can trail the import, rebuilding indexes and evaluating watches,
+// runs in the background; Wait waits for it.
+type ImportService struct {
+	users     *UserService
+	importer  repository.UserImporter
+	batchSize int
+	logger    *zap.Logger
+
+	background sync.WaitGroup
+	// rebuilding is set while an index rebuild runs, so imports finishing
+	// meanwhile do not start another.
+	rebuilding atomic.Bool
+}
+
+
+func NewImportService(users *UserService, importer repository.UserImporter, batchSize int, logger *zap.Logger) *ImportService {
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+	return &ImportService{
+		users:     users,
+		importer:  importer,
+		batchSize: batchSize,
+		logger:    logger,
+	}
+}
+
+// Wait waits for the background work of imports finished so far. Commands
+// call it before exiting.
+func (s *ImportService) Wait() {
+	s.background.Wait()
+}
+
+// DetectImportFormat guesses the format from a file name or content type.
+func DetectImportFormat(nameOrType string) (string, bool) {
+	s := strings.ToLower(nameOrType)
+	switch {
+	case strings.Contains(s, "csv"):
+		return ImportFormatCSV, true
+	case strings.Contains(s, "ndjson"), strings.Contains(s, "jsonl"), strings.Contains(s, "json-seq"):
+		return ImportFormatNDJSON, true
+	}
+	return "", false
+}
+
+// Import loads every row of r. The report is returned even when the import
+// stops early with an error, and covers the rows read until then.
+func (s *ImportService) Import(ctx context.Context, r io.Reader, format string) (*models.ImportReport, error) {
+	src, err := newImportSource(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ImportReport{
+		Format:    format,
+		Errors:    []models.ImportRowError{},
+		StartedAt: time.Now().UTC(),
+	}
+
+	// Duplicates within the input are caught here; the database only sees
+	// one batch at a time.
+	seenIDs := make(map[string]int64)
+	seenNames := make(map[string]int64)
+	batch := make([]repository.ImportRow, 0, s.batchSize)
+
+	err = func() error {
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			rec, err := src.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			report.Processed++
+
+			if rec.err != nil {
+				s.reject(report, rec.line, rec.userID, rec.username, rec.err.Error())
+				continue
+			}
+			if line, ok := seenIDs[rec.userID]; ok && rec.userID != "" {
+				s.reject(report, rec.line, rec.userID, rec.username, fmt.Sprintf("user_id already appears on line %d", line))
+				continue
+			}
+			name := strings.ToLower(rec.username)
+			if line, ok := seenNames[name]; ok {
+				s.reject(report, rec.line, rec.userID, rec.username, fmt.Sprintf("username already appears on line %d", line))
+				continue
+			}
+			if rec.userID != "" {
+				seenIDs[rec.userID] = rec.line
+			}
+			seenNames[name] = rec.line
+
+			row := repository.ImportRow{Line: rec.line, ID: rec.userID, Username: rec.username, Rating: rec.rating}
+			if row.ID == "" {
+				row.ID = uuid.NewString()
+				row.MatchUsername = true
+			}
+			batch = append(batch, row)
+
+			if len(batch) >= s.batchSize {
+				if err := s.load(ctx, batch, report); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+		return s.load(ctx, batch, report)
+	}()
+
+	s.finish(ctx, report)
+	report.FinishedAt = time.Now().UTC()
+
+	s.logger.Info("Import finished",
+		zap.String("format", format),
+		zap.Int64("processed", report.Processed),
+		zap.Int64("inserted", report.Inserted),
+		zap.Int64("updated", report.Updated),
+		zap.Int64("rejected", report.Rejected),
+		zap.Error(err),
+	)
+	return report, err
+}
+
+
+func (s *ImportService) load(ctx context.Context, batch []repository.ImportRow, report *models.ImportReport) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	result, err := s.importer.ImportUsers(ctx, batch, func(ctx context.Context, result *repository.ImportBatchResult) error {
+		if result.Inserted+result.Updated == 0 {
+			return nil
+		}
+		// Committed with the batch, so clients polling the changes feed and
+		// the rank table never miss a batch that landed.
+		if err := s.users.changeLog.RecordBulkChange(ctx); err != nil {
+			return err
+		}
+		repository.AfterCommit(ctx, func(ctx context.Context) {
+			for _, id := range result.UpdatedIDs {
+				if err := s.users.cache.InvalidateUser(ctx, id); err != nil {
+					s.logger.Warn("Failed to invalidate user cache", zap.Error(err))
+				}
+			}
+			s.evaluateWatches(ctx, result.RatingChanges)
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	report.Inserted += result.Inserted
+	report.Updated += result.Updated
+	report.Unchanged += int64(len(batch)) - result.Inserted - result.Updated - int64(len(result.Rejected))
+
+	if len(result.Rejected) > 0 {
+		byLine := make(map[int64]repository.ImportRow, len(batch))
+		for _, row := range batch {
+			byLine[row.Line] = row
+		}
+		for _, rej := range result.Rejected {
+			row := byLine[rej.Line]
+			userID := row.ID
+			if row.MatchUsername {
+				userID = ""
+			}
+			s.reject(report, rej.Line, userID, row.Username, rej.Reason)
+		}
+	}
+
+	s.logger.Info("Import batch loaded",
+		zap.Int("rows", len(batch)),
+		zap.Int64("inserted", result.Inserted),
+		zap.Int64("updated", result.Updated),
+		zap.Int("rejected", len(result.Rejected)),
+	)
+	return nil
+}
+
+// finish runs once the loaded batches are committed, even if the import
+// stopped early: the rank indexes are rebuilt, and caches are told the whole
+// board may have changed. Each batch has already told clients.
+func (s *ImportService) finish(ctx context.Context, report *models.ImportReport) {
+	if report.Inserted+report.Updated == 0 {
+		return
+	}
+
+	// The caller's context may be what stopped the import.
+	ctx = tenant.Detach(ctx)
+
+	if report.Inserted+report.Updated >= RebuildIndexThreshold && s.rebuilding.CompareAndSwap(false, true) {
+		report.IndexRebuildStarted = true
+		s.background.Add(1)
+		go func() {
+			defer s.background.Done()
+			defer s.rebuilding.Store(false)
+			started := time.Now()
+			if err := s.importer.RebuildRankIndexes(ctx); err != nil {
+				s.logger.Error("Failed to rebuild rank indexes after import", zap.Error(err))
+				return
+			}
+			s.logger.Info("Rank indexes rebuilt after import", zap.Duration("took", time.Since(started)))
+		}()
+	}
+
+	s.users.invalidateBoard(ctx)
+	s.users.afterReplicaLag(ctx, s.users.invalidateBoard)
+	s.users.search.index(ctx).reset()
+}
+
+// evaluateWatches feeds a committed batch's rating changes to the rating
+// listeners, in the background, as if each had been a single update. They
+// are evaluated against the board after the batch, so a rank threshold
+// crossed by several changes at once may be reported late or not at all.
+func (s *ImportService) evaluateWatches(ctx context.Context, changes []repository.ImportRatingChange) {
+	if len(changes) == 0 {
+		return
+	}
+	now := time.Now().UTC()
+	s.background.Add(1)
+	go func(ctx context.Context) {
+		defer s.background.Done()
+		// Listeners evaluate the board right after the write.
+		ctx = repository.WithPrimaryReads(ctx)
+		for _, ch := range changes {
+			s.users.notifyRatingChange(ctx, models.RatingChange{
+				UserID:    ch.ID,
+				Username:  ch.Username,
+				OldRating: ch.OldRating,
+				NewRating: ch.NewRating,
+				Timestamp: now,
+			})
+		}
+	}(tenant.Detach(ctx))
+}
+
+
+func (s *ImportService) reject(report *models.ImportReport, line int64, userID, username, reason string) {
+	report.Rejected++
+	if len(report.Errors) >= MaxImportErrors {
+		report.ErrorsTruncated = true
+		return
+	}
+	report.Errors = append(report.Errors, models.ImportRowError{
+		Line:     line,
+		UserID:   userID,
+		Username: username,
+		Error:    reason,
+	})
+}
+
+// importRecord is one parsed row; err is set when the row is rejected.
+type importRecord struct {
+	line     int64
+	userID   string
+	username string
+	rating   int32
+	err      error
+}
+
+// importSource yields records until io.EOF. Any other error means the input
+// cannot be read further.
+type importSource interface {
+	next() (importRecord, error)
+}
+
+
+func newImportSource(r io.Reader, format string) (importSource, error) {
+	switch format {
+	case ImportFormatCSV:
+		return newCSVSource(r)
+	case ImportFormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), MaxImportLineBytes)
+		return &ndjsonSource{scanner: scanner}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q (csv or ndjson)", ErrInvalidImport, format)
+	}
+}
+
+// validateImportRow fills rec from raw values and validates it the way
+// CreateUser would.
+func validateImportRow(rec *importRecord, userID, username, rating string) {
+	rec.userID = strings.TrimSpace(userID)
+	rec.username = strings.TrimSpace(username)
+
+	if len(rec.userID) > 255 {
+		rec.err = errors.New("user_id must not exceed 255 characters")
+		return
+	}
+	if err := ValidateUsername(rec.username); err != nil {
+		rec.err = fmt.Errorf("invalid username: %w", err)
+		return
+	}
+
+	v, err := strconv.ParseInt(strings.TrimSpace(rating), 10, 32)
+	if err != nil {
+		rec.err = fmt.Errorf("invalid rating: must be an integer, got %q", rating)
+		return
+	}
+	rec.rating = int32(v)
+	if err := ValidateRating(rec.rating); err != nil {
+		rec.err = fmt.Errorf("invalid rating: %w", err)
+	}
+}
+
+// csvSource reads CSV with a header row naming the columns user_id (or id),
+// username and rating, in any order. Other columns are ignored.
+type csvSource struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+
+func newCSVSource(r io.Reader) (*csvSource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: empty CSV", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad CSV header: %v", ErrInvalidImport, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if name == "id" {
+			name = "user_id"
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"username", "rating"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: CSV header has no %s column", ErrInvalidImport, required)
+		}
+	}
+
+	return &csvSource{reader: reader, columns: columns}, nil
+}
+
+
+func (s *csvSource) next() (importRecord, error) {
+	record, err := s.reader.Read()
+	if err == io.EOF {
+		return importRecord{}, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return importRecord{line: int64(parseErr.StartLine), err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return importRecord{}, err
+	}
+
+	line, _ := s.reader.FieldPos(0)
+	rec := importRecord{line: int64(line)}
+
+	field := func(name string) string {
+		if i, ok := s.columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	validateImportRow(&rec, field("user_id"), field("username"), field("rating"))
+	return rec, nil
+}
+
+// ndjsonSource reads one JSON object per line with the fields user_id
+// (optional), username and rating. Blank lines are skipped.
+type ndjsonSource struct {
+	scanner *bufio.Scanner
+	line    int64
+}
+
+
+func (s *ndjsonSource) next() (importRecord, error) {
+	for s.scanner.Scan() {
+		s.line++
+		data := s.scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+
+		rec := importRecord{line: s.line}
+		var raw struct {
+			UserID   string          `json:"user_id"`
+			Username string          `json:"username"`
+			Rating   json.RawMessage `json:"rating"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			rec.err = fmt.Errorf("invalid JSON: %v", err)
+			return rec, nil
+		}
+		if len(raw.Rating) == 0 {
+			rec.userID, rec.username = raw.UserID, raw.Username
+			rec.err = errors.New("invalid rating: rating is required")
+			return rec, nil
+		}
+		validateImportRow(&rec, raw.UserID, raw.Username, string(raw.Rating))
+		return rec, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return importRecord{}, fmt.Errorf("%w: line %d: %v", ErrInvalidImport, s.line+1, err)
+	}
+	return importRecord{}, io.EOF
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"leaderboard-system/cache"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+)
+
+// The board holds alice 1000 and bob 1200, and carol 1300 deactivated.
+// Imports run in batches of two, each writing batch recording one bulk
+// change.
+func TestImportReport(t *testing.T) {
+	cases := []struct {
+		name                         string
+		csv                          string
+		inserted, updated, unchanged int64
+		errors                       []models.ImportRowError
+		versions                     int64
+		ratings                      map[string]int32
+	}{
+		{
+			name: "insert, update and leave alone",
+			csv: "user_id,username,rating\n" +
+				"u1,alice,1100\n" +
+				"u2,bob,1200\n" +
+				"u4,dave,900\n",
+			inserted: 1, updated: 1, unchanged: 1,
+			versions: 2,
+			ratings:  map[string]int32{"alice": 1100, "bob": 1200, "dave": 900},
+		},
+		{
+			name: "rows without an id match by username",
+			csv: "username,rating\n" +
+				"ALICE,1500\n" +
+				"erin,800\n",
+			inserted: 1, updated: 1,
+			versions: 1,
+			ratings:  map[string]int32{"ALICE": 1500, "erin": 800},
+		},
+		{
+			name: "batches that change nothing record nothing",
+			csv: "user_id,username,rating\n" +
+				"u1,alice,1000\n" +
+				"u2,bob,1200\n",
+			unchanged: 2,
+			ratings:   map[string]int32{"alice": 1000, "bob": 1200},
+		},
+		{
+			name: "rejections",
+			csv: "user_id,username,rating\n" +
+				"u3,carol,1400\n" +
+				"u5,BOB,1000\n" +
+				"u4,dave,abc\n" +
+				"u6,frank,1000\n" +
+				"u6,frank2,1000\n" +
+				"u7,Frank,1000\n",
+			inserted: 1,
+			errors: []models.ImportRowError{
+				// The first batch loads before line 4 is read.
+				{Line: 2, UserID: "u3", Username: "carol", Error: "user is deactivated"},
+				{Line: 3, UserID: "u5", Username: "BOB", Error: "username is taken by another user"},
+				{Line: 4, UserID: "u4", Username: "dave", Error: `invalid rating: must be an integer, got "abc"`},
+				{Line: 6, UserID: "u6", Username: "frank2", Error: "user_id already appears on line 5"},
+				{Line: 7, UserID: "u7", Username: "Frank", Error: "username already appears on line 5"},
+			},
+			versions: 1,
+			ratings:  map[string]int32{"bob": 1200, "frank": 1000},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := repository.NewMemoryUserStore()
+			changeLog := NewChangeLogService(repository.NewMemoryChangeLog(), store, zap.NewNop())
+			users := NewUserService(store, changeLog, cache.NewMemoryCache(1000), zap.NewNop())
+			for _, u := range []models.User{
+				{ID: "u1", Username: "alice", Rating: 1000},
+				{ID: "u2", Username: "bob", Rating: 1200},
+				{ID: "u3", Username: "carol", Rating: 1300},
+			} {
+				user := u
+				if err := store.CreateUser(ctx, &user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := store.DeactivateUser(ctx, "u3"); err != nil {
+				t.Fatal(err)
+			}
+
+			s := NewImportService(users, store, 2, zap.NewNop())
+			report, err := s.Import(ctx, strings.NewReader(tc.csv), ImportFormatCSV)
+			s.Wait()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := [3]int64{report.Inserted, report.Updated, report.Unchanged}
+			if want := [3]int64{tc.inserted, tc.updated, tc.unchanged}; got != want {
+				t.Errorf("inserted, updated, unchanged = %v, want %v", got, want)
+			}
+			if report.Rejected != int64(len(tc.errors)) {
+				t.Errorf("rejected = %d, want %d", report.Rejected, len(tc.errors))
+			}
+			if len(tc.errors) == 0 {
+				tc.errors = []models.ImportRowError{}
+			}
+			if !reflect.DeepEqual(report.Errors, tc.errors) {
+				t.Errorf("errors = %+v, want %+v", report.Errors, tc.errors)
+			}
+
+			version, err := changeLog.CurrentVersion(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tc.versions {
+				t.Errorf("change log version = %d, want %d", version, tc.versions)
+			}
+			for name, rating := range tc.ratings {
+				user, err := store.GetUserByUsername(ctx, name)
+				if err != nil || user == nil || user.Rating != rating {
+					t.Errorf("%s = %+v, %v, want rating %d", name, user, err, rating)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"leaderboard-system/config"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+const (
+	// RankSyncBatchSize is how many leaderboard changes one sync transaction
+	// applies.
+	RankSyncBatchSize = 1000
+	// maxRankSyncBatches bounds how many batches one sync applies before
+	// yielding to the next tick.
+	maxRankSyncBatches = 10
+
+	// rankAsOfRefresh is how long a read of the table's as_of is reused.
+	rankAsOfRefresh = time.Second
+)
+
+// ranksAsOf remembers the last as_of read from the rank table. A remembered
+// value is older than the table, so it never overstates freshness.
+type ranksAsOf struct {
+	mu     sync.Mutex
+	value  time.Time
+	readAt time.Time
+}
+
+// SetRankTable makes ranks and leaderboard pages come from the materialized
+// user_ranks table once it has been computed.
+func (s *UserService) SetRankTable(ranks *repository.RankRepository) {
+	s.ranks = ranks
+}
+
+// calculateRank reads the rank from the rank table, or computes it live when
+// the table does not rank the user yet or the caller must see its own write.
+// A user that is not active has no rank: ErrUserNotFound.
+func (s *UserService) calculateRank(ctx context.Context, userID string) (int64, error) {
+	if s.ranks != nil && !repository.ReadsFromPrimary(ctx) {
+		rank, err := s.ranks.GetUserRank(ctx, userID)
+		if err != nil {
+			s.logger.Warn("Failed to read materialized rank", zap.Error(err))
+		} else if rank > 0 {
+			return rank, nil
+		}
+	}
+	rank, err := s.repo.CalculateRank(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if rank == 0 {
+		return 0, ErrUserNotFound
+	}
+	return rank, nil
+}
+
+// RanksAsOf returns a time every rank GetUserRank returns after it was
+// called is at least as current as. Read it before the ranks it describes.
+// Without a rank table, or when the caller reads its own writes, ranks are
+// computed live and current now.
+func (s *UserService) RanksAsOf(ctx context.Context) time.Time {
+	now := time.Now().UTC()
+	if s.ranks == nil || repository.ReadsFromPrimary(ctx) {
+		return now
+	}
+
+	s.asOf.mu.Lock()
+	defer s.asOf.mu.Unlock()
+
+	if now.Sub(s.asOf.readAt) >= rankAsOfRefresh {
+		st, err := s.ranks.GetState(repository.WithPrimaryReads(ctx))
+		if err != nil {
+			s.logger.Warn("Failed to read rank table state", zap.Error(err))
+		} else {
+			s.asOf.value = time.Time{}
+			if st.Ready() {
+				// Ranks may be read from a replica up to the allowed lag behind.
+				s.asOf.value = st.AsOf.UTC().Add(-s.replicaLag)
+			}
+			s.asOf.readAt = now
+		}
+	}
+
+	if s.asOf.value.IsZero() {
+		return now
+	}
+	return s.asOf.value
+}
+
+// RankTableState reports the rank table's progress, and false when ranks are
+// not materialized.
+func (s *UserService) RankTableState(ctx context.Context) (repository.RankState, bool, error) {
+	if s.ranks == nil {
+		return repository.RankState{}, false, nil
+	}
+	st, err := s.ranks.GetState(ctx)
+	return st, true, err
+}
+
+// RankWorker keeps the user_ranks table in step with the leaderboard change
+// log. Every instance may run one; they take turns through a row lock.
+type RankWorker struct {
+	users          *UserService
+	ranks          *repository.RankRepository
+	interval       time.Duration
+	recomputeEvery time.Duration
+	logger         *zap.Logger
+}
+
+
+func NewRankWorker(users *UserService, ranks *repository.RankRepository, cfg *config.RanksConfig, logger *zap.Logger) *RankWorker {
+	w := &RankWorker{
+		users:          users,
+		ranks:          ranks,
+		interval:       cfg.SyncInterval,
+		recomputeEvery: cfg.RecomputeInterval,
+		logger:         logger,
+	}
+	if w.interval <= 0 {
+		w.interval = time.Second
+	}
+	return w
+}
+
+// Run syncs the table every interval until ctx is done.
+func (w *RankWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Sync(ctx); err != nil && ctx.Err() == nil {
+			w.logger.Error("Rank table sync failed", zap.Error(err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sync applies the changes recorded since the last sync, or recomputes the
+// table when they cannot be applied one by one or a recompute is due.
+func (w *RankWorker) Sync(ctx context.Context) error {
+	for i := 0; i < maxRankSyncBatches; i++ {
+		res, err := w.ranks.ApplyChanges(ctx, RankSyncBatchSize)
+		if err != nil {
+			return err
+		}
+		if res.Busy {
+			return nil
+		}
+		if res.NeedsRecompute || w.recomputeDue(res.State) {
+			return w.Recompute(ctx)
+		}
+		w.invalidate(ctx, res.Tenants)
+		if res.CaughtUp {
+			return nil
+		}
+	}
+	return nil
+}
+
+
+func (w *RankWorker) recomputeDue(st repository.RankState) bool {
+	if w.recomputeEvery <= 0 {
+		return false
+	}
+	return st.RecomputedAt == nil || time.Since(*st.RecomputedAt) >= w.recomputeEvery
+}
+
+// Recompute rebuilds the whole table, unless another instance is busy with it.
+func (w *RankWorker) Recompute(ctx context.Context) error {
+	start := time.Now()
+	tenants, done, err := w.ranks.Recompute(ctx)
+	if err != nil || !done {
+		return err
+	}
+
+	w.logger.Info("Rank table recomputed",
+		zap.Duration("duration", time.Since(start)),
+		zap.Int("tenants_changed", len(tenants)),
+	)
+	w.invalidate(ctx, tenants)
+	return nil
+}
+
+// invalidate retires the cached ranks and pages of tenants that the table
+// just replaced, and again once replicas have caught up with it.
+func (w *RankWorker) invalidate(ctx context.Context, tenants []string) {
+	for _, id := range tenants {
+		ctx := tenant.WithID(ctx, id)
+		w.users.invalidateBoard(ctx)
+		w.users.afterReplicaLag(ctx, w.users.invalidateBoard)
+	}
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"leaderboard-system/models"
+)
+
+// Rating distributions the seed generator draws from.
+const (
+	SeedUniform  = "uniform"
+	SeedNormal   = "normal"
+	SeedPowerLaw = "power-law"
+)
+
+const (
+	DefaultSeedUsers       = 500
+	DefaultSeedNamePattern = "user%03d"
+	DefaultSeedAlpha       = 1.5
+
+	// MaxSeedUsers keeps a typo from filling the disk.
+	MaxSeedUsers = 50_000_000
+
+	// seedTiePool is how many recent ratings a tie is drawn from.
+	seedTiePool = 1024
+)
+
+var ErrInvalidSeed = NewValidationError("INVALID_SEED", "invalid seed options")
+
+// SeedOptions describes a synthetic user population. The same options,
+// RandomSeed included, always produce the same users with the same IDs, so
+// seeding twice updates rather than duplicates.
+type SeedOptions struct {
+	Users        int
+	Distribution string
+	RandomSeed   int64
+
+	// TieDensity is the share of users, from 0 to 1, given the rating of a
+	// recently generated user instead of a fresh draw.
+	TieDensity float64
+
+	// NamePatterns are fmt patterns with one integer verb, filled with the
+	// user's 1-based number, like "player_%06d". Each user gets one at random.
+	NamePatterns []string
+
+	// Mean and StdDev shape the normal distribution; zero picks the middle of
+	// the rating range and a sixth of its width. Alpha is the power-law
+	// exponent: the higher, the more users sit near MinRating.
+	Mean   float64
+	StdDev float64
+	Alpha  float64
+}
+
+// Validate fills in defaults and checks the options.
+func (o *SeedOptions) Validate() error {
+	if o.Users == 0 {
+		o.Users = DefaultSeedUsers
+	}
+	if o.Distribution == "" {
+		o.Distribution = SeedUniform
+	}
+	if len(o.NamePatterns) == 0 {
+		o.NamePatterns = []string{DefaultSeedNamePattern}
+	}
+	if o.Mean == 0 {
+		o.Mean = float64(MinRating+MaxRating) / 2
+	}
+	if o.StdDev == 0 {
+		o.StdDev = float64(MaxRating-MinRating) / 6
+	}
+	if o.Alpha == 0 {
+		o.Alpha = DefaultSeedAlpha
+	}
+
+	if o.Users < 0 || o.Users > MaxSeedUsers {
+		return fmt.Errorf("%w: users must be between 1 and %d", ErrInvalidSeed, MaxSeedUsers)
+	}
+	switch o.Distribution {
+	case SeedUniform, SeedNormal, SeedPowerLaw:
+	default:
+		return fmt.Errorf("%w: unknown distribution %q (want %s, %s or %s)", ErrInvalidSeed, o.Distribution, SeedUniform, SeedNormal, SeedPowerLaw)
+	}
+	if o.TieDensity < 0 || o.TieDensity > 1 {
+		return fmt.Errorf("%w: tie density must be between 0 and 1", ErrInvalidSeed)
+	}
+	if o.Mean < MinRating || o.Mean > MaxRating {
+		return fmt.Errorf("%w: mean must be between %d and %d", ErrInvalidSeed, MinRating, MaxRating)
+	}
+	if o.StdDev < 0 {
+		return fmt.Errorf("%w: stddev must not be negative", ErrInvalidSeed)
+	}
+	if o.Alpha <= 0 || o.Alpha == 1 {
+		return fmt.Errorf("%w: alpha must be positive and not 1", ErrInvalidSeed)
+	}
+
+	seen := make(map[string]bool, len(o.NamePatterns))
+	for _, p := range o.NamePatterns {
+		if seen[p] {
+			return fmt.Errorf("%w: name pattern %q given twice", ErrInvalidSeed, p)
+		}
+		seen[p] = true
+
+		// Too many or too few verbs show up as %!.
+		for _, n := range []int{1, o.Users} {
+			name := fmt.Sprintf(p, n)
+			if strings.Contains(name, "%!") {
+				return fmt.Errorf("%w: name pattern %q must have exactly one integer verb, like %%d", ErrInvalidSeed, p)
+			}
+			if err := ValidateUsername(name); err != nil {
+				return fmt.Errorf("%w: name pattern %q gives %q: %v", ErrInvalidSeed, p, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SeedGenerator produces the users SeedOptions describe, one at a time, so a
+// population of millions never sits in memory.
+type SeedGenerator struct {
+	opts SeedOptions
+	rng  *rand.Rand
+	n    int
+
+	recent []int32
+}
+
+// NewSeedGenerator validates opts and returns a generator for them.
+func NewSeedGenerator(opts SeedOptions) (*SeedGenerator, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return &SeedGenerator{
+		opts:   opts,
+		rng:    rand.New(rand.NewSource(opts.RandomSeed)),
+		recent: make([]int32, 0, seedTiePool),
+	}, nil
+}
+
+// Next returns the next user, and false once all of them were generated.
+func (g *SeedGenerator) Next() (models.User, bool) {
+	if g.n >= g.opts.Users {
+		return models.User{}, false
+	}
+	g.n++
+
+	// Every draw comes from the one source in a fixed order, which is what
+	// makes a seed reproducible.
+	id, err := uuid.NewRandomFromReader(g.rng)
+	if err != nil {
+		// rand.Rand reads never fail.
+		panic(err)
+	}
+	pattern := g.opts.NamePatterns[0]
+	if len(g.opts.NamePatterns) > 1 {
+		pattern = g.opts.NamePatterns[g.rng.Intn(len(g.opts.NamePatterns))]
+	}
+
+	return models.User{
+		ID:       id.String(),
+		Username: fmt.Sprintf(pattern, g.n),
+		Rating:   g.rating(),
+	}, true
+}
+
+
+func (g *SeedGenerator) rating() int32 {
+	if len(g.recent) > 0 && g.rng.Float64() < g.opts.TieDensity {
+		return g.recent[g.rng.Intn(len(g.recent))]
+	}
+
+	r := g.draw()
+	if len(g.recent) < seedTiePool {
+		g.recent = append(g.recent, r)
+	} else {
+		g.recent[g.n%seedTiePool] = r
+	}
+	return r
+}
+
+// draw takes a fresh rating from the distribution, within MinRating and
+// MaxRating.
+func (g *SeedGenerator) draw() int32 {
+	switch g.opts.Distribution {
+	case SeedNormal:
+		// Redraw rather than clamp, which would pile users up at the bounds.
+		for i := 0; i < 100; i++ {
+			r := math.Round(g.opts.Mean + g.rng.NormFloat64()*g.opts.StdDev)
+			if r >= MinRating && r <= MaxRating {
+				return int32(r)
+			}
+		}
+		return int32(math.Round(g.opts.Mean))
+
+	case SeedPowerLaw:
+		// Inverse CDF of a power law bounded to [1, span]: most users near
+		// MinRating, a long tail towards MaxRating.
+		span := float64(MaxRating - MinRating + 1)
+		e := 1 - g.opts.Alpha
+		v := math.Pow(1-g.rng.Float64()*(1-math.Pow(span, e)), 1/e)
+		r := MinRating + int32(v) - 1
+		if r > MaxRating {
+			r = MaxRating
+		}
+		return r
+
+	default:
+		return MinRating + g.rng.Int31n(MaxRating-MinRating+1)
+	}
+}
+
+// WriteCSV writes every remaining user as import CSV.
+func (g *SeedGenerator) WriteCSV(w io.Writer) error {
+	bw := bufio.NewWriterSize(w, 64<<10)
+	cw := csv.NewWriter(bw)
+	if err := cw.Write([]string{"user_id", "username", "rating"}); err != nil {
+		return err
+	}
+	for {
+		u, ok := g.Next()
+		if !ok {
+			break
+		}
+		if err := cw.Write([]string{u.ID, u.Username, strconv.Itoa(int(u.Rating))}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Seed generates the users opts describes and loads them into the tenant in
+// ctx through Import, so they are validated, COPYed in batches and announced
+// like any other import. Generated IDs are stable, so seeding again with the
+// same options updates those users in place.
+func (s *ImportService) Seed(ctx context.Context, opts SeedOptions) (*models.ImportReport, error) {
+	gen, err := NewSeedGenerator(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(gen.WriteCSV(pw))
+	}()
+	// Unblocks the generator when Import stops reading early.
+	defer pr.Close()
+
+	return s.Import(ctx, pr, ImportFormatCSV)
+}
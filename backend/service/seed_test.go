@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+	"leaderboard-system/cache"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+)
+
+func generate(t *testing.T, opts SeedOptions) []models.User {
+	t.Helper()
+	gen, err := NewSeedGenerator(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []models.User
+	for {
+		u, ok := gen.Next()
+		if !ok {
+			return users
+		}
+		users = append(users, u)
+	}
+}
+
+// The same options must always produce the same users, IDs included, within
+// the rating range and under unique names.
+func TestSeedGeneratorIsDeterministic(t *testing.T) {
+	for _, dist := range []string{SeedUniform, SeedNormal, SeedPowerLaw} {
+		t.Run(dist, func(t *testing.T) {
+			opts := SeedOptions{
+				Users:        2000,
+				Distribution: dist,
+				RandomSeed:   42,
+				TieDensity:   0.3,
+				NamePatterns: []string{"player_%05d", "bot%d"},
+			}
+			users := generate(t, opts)
+			if len(users) != opts.Users {
+				t.Fatalf("generated %d users, want %d", len(users), opts.Users)
+			}
+			if again := generate(t, opts); !reflect.DeepEqual(users, again) {
+				t.Fatal("the same options generated different users")
+			}
+			opts.RandomSeed++
+			if other := generate(t, opts); reflect.DeepEqual(users, other) {
+				t.Fatal("another random seed generated the same users")
+			}
+
+			names := make(map[string]bool, len(users))
+			for _, u := range users {
+				if u.Rating < MinRating || u.Rating > MaxRating {
+					t.Fatalf("%s rated %d, outside %d to %d", u.Username, u.Rating, MinRating, MaxRating)
+				}
+				if names[u.Username] {
+					t.Fatalf("username %s generated twice", u.Username)
+				}
+				names[u.Username] = true
+			}
+		})
+	}
+}
+
+func TestSeedTieDensity(t *testing.T) {
+	distinct := func(density float64) int {
+		ratings := map[int32]bool{}
+		for _, u := range generate(t, SeedOptions{Users: 200, RandomSeed: 7, TieDensity: density}) {
+			ratings[u.Rating] = true
+		}
+		return len(ratings)
+	}
+	if n := distinct(1); n != 1 {
+		t.Errorf("tie density 1 gave %d distinct ratings, want 1", n)
+	}
+	if n := distinct(0); n < 150 {
+		t.Errorf("tie density 0 gave only %d distinct ratings of 200", n)
+	}
+}
+
+func TestSeedOptionsValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		opts SeedOptions
+	}{
+		{"too many users", SeedOptions{Users: MaxSeedUsers + 1}},
+		{"unknown distribution", SeedOptions{Distribution: "zipf"}},
+		{"tie density above 1", SeedOptions{TieDensity: 1.5}},
+		{"alpha of 1", SeedOptions{Distribution: SeedPowerLaw, Alpha: 1}},
+		{"pattern without a verb", SeedOptions{NamePatterns: []string{"player"}}},
+		{"pattern with two verbs", SeedOptions{NamePatterns: []string{"p%d_%d"}}},
+		{"pattern given twice", SeedOptions{NamePatterns: []string{"p%d", "p%d"}}},
+		{"pattern making invalid names", SeedOptions{NamePatterns: []string{"bad name %d"}}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.opts.Validate(); !errors.Is(err, ErrInvalidSeed) {
+				t.Fatalf("Validate = %v, want %v", err, ErrInvalidSeed)
+			}
+		})
+	}
+}
+
+// Seeding twice with the same options finds every user already in place.
+func TestSeedTwiceUpdatesInPlace(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryUserStore()
+	changeLog := NewChangeLogService(repository.NewMemoryChangeLog(), store, zap.NewNop())
+	users := NewUserService(store, changeLog, cache.NewMemoryCache(1000), zap.NewNop())
+	s := NewImportService(users, store, 100, zap.NewNop())
+	opts := SeedOptions{Users: 250, Distribution: SeedNormal, RandomSeed: 3, TieDensity: 0.2}
+
+	first, err := s.Seed(ctx, opts)
+	s.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Seed(ctx, opts)
+	s.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Inserted != 250 || first.Rejected != 0 {
+		t.Fatalf("first seed inserted %d and rejected %d, want 250 and 0", first.Inserted, first.Rejected)
+	}
+	if second.Inserted != 0 || second.Updated != 0 || second.Unchanged != 250 {
+		t.Fatalf("second seed inserted %d, updated %d, left %d, want 0, 0, 250", second.Inserted, second.Updated, second.Unchanged)
+	}
+}
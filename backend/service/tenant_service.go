@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+const (
+	// DefaultTenantRPS and DefaultTenantBurst are the rate limit of a tenant
+	// created without one, per client IP.
+	DefaultTenantRPS   = 100
+	DefaultTenantBurst = 200
+
+	// APIKeyPrefix starts every issued API key.
+	APIKeyPrefix = "lb_"
+
+	// tenantCacheTTL bounds how long a changed or rotated tenant keeps
+	// resolving to its old settings on other instances.
+	tenantCacheTTL = 30 * time.Second
+
+	// tenantMissTTL is how long an unknown tenant ID or API key is remembered
+	// as unknown, so a client sending random ones costs no query per request.
+	// A tenant created on another instance is unknown here for at most this
+	// long. maxTenantMisses bounds the misses remembered.
+	tenantMissTTL   = 5 * time.Second
+	maxTenantMisses = 10000
+
+	maxTenantNameLength = 255
+	apiKeyBytes         = 32
+	apiKeyPrefixLength  = len(APIKeyPrefix) + 8
+)
+
+var (
+	ErrTenantNotFound = NewError(ErrNotFound, "TENANT_NOT_FOUND", "tenant not found")
+	ErrTenantExists   = NewError(ErrConflict, "TENANT_EXISTS", "tenant already exists")
+	ErrInvalidTenant  = NewValidationError("INVALID_TENANT", "invalid tenant")
+	ErrInvalidAPIKey  = NewError(ErrUnauthorized, "INVALID_API_KEY", "invalid API key")
+)
+
+// TenantUpdate holds the settings an admin may change; nil fields are left
+// as they are.
+type TenantUpdate struct {
+	Name           *string  `json:"name"`
+	RateLimitRPS   *float64 `json:"rate_limit_rps"`
+	RateLimitBurst *int     `json:"rate_limit_burst"`
+}
+
+// TenantService creates and configures tenants and resolves the tenant of a
+// request. Resolved tenants are cached in process for tenantCacheTTL, and
+// unknown IDs and keys for tenantMissTTL, so the lookup on every request
+// seldom reaches Postgres.
+type TenantService struct {
+	repo   *repository.TenantRepository
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	byID  map[string]cachedTenant
+	byKey map[string]cachedTenant
+
+	// idMisses and keyMisses hold when an unknown ID or key hash was looked up.
+	idMisses  map[string]time.Time
+	keyMisses map[string]time.Time
+}
+
+
+type cachedTenant struct {
+	tenant    *models.Tenant
+	fetchedAt time.Time
+}
+
+
+func NewTenantService(repo *repository.TenantRepository, logger *zap.Logger) *TenantService {
+	return &TenantService{
+		repo:   repo,
+		logger: logger,
+		byID:   make(map[string]cachedTenant),
+		byKey:  make(map[string]cachedTenant),
+
+		idMisses:  make(map[string]time.Time),
+		keyMisses: make(map[string]time.Time),
+	}
+}
+
+// CreateTenant creates a tenant with its own API key and returns the key,
+// which is not stored and cannot be shown again. A zero rate limit takes the
+// default.
+func (s *TenantService) CreateTenant(ctx context.Context, id, name string, rps float64, burst int) (*models.Tenant, string, error) {
+	if !tenant.ValidID(id) {
+		return nil, "", fmt.Errorf("%w: id must be 1-64 lowercase letters, digits, '-' or '_'", ErrInvalidTenant)
+	}
+	if rps == 0 {
+		rps = DefaultTenantRPS
+	}
+	if burst == 0 {
+		burst = DefaultTenantBurst
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = id
+	}
+	t := &models.Tenant{ID: id, Name: name, RateLimitRPS: rps, RateLimitBurst: burst}
+	if err := validateTenant(t); err != nil {
+		return nil, "", err
+	}
+
+	existing, err := s.repo.GetTenant(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if existing != nil {
+		return nil, "", ErrTenantExists
+	}
+
+	key, err := issueAPIKey(t)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.repo.CreateTenant(ctx, t); err != nil {
+		return nil, "", err
+	}
+	s.forget(t)
+
+	s.logger.Info("Tenant created", zap.String("tenant_id", id))
+	return t, key, nil
+}
+
+// GetTenant returns ErrTenantNotFound when there is no such tenant.
+func (s *TenantService) GetTenant(ctx context.Context, id string) (*models.Tenant, error) {
+	t, err := s.repo.GetTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrTenantNotFound
+	}
+	return t, nil
+}
+
+
+func (s *TenantService) ListTenants(ctx context.Context) ([]models.Tenant, error) {
+	return s.repo.ListTenants(ctx)
+}
+
+// UpdateTenant changes a tenant's name or rate limit. Other instances pick
+// the change up within tenantCacheTTL.
+func (s *TenantService) UpdateTenant(ctx context.Context, id string, update TenantUpdate) (*models.Tenant, error) {
+	t, err := s.GetTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if update.Name != nil {
+		t.Name = strings.TrimSpace(*update.Name)
+		updates["name"] = t.Name
+	}
+	if update.RateLimitRPS != nil {
+		t.RateLimitRPS = *update.RateLimitRPS
+		updates["rate_limit_rps"] = t.RateLimitRPS
+	}
+	if update.RateLimitBurst != nil {
+		t.RateLimitBurst = *update.RateLimitBurst
+		updates["rate_limit_burst"] = t.RateLimitBurst
+	}
+	if len(updates) == 0 {
+		return t, nil
+	}
+	if err := validateTenant(t); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.repo.UpdateTenant(ctx, id, updates)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTenantNotFound
+	}
+	s.forget(t)
+
+	s.logger.Info("Tenant updated", zap.String("tenant_id", id))
+	return s.GetTenant(ctx, id)
+}
+
+// RotateAPIKey issues the tenant a new API key and returns it. The old key
+// stops working at once here and within tenantCacheTTL elsewhere.
+func (s *TenantService) RotateAPIKey(ctx context.Context, id string) (*models.Tenant, string, error) {
+	t, err := s.GetTenant(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	old := *t
+
+	key, err := issueAPIKey(t)
+	if err != nil {
+		return nil, "", err
+	}
+	ok, err := s.repo.UpdateTenant(ctx, id, map[string]interface{}{
+		"api_key_hash":   t.APIKeyHash,
+		"api_key_prefix": t.APIKeyPrefix,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if !ok {
+		return nil, "", ErrTenantNotFound
+	}
+	s.forget(&old)
+
+	s.logger.Info("Tenant API key rotated", zap.String("tenant_id", id), zap.String("api_key_prefix", t.APIKeyPrefix))
+	return t, key, nil
+}
+
+// ResolveAPIKey returns the tenant key belongs to, or ErrInvalidAPIKey.
+func (s *TenantService) ResolveAPIKey(ctx context.Context, key string) (*models.Tenant, error) {
+	hash := hashAPIKey(key)
+	if t, ok := s.cached(s.byKey, hash); ok {
+		return t, nil
+	}
+	if s.missed(s.keyMisses, hash) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	t, err := s.repo.GetTenantByAPIKeyHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		s.rememberMiss(s.keyMisses, hash)
+		return nil, ErrInvalidAPIKey
+	}
+	s.remember(t)
+	return t, nil
+}
+
+// ResolveTenant is GetTenant through the cache, for the request path.
+func (s *TenantService) ResolveTenant(ctx context.Context, id string) (*models.Tenant, error) {
+	if t, ok := s.cached(s.byID, id); ok {
+		return t, nil
+	}
+	if s.missed(s.idMisses, id) {
+		return nil, ErrTenantNotFound
+	}
+
+	t, err := s.GetTenant(ctx, id)
+	if errors.Is(err, ErrTenantNotFound) {
+		s.rememberMiss(s.idMisses, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.remember(t)
+	return t, nil
+}
+
+
+func (s *TenantService) cached(entries map[string]cachedTenant, key string) (*models.Tenant, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := entries[key]
+	if !ok || time.Since(entry.fetchedAt) > tenantCacheTTL {
+		return nil, false
+	}
+	return entry.tenant, true
+}
+
+
+func (s *TenantService) remember(t *models.Tenant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := cachedTenant{tenant: t, fetchedAt: time.Now()}
+	s.byID[t.ID] = entry
+	if t.APIKeyHash != nil {
+		s.byKey[*t.APIKeyHash] = entry
+	}
+}
+
+
+func (s *TenantService) forget(t *models.Tenant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.byID, t.ID)
+	delete(s.idMisses, t.ID)
+	if t.APIKeyHash != nil {
+		delete(s.byKey, *t.APIKeyHash)
+		delete(s.keyMisses, *t.APIKeyHash)
+	}
+}
+
+
+func (s *TenantService) missed(misses map[string]time.Time, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at, ok := misses[key]
+	return ok && time.Since(at) <= tenantMissTTL
+}
+
+// rememberMiss records that key is unknown. When misses is full, expired
+// entries are dropped, and all of them if none has expired: forgetting a miss
+// early only costs a query.
+func (s *TenantService) rememberMiss(misses map[string]time.Time, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(misses) >= maxTenantMisses {
+		for k, at := range misses {
+			if time.Since(at) > tenantMissTTL {
+				delete(misses, k)
+			}
+		}
+		if len(misses) >= maxTenantMisses {
+			for k := range misses {
+				delete(misses, k)
+			}
+		}
+	}
+	misses[key] = time.Now()
+}
+
+
+func validateTenant(t *models.Tenant) error {
+	if t.Name == "" || len(t.Name) > maxTenantNameLength {
+		return fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidTenant, maxTenantNameLength)
+	}
+	if t.RateLimitRPS <= 0 {
+		return fmt.Errorf("%w: rate_limit_rps must be positive", ErrInvalidTenant)
+	}
+	if t.RateLimitBurst < 1 {
+		return fmt.Errorf("%w: rate_limit_burst must be at least 1", ErrInvalidTenant)
+	}
+	return nil
+}
+
+// issueAPIKey generates a key for t and sets its hash and prefix.
+func issueAPIKey(t *models.Tenant) (string, error) {
+	buf := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := APIKeyPrefix + hex.EncodeToString(buf)
+
+	hash := hashAPIKey(key)
+	t.APIKeyHash = &hash
+	t.APIKeyPrefix = key[:apiKeyPrefixLength]
+	return key, nil
+}
+
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
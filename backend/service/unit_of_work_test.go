@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"leaderboard-system/cache"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+)
+
+var errCommit = errors.New("commit failed")
+
+// failingCommitStore runs each unit of work to its end and then fails it, as
+// a commit the database refuses would.
+type failingCommitStore struct {
+	*repository.MemoryUserStore
+}
+
+func (s failingCommitStore) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.MemoryUserStore.InTx(ctx, func(ctx context.Context) error {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		return errCommit
+	})
+}
+
+// A write whose unit of work rolls back must leave no trace: not in the
+// store, the change log, the cache or the search index.
+func TestRolledBackWritesPublishNothing(t *testing.T) {
+	cases := []struct {
+		name  string
+		write func(ctx context.Context, s *UserService) error
+	}{
+		{name: "create", write: func(ctx context.Context, s *UserService) error {
+			_, err := s.CreateUser(ctx, "u2", "bob", 1200)
+			return err
+		}},
+		{name: "rating update", write: func(ctx context.Context, s *UserService) error {
+			_, _, err := s.UpdateUserRating(ctx, "u1", 1500)
+			return err
+		}},
+		{name: "rename", write: func(ctx context.Context, s *UserService) error {
+			name := "bob"
+			_, _, err := s.UpdateUser(ctx, "u1", UserPatch{Username: &name})
+			return err
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := repository.NewMemoryUserStore()
+			if err := store.CreateUser(ctx, &models.User{ID: "u1", Username: "alice", Rating: 1000}); err != nil {
+				t.Fatal(err)
+			}
+			changeLog := NewChangeLogService(repository.NewMemoryChangeLog(), store, zap.NewNop())
+			c := cache.NewMemoryCache(1000)
+			s := NewUserService(failingCommitStore{store}, changeLog, c, zap.NewNop())
+			// Warm the cache and the search index.
+			if _, _, err := s.GetUserByID(ctx, "u1"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.SearchUsers(ctx, "alice", repository.SearchPrefix, 1, 10); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tc.write(ctx, s); !errors.Is(err, errCommit) {
+				t.Fatalf("write = %v, want %v", err, errCommit)
+			}
+
+			if user, err := store.GetUserByID(ctx, "u1"); err != nil || user.Username != "alice" || user.Rating != 1000 {
+				t.Errorf("stored u1 = %+v, %v, want alice at 1000", user, err)
+			}
+			if user, err := store.GetUserByID(ctx, "u2"); err != nil || user != nil {
+				t.Errorf("stored u2 = %+v, %v, want none", user, err)
+			}
+			if version, err := changeLog.CurrentVersion(ctx); err != nil || version != 0 {
+				t.Errorf("change log version = %d, %v, want 0", version, err)
+			}
+			if user, err := c.GetUser(ctx, "u1"); err != nil || user == nil || user.Username != "alice" || user.Rating != 1000 {
+				t.Errorf("cached u1 = %+v, %v, want alice at 1000", user, err)
+			}
+			for query, want := range map[string]int64{"alice": 1, "bob": 0} {
+				resp, err := s.SearchUsers(ctx, query, repository.SearchPrefix, 1, 10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.Total != want {
+					t.Errorf("search for %s found %d, want %d", query, resp.Total, want)
+				}
+			}
+		})
+	}
+}
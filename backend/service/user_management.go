@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+)
+
+const (
+	DefaultUserListPageSize = 50
+	MaxUserListPageSize     = 500
+
+	MaxMetadataKeys      = 32
+	MaxMetadataKeyLength = 64
+	MaxMetadataBytes     = 4096
+)
+
+var (
+	ErrInvalidUserUpdate = NewValidationError("INVALID_USER_UPDATE", "invalid user update")
+	ErrInvalidUserQuery  = NewValidationError("INVALID_USER_QUERY", "invalid user query")
+)
+
+// UserPatch is a partial update of a user; nil fields are left alone.
+// Metadata is merged into the user's like a JSON merge patch: keys set to
+// null are removed and the rest replaced.
+type UserPatch struct {
+	Username *string
+	Metadata models.UserMetadata
+}
+
+// UserListOptions selects and orders the users GET /users lists. Order is
+// asc or desc, by default desc for created_at and rating and asc for
+// username.
+type UserListOptions struct {
+	Username      string
+	MinRating     int32
+	MaxRating     int32
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Sort          string
+	Order         string
+	Page          int
+	PageSize      int
+}
+
+// UpdateUser renames a user and changes its metadata. A rename locks the new
+// username the way CreateUser does, so it cannot race a create or another
+// rename to the same name; a change of case alone keeps the user's own name.
+func (s *UserService) UpdateUser(ctx context.Context, userID string, patch UserPatch) (*models.UserDTO, int64, error) {
+	ctx = repository.WithPrimaryReads(ctx)
+
+	if patch.Username == nil && patch.Metadata == nil {
+		return nil, 0, fmt.Errorf("%w: nothing to update", ErrInvalidUserUpdate)
+	}
+	if patch.Username != nil {
+		if err := ValidateUsername(*patch.Username); err != nil {
+			return nil, 0, invalidField("username", err)
+		}
+	}
+
+	var user *models.User
+	var oldUsername string
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.repo.LockUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return ErrUserNotFound
+		}
+		oldUsername = user.Username
+
+		username := user.Username
+		if patch.Username != nil && *patch.Username != user.Username {
+			username = *patch.Username
+			if err := s.repo.LockUsername(ctx, username); err != nil {
+				return err
+			}
+			// Deactivated users keep their username until they are erased.
+			existing, err := s.repo.GetUserByUsernameUnscoped(ctx, username)
+			if err != nil {
+				return err
+			}
+			if existing != nil && existing.ID != user.ID {
+				return ErrUserExists
+			}
+		}
+
+		metadata, err := mergeMetadata(user.Metadata, patch.Metadata)
+		if err != nil {
+			return err
+		}
+
+		if err := s.repo.UpdateUserProfile(ctx, userID, username, metadata); err != nil {
+			if errors.Is(err, repository.ErrDuplicateUser) {
+				return ErrUserExists
+			}
+			return err
+		}
+		user.Username, user.Metadata = username, metadata
+
+		renamed := username != oldUsername
+		if renamed {
+			if err := s.changeLog.RecordRename(ctx, userID, user.Rating); err != nil {
+				return err
+			}
+		}
+
+		updated := *user
+		repository.AfterCommit(ctx, func(ctx context.Context) {
+			s.invalidateProfile(ctx, &updated, renamed)
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	asOf := s.RanksAsOf(ctx)
+	rank, err := s.GetUserRank(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to calculate rank", zap.Error(err))
+	}
+
+	if user.Username != oldUsername {
+		s.logger.Info("User renamed",
+			zap.String("user_id", userID),
+			zap.String("old_username", oldUsername),
+			zap.String("new_username", user.Username),
+		)
+	}
+
+	return &models.UserDTO{
+		ID:        user.ID,
+		Username:  user.Username,
+		Rating:    user.Rating,
+		Metadata:  user.Metadata,
+		RanksAsOf: &asOf,
+	}, rank, nil
+}
+
+// invalidateProfile drops what a profile change makes stale: the cached user
+// and, after a rename, the cached pages showing the old username, and puts
+// the new username in the search index. Ranks are unaffected; they depend on
+// ratings alone.
+func (s *UserService) invalidateProfile(ctx context.Context, user *models.User, renamed bool) {
+	if renamed {
+		s.search.index(ctx).put(user)
+	}
+
+	invalidate := func(ctx context.Context) {
+		if err := s.cache.InvalidateUser(ctx, user.ID); err != nil {
+			s.logger.Warn("Failed to invalidate user cache", zap.Error(err))
+		}
+		if !renamed {
+			return
+		}
+		if err := s.cache.InvalidateLeaderboardRange(ctx, GlobalBoard, user.Rating, user.Rating); err != nil {
+			s.logger.Warn("Failed to invalidate leaderboard cache", zap.Error(err))
+		}
+	}
+	invalidate(ctx)
+	s.afterReplicaLag(ctx, invalidate)
+}
+
+// mergeMetadata applies patch to current and checks the result fits the
+// metadata limits. current is not changed.
+func mergeMetadata(current, patch models.UserMetadata) (models.UserMetadata, error) {
+	merged := current.Clone()
+	if merged == nil {
+		merged = models.UserMetadata{}
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+
+	if len(merged) > MaxMetadataKeys {
+		return nil, fmt.Errorf("%w: metadata must not have more than %d keys", ErrInvalidUserUpdate, MaxMetadataKeys)
+	}
+	for k := range merged {
+		if k == "" || len(k) > MaxMetadataKeyLength {
+			return nil, fmt.Errorf("%w: metadata keys must be 1 to %d characters", ErrInvalidUserUpdate, MaxMetadataKeyLength)
+		}
+	}
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrInvalidUserUpdate, err)
+	}
+	if len(b) > MaxMetadataBytes {
+		return nil, fmt.Errorf("%w: metadata must not exceed %d bytes as JSON", ErrInvalidUserUpdate, MaxMetadataBytes)
+	}
+	return merged, nil
+}
+
+// ListUsers returns one page of active users, filtered and sorted as opts
+// asks.
+func (s *UserService) ListUsers(ctx context.Context, opts UserListOptions) (*models.UserListResponse, error) {
+	sort := repository.UserSort(opts.Sort)
+	switch sort {
+	case "":
+		sort = repository.SortByCreatedAt
+	case repository.SortByCreatedAt, repository.SortByRating, repository.SortByUsername:
+	default:
+		return nil, fmt.Errorf("%w: unknown sort %q (created_at, rating or username)", ErrInvalidUserQuery, opts.Sort)
+	}
+
+	desc := sort != repository.SortByUsername
+	switch opts.Order {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		return nil, fmt.Errorf("%w: order must be asc or desc", ErrInvalidUserQuery)
+	}
+
+	if opts.MinRating != 0 && opts.MaxRating != 0 && opts.MinRating > opts.MaxRating {
+		return nil, fmt.Errorf("%w: min_rating must not exceed max_rating", ErrInvalidUserQuery)
+	}
+	if !opts.CreatedAfter.IsZero() && !opts.CreatedBefore.IsZero() && !opts.CreatedAfter.Before(opts.CreatedBefore) {
+		return nil, fmt.Errorf("%w: created_after must be before created_before", ErrInvalidUserQuery)
+	}
+	if len(opts.Username) > MaxUsername {
+		return nil, fmt.Errorf("%w: username must not exceed %d characters", ErrInvalidUserQuery, MaxUsername)
+	}
+
+	page, pageSize := opts.Page, opts.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > MaxUserListPageSize {
+		pageSize = DefaultUserListPageSize
+	}
+
+	users, total, err := s.repo.ListUsers(ctx, repository.UserListQuery{
+		UsernamePrefix: opts.Username,
+		MinRating:      opts.MinRating,
+		MaxRating:      opts.MaxRating,
+		CreatedAfter:   opts.CreatedAfter,
+		CreatedBefore:  opts.CreatedBefore,
+		Sort:           sort,
+		Desc:           desc,
+		Offset:         (page - 1) * pageSize,
+		Limit:          pageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows carry columns clients must not see, tenant_id among them, so the
+	// page is built from DTOs, ranked the way search ranks its hits.
+	asOf := s.RanksAsOf(ctx)
+	matches := make([]repository.UsernameMatch, len(users))
+	for i, u := range users {
+		matches[i] = repository.UsernameMatch{ID: u.ID, Username: u.Username, Rating: u.Rating}
+	}
+	ranks, err := s.rankMatches(ctx, matches)
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]models.UserDTO, len(users))
+	for i, u := range users {
+		dtos[i] = models.UserDTO{
+			ID:        u.ID,
+			Username:  u.Username,
+			Rating:    u.Rating,
+			Rank:      ranks[u.ID],
+			Metadata:  u.Metadata,
+			RanksAsOf: &asOf,
+		}
+	}
+
+	order := "asc"
+	if desc {
+		order = "desc"
+	}
+	return &models.UserListResponse{
+		Users:    dtos,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+		HasMore:  int64(page*pageSize) < total,
+		Sort:     string(sort),
+		Order:    order,
+	}, nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"leaderboard-system/models"
+)
+
+// GET /users must serve DTOs: ranked, and without the row's internal columns.
+func TestListUsersReturnsRankedDTOs(t *testing.T) {
+	ctx := context.Background()
+	s := newTestUserService()
+	for _, u := range []struct {
+		id, name string
+		rating   int32
+	}{{"u1", "alice", 1500}, {"u2", "bob", 2000}, {"u3", "carol", 1500}} {
+		if _, err := s.CreateUser(ctx, u.id, u.name, u.rating); err != nil {
+			t.Fatalf("CreateUser(%s): %v", u.name, err)
+		}
+	}
+
+	resp, err := s.ListUsers(ctx, UserListOptions{Sort: "username"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"alice": 2, "bob": 1, "carol": 2}
+	if len(resp.Users) != len(want) {
+		t.Fatalf("listed %d users, want %d", len(resp.Users), len(want))
+	}
+	for _, u := range resp.Users {
+		if u.Rank != want[u.Username] {
+			t.Errorf("rank of %s = %d, want %d", u.Username, u.Rank, want[u.Username])
+		}
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range []string{"tenant_id", "updated_at", "created_at", "deleted_at"} {
+		if strings.Contains(string(body), `"`+column+`"`) {
+			t.Errorf("response exposes %s: %s", column, body)
+		}
+	}
+}
+
+// A rename must not take a username another user holds, active or not, but
+// may change the case of the user's own.
+func TestUpdateUserRenameConflicts(t *testing.T) {
+	cases := []struct {
+		name    string
+		to      string
+		wantErr error
+		want    string
+	}{
+		{name: "free name", to: "alicia", want: "alicia"},
+		{name: "own name in another case", to: "Alice", want: "Alice"},
+		{name: "active user's name", to: "bob", wantErr: ErrUserExists, want: "alice"},
+		{name: "active user's name in another case", to: "BOB", wantErr: ErrUserExists, want: "alice"},
+		{name: "deactivated user's name", to: "carol", wantErr: ErrUserExists, want: "alice"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestUserService()
+			for _, u := range []models.User{{ID: "u1", Username: "alice"}, {ID: "u2", Username: "bob"}, {ID: "u3", Username: "carol"}} {
+				if _, err := s.CreateUser(ctx, u.ID, u.Username, 1000); err != nil {
+					t.Fatalf("CreateUser(%s): %v", u.Username, err)
+				}
+			}
+			if _, err := s.repo.DeactivateUser(ctx, "u3"); err != nil {
+				t.Fatal(err)
+			}
+
+			to := tc.to
+			_, _, err := s.UpdateUser(ctx, "u1", UserPatch{Username: &to})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("UpdateUser = %v, want %v", err, tc.wantErr)
+			}
+			user, err := s.repo.GetUserByID(ctx, "u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Username != tc.want {
+				t.Fatalf("username = %q, want %q", user.Username, tc.want)
+			}
+		})
+	}
+}
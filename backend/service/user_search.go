@@ -0,0 +1,474 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go.uber.org/zap"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+const (
+	DefaultSearchPageSize = 20
+	MaxSearchPageSize     = 100
+
+	// FuzzyThreshold is the least similarity a fuzzy match needs, the same
+	// as pg_trgm's default.
+	FuzzyThreshold = 0.3
+
+	// SearchIndexMaxAge is how long the in-memory index is used before it
+	// is reloaded, which bounds how long users created on other instances
+	// stay unfindable.
+	SearchIndexMaxAge = time.Minute
+	// searchIndexBatch is how many users a reload reads at a time.
+	searchIndexBatch = 1000
+
+	highlightOpen  = "<mark>"
+	highlightClose = "</mark>"
+)
+
+var ErrInvalidSearch = NewValidationError("INVALID_SEARCH", "invalid search")
+
+// userSearch searches usernames in the store when it can, with pg_trgm, and
+// otherwise in an in-memory index of every active username, one per tenant.
+type userSearch struct {
+	users *UserService
+
+	mu      sync.Mutex
+	checked bool
+	inStore bool
+	indexes map[string]*usernameIndex
+}
+
+
+func newUserSearch(users *UserService) *userSearch {
+	return &userSearch{users: users, indexes: make(map[string]*usernameIndex)}
+}
+
+// index returns the in-memory index of the tenant ctx acts for.
+func (us *userSearch) index(ctx context.Context) *usernameIndex {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	id := tenant.ID(ctx)
+	ix, ok := us.indexes[id]
+	if !ok {
+		ix = newUsernameIndex()
+		us.indexes[id] = ix
+	}
+	return ix
+}
+
+// useStore reports whether the store can search. The answer is only cached
+// once the check has succeeded.
+func (us *userSearch) useStore(ctx context.Context) (repository.UsernameSearcher, bool) {
+	searcher, ok := us.users.repo.(repository.UsernameSearcher)
+	if !ok {
+		return nil, false
+	}
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if !us.checked {
+		available, err := searcher.UsernameSearchAvailable(ctx)
+		if err != nil {
+			us.users.logger.Warn("Failed to check for store username search", zap.Error(err))
+			return nil, false
+		}
+		us.checked, us.inStore = true, available
+		if !available {
+			us.users.logger.Warn("pg_trgm is not installed, searching usernames in memory")
+		}
+	}
+	return searcher, us.inStore
+}
+
+
+func (us *userSearch) search(ctx context.Context, search repository.UsernameSearch) ([]repository.UsernameMatch, int64, error) {
+	if searcher, ok := us.useStore(ctx); ok {
+		return searcher.SearchUsernames(ctx, search)
+	}
+	ix := us.index(ctx)
+	if err := ix.ensureFresh(ctx, us.users.repo, us.users.logger); err != nil {
+		return nil, 0, err
+	}
+	page, total := ix.search(search)
+	return page, int64(total), nil
+}
+
+// SearchUsers returns one page of active users whose username matches query,
+// most similar first, each with its rank and the match highlighted.
+func (s *UserService) SearchUsers(ctx context.Context, query string, mode repository.SearchMode, page, pageSize int) (*models.UserSearchResponse, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("%w: q is required", ErrInvalidSearch)
+	}
+	if len(query) > MaxUsername {
+		return nil, fmt.Errorf("%w: q must not exceed %d characters", ErrInvalidSearch, MaxUsername)
+	}
+	switch mode {
+	case "":
+		mode = repository.SearchFuzzy
+	case repository.SearchPrefix, repository.SearchSubstring, repository.SearchFuzzy:
+	default:
+		return nil, fmt.Errorf("%w: unknown mode %q (prefix, substring or fuzzy)", ErrInvalidSearch, mode)
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > MaxSearchPageSize {
+		pageSize = DefaultSearchPageSize
+	}
+
+	matches, total, err := s.search.search(ctx, repository.UsernameSearch{
+		Query:  query,
+		Mode:   mode,
+		Offset: (page - 1) * pageSize,
+		Limit:  pageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	asOf := s.RanksAsOf(ctx)
+	ranks, err := s.rankMatches(ctx, matches)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]models.UserSearchHit, 0, len(matches))
+	for _, m := range matches {
+		hits = append(hits, models.UserSearchHit{
+			ID:        m.ID,
+			Username:  m.Username,
+			Rating:    m.Rating,
+			Rank:      ranks[m.ID],
+			Score:     m.Score,
+			Highlight: highlightMatch(m.Username, query, mode),
+		})
+	}
+
+	return &models.UserSearchResponse{
+		Query:     query,
+		Mode:      string(mode),
+		Hits:      hits,
+		Total:     total,
+		Page:      page,
+		PageSize:  pageSize,
+		HasMore:   int64(page*pageSize) < total,
+		RanksAsOf: asOf,
+	}, nil
+}
+
+// rankMatches ranks a page of matches, or of listed users, with a few
+// queries rather than one lookup per user: the rank table's ranks in one
+// query, and for users it does not rank yet one count per distinct rating,
+// since ties share a rank.
+func (s *UserService) rankMatches(ctx context.Context, matches []repository.UsernameMatch) (map[string]int64, error) {
+	ranks := make(map[string]int64, len(matches))
+	if len(matches) == 0 {
+		return ranks, nil
+	}
+
+	if s.ranks != nil && !repository.ReadsFromPrimary(ctx) {
+		ids := make([]string, len(matches))
+		for i, m := range matches {
+			ids[i] = m.ID
+		}
+		materialized, err := s.ranks.GetUserRanks(ctx, ids)
+		if err != nil {
+			s.logger.Warn("Failed to read materialized ranks", zap.Error(err))
+		} else {
+			ranks = materialized
+		}
+	}
+
+	byRating := make(map[int32]int64)
+	for _, m := range matches {
+		if ranks[m.ID] > 0 {
+			continue
+		}
+		rank, ok := byRating[m.Rating]
+		if !ok {
+			above, err := s.repo.CountUsersAbove(ctx, m.Rating)
+			if err != nil {
+				return nil, err
+			}
+			rank = above + 1
+			byRating[m.Rating] = rank
+		}
+		ranks[m.ID] = rank
+	}
+	return ranks, nil
+}
+
+// usernameIndex holds every active user's username and trigrams. Searching
+// it is a scan, which is fine up to a few hundred thousand users; larger
+// boards should install pg_trgm.
+type usernameIndex struct {
+	mu      sync.RWMutex
+	entries map[string]*indexedUser
+	builtAt time.Time
+
+	// loading serializes reloads.
+	loading sync.Mutex
+}
+
+
+type indexedUser struct {
+	id       string
+	username string
+	lower    string
+	rating   int32
+	grams    []string
+}
+
+
+func newUsernameIndex() *usernameIndex {
+	return &usernameIndex{}
+}
+
+
+func newIndexedUser(user *models.User) *indexedUser {
+	lower := strings.ToLower(user.Username)
+	return &indexedUser{
+		id:       user.ID,
+		username: user.Username,
+		lower:    lower,
+		rating:   user.Rating,
+		grams:    trigrams(lower),
+	}
+}
+
+// ensureFresh loads the index on first use and reloads it in the background
+// once it is older than SearchIndexMaxAge.
+func (ix *usernameIndex) ensureFresh(ctx context.Context, store repository.UserStore, logger *zap.Logger) error {
+	ix.mu.RLock()
+	built, builtAt := ix.entries != nil, ix.builtAt
+	ix.mu.RUnlock()
+
+	if !built {
+		return ix.load(ctx, store)
+	}
+	if time.Since(builtAt) > SearchIndexMaxAge && ix.loading.TryLock() {
+		ix.loading.Unlock()
+		go func() {
+			if err := ix.load(tenant.Detach(ctx), store); err != nil {
+				logger.Warn("Failed to reload username index", zap.Error(err))
+			}
+		}()
+	}
+	return nil
+}
+
+
+func (ix *usernameIndex) load(ctx context.Context, store repository.UserStore) error {
+	ix.loading.Lock()
+	defer ix.loading.Unlock()
+
+	// Another caller may have loaded it while this one waited.
+	ix.mu.RLock()
+	fresh := ix.entries != nil && time.Since(ix.builtAt) <= SearchIndexMaxAge
+	ix.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	started := time.Now()
+	entries := make(map[string]*indexedUser)
+	var cursor *repository.LeaderboardCursor
+	for {
+		users, err := store.GetLeaderboardAfter(ctx, cursor, searchIndexBatch)
+		if err != nil {
+			return err
+		}
+		for i := range users {
+			entries[users[i].ID] = newIndexedUser(&users[i])
+		}
+		if len(users) < searchIndexBatch {
+			break
+		}
+		last := users[len(users)-1]
+		cursor = &repository.LeaderboardCursor{Rating: last.Rating, Username: last.Username}
+	}
+
+	ix.mu.Lock()
+	ix.entries = entries
+	ix.builtAt = started
+	ix.mu.Unlock()
+	return nil
+}
+
+// put and remove keep a loaded index current with writes on this instance.
+// Before the first load there is nothing to update.
+func (ix *usernameIndex) put(user *models.User) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.entries != nil {
+		ix.entries[user.ID] = newIndexedUser(user)
+	}
+}
+
+
+func (ix *usernameIndex) remove(userID string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.entries != nil {
+		delete(ix.entries, userID)
+	}
+}
+
+// reset forces the next search to reload, after a bulk change.
+func (ix *usernameIndex) reset() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.entries = nil
+}
+
+
+func (ix *usernameIndex) matches(search repository.UsernameSearch) []repository.UsernameMatch {
+	query := strings.ToLower(search.Query)
+	queryGrams := trigrams(query)
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	var matches []repository.UsernameMatch
+	for _, u := range ix.entries {
+		var ok bool
+		switch search.Mode {
+		case repository.SearchPrefix:
+			ok = strings.HasPrefix(u.lower, query)
+		case repository.SearchSubstring:
+			ok = strings.Contains(u.lower, query)
+		}
+		score := similarity(u.grams, queryGrams)
+		if search.Mode == repository.SearchFuzzy {
+			ok = score >= FuzzyThreshold
+		}
+		if ok {
+			matches = append(matches, repository.UsernameMatch{ID: u.id, Username: u.username, Rating: u.rating, Score: score})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Username < matches[j].Username
+	})
+	return matches
+}
+
+
+// search returns one page of matches and the number of matches.
+func (ix *usernameIndex) search(search repository.UsernameSearch) ([]repository.UsernameMatch, int) {
+	matches := ix.matches(search)
+	start, end := search.Offset, search.Offset+search.Limit
+	if start > len(matches) {
+		start = len(matches)
+	}
+	if end > len(matches) {
+		end = len(matches)
+	}
+	return matches[start:end], len(matches)
+}
+
+// trigrams returns the sorted, distinct trigrams of s the way pg_trgm
+// extracts them: each run of letters and digits is padded with two spaces in
+// front and one behind, and everything else separates words.
+func trigrams(s string) []string {
+	seen := make(map[string]bool)
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			seen[string(padded[i:i+3])] = true
+		}
+	}
+
+	grams := make([]string, 0, len(seen))
+	for g := range seen {
+		grams = append(grams, g)
+	}
+	sort.Strings(grams)
+	return grams
+}
+
+// similarity is pg_trgm's similarity of two sorted trigram sets: shared
+// trigrams over distinct trigrams.
+func similarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			shared++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// highlightMatch wraps the part of username that matched query: the prefix,
+// the first occurrence, or for fuzzy matches the longest run both share.
+func highlightMatch(username, query string, mode repository.SearchMode) string {
+	name := []rune(username)
+	lowerName := []rune(strings.ToLower(username))
+	lowerQuery := []rune(strings.ToLower(query))
+	// Lowercasing changed the length; offsets would not line up.
+	if len(name) != len(lowerName) {
+		return username
+	}
+
+	start, n := 0, 0
+	switch mode {
+	case repository.SearchPrefix, repository.SearchSubstring:
+		if i := strings.Index(string(lowerName), string(lowerQuery)); i >= 0 {
+			start, n = len([]rune(string(lowerName)[:i])), len(lowerQuery)
+		}
+	default:
+		start, n = longestCommonRun(lowerName, lowerQuery)
+	}
+	if n == 0 {
+		return username
+	}
+	return string(name[:start]) + highlightOpen + string(name[start:start+n]) + highlightClose + string(name[start+n:])
+}
+
+// longestCommonRun returns where in a the longest substring shared with b
+// starts, and its length.
+func longestCommonRun(a, b []rune) (int, int) {
+	best, bestEnd := 0, 0
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				cur[j] = prev[j-1] + 1
+				if cur[j] > best {
+					best, bestEnd = cur[j], i
+				}
+			} else {
+				cur[j] = 0
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return bestEnd - best, best
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+	"leaderboard-system/cache"
+	"leaderboard-system/repository"
+)
+
+// The board is johnny 2000, jane 1800, john 1500, johnson 1000 and jon 900,
+// searched through the in-memory index.
+func TestSearchUsersRanking(t *testing.T) {
+	cases := []struct {
+		name           string
+		query          string
+		mode           repository.SearchMode
+		page, pageSize int
+		want           []string
+		ranks          []int64
+		total          int64
+		highlight      string
+	}{
+		{
+			name: "fuzzy, most similar first", query: "john", mode: repository.SearchFuzzy,
+			want: []string{"john", "johnny", "johnson"}, ranks: []int64{3, 1, 4}, total: 3,
+			highlight: "<mark>john</mark>",
+		},
+		{
+			name: "fuzzy, second page", query: "john", mode: repository.SearchFuzzy, page: 2, pageSize: 2,
+			want: []string{"johnson"}, ranks: []int64{4}, total: 3,
+		},
+		{
+			name: "substring", query: "OHN", mode: repository.SearchSubstring,
+			want: []string{"john", "johnny", "johnson"}, ranks: []int64{3, 1, 4}, total: 3,
+			highlight: "j<mark>ohn</mark>",
+		},
+		{
+			name: "prefix", query: "johns", mode: repository.SearchPrefix,
+			want: []string{"johnson"}, ranks: []int64{4}, total: 1,
+			highlight: "<mark>johns</mark>on",
+		},
+		{
+			name: "no match", query: "zed", mode: repository.SearchFuzzy,
+		},
+	}
+
+	ctx := context.Background()
+	s := newTestUserService()
+	for name, rating := range map[string]int32{"johnny": 2000, "jane": 1800, "john": 1500, "johnson": 1000, "jon": 900} {
+		if _, err := s.CreateUser(ctx, "u-"+name, name, rating); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := s.SearchUsers(ctx, tc.query, tc.mode, tc.page, tc.pageSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			var ranks []int64
+			for _, hit := range resp.Hits {
+				names = append(names, hit.Username)
+				ranks = append(ranks, hit.Rank)
+			}
+			if !reflect.DeepEqual(names, tc.want) || !reflect.DeepEqual(ranks, tc.ranks) {
+				t.Fatalf("hits = %v ranked %v, want %v ranked %v", names, ranks, tc.want, tc.ranks)
+			}
+			if resp.Total != tc.total {
+				t.Fatalf("total = %d, want %d", resp.Total, tc.total)
+			}
+			if tc.highlight != "" && resp.Hits[0].Highlight != tc.highlight {
+				t.Fatalf("highlight = %q, want %q", resp.Hits[0].Highlight, tc.highlight)
+			}
+		})
+	}
+}
+
+// searchingStore is a store that can search usernames itself, when
+// available says so.
+type searchingStore struct {
+	*repository.MemoryUserStore
+	available func() (bool, error)
+	searches  int
+}
+
+func (s *searchingStore) UsernameSearchAvailable(ctx context.Context) (bool, error) {
+	return s.available()
+}
+
+func (s *searchingStore) SearchUsernames(ctx context.Context, search repository.UsernameSearch) ([]repository.UsernameMatch, int64, error) {
+	s.searches++
+	return []repository.UsernameMatch{{ID: "u-john", Username: "john", Rating: 1500, Score: 1}}, 1, nil
+}
+
+// Search runs in the store when it can, and in memory when the store has no
+// pg_trgm or cannot say, asking again after a failed check.
+func TestSearchUsersFallback(t *testing.T) {
+	cases := []struct {
+		name     string
+		answers  []error // nil answers available, errNo unavailable
+		inStore  []bool
+		searches int
+	}{
+		{name: "store can search", answers: []error{nil, nil}, inStore: []bool{true, true}, searches: 2},
+		{name: "store lacks pg_trgm", answers: []error{errNo}, inStore: []bool{false, false}},
+		{name: "failed check is retried", answers: []error{errors.New("down"), nil}, inStore: []bool{false, true}, searches: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			checks := 0
+			store := &searchingStore{MemoryUserStore: repository.NewMemoryUserStore()}
+			store.available = func() (bool, error) {
+				err := tc.answers[checks]
+				checks++
+				if err == errNo {
+					return false, nil
+				}
+				return err == nil, err
+			}
+			changeLog := NewChangeLogService(repository.NewMemoryChangeLog(), store, zap.NewNop())
+			s := NewUserService(store, changeLog, cache.NewMemoryCache(1000), zap.NewNop())
+			for name, rating := range map[string]int32{"john": 1500, "johnny": 2000} {
+				if _, err := s.CreateUser(ctx, "u-"+name, name, rating); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for i, inStore := range tc.inStore {
+				resp, err := s.SearchUsers(ctx, "john", repository.SearchFuzzy, 1, 10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// The store's double only ever finds john.
+				if got := resp.Total == 1; got != inStore {
+					t.Fatalf("search %d ran in the store = %v, want %v", i+1, got, inStore)
+				}
+			}
+			if store.searches != tc.searches {
+				t.Fatalf("store searches = %d, want %d", store.searches, tc.searches)
+			}
+		})
+	}
+}
+
+// errNo stands for the store answering that it cannot search.
+var errNo = errors.New("unavailable")
@@ -5,69 +5,162 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 
 	"go.uber.org/zap"
 	"leaderboard-system/cache"
 	"leaderboard-system/models"
 	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
 )
 
+
+const (
+	GlobalBoard = "global"
+
+	DefaultLeaderboardPageSize = 100
+)
+
+var ErrUserExists = NewError(ErrConflict, "USER_EXISTS", "user already exists")
+
+
+type RatingChangeListener interface {
+	OnRatingChange(ctx context.Context, change models.RatingChange)
+}
+
  
 type UserService struct {
-	repo     *repository.UserRepository
-	cache    *cache.CacheManager
-	logger   *zap.Logger
-	mu       sync.RWMutex 
-	rankMu   map[string]*sync.Mutex 
+	repo      repository.UserStore
+	changeLog *ChangeLogService
+	cache     cache.Cache
+	logger    *zap.Logger
+	loader    *loader
+	mu        sync.RWMutex 
+	listeners []RatingChangeListener
+
+	search *userSearch
+
+	// ranks, when set, serves boards and ranks from the materialized table.
+	ranks *repository.RankRepository
+	asOf  ranksAsOf
+
+	// replicaLag is how stale a replica read may be. Caches are invalidated
+	// again this long after a write, so an entry refilled from a replica that
+	// had not seen the write yet does not outlive the lag.
+	replicaLag time.Duration
+}
+
+
+func NewUserService(repo repository.UserStore, changeLog *ChangeLogService, cache cache.Cache, logger *zap.Logger) *UserService {
+	s := &UserService{
+		repo:      repo,
+		changeLog: changeLog,
+		cache:     cache,
+		logger:    logger,
+		loader:    newLoader(cache, logger),
+	}
+	s.search = newUserSearch(s)
+	return s
 }
 
 
-func NewUserService(repo *repository.UserRepository, cache *cache.CacheManager, logger *zap.Logger) *UserService {
-	return &UserService{
-		repo:   repo,
-		cache:  cache,
-		logger: logger,
-		rankMu: make(map[string]*sync.Mutex),
+func (s *UserService) SetReplicaLag(lag time.Duration) {
+	s.replicaLag = lag
+}
+
+
+// afterReplicaLag runs fn for the tenant ctx acts for once replicas have
+// caught up with writes made so far.
+func (s *UserService) afterReplicaLag(ctx context.Context, fn func(ctx context.Context)) {
+	if s.replicaLag <= 0 {
+		return
 	}
+	ctx = tenant.Detach(ctx)
+	time.AfterFunc(s.replicaLag, func() {
+		fn(ctx)
+	})
 }
 
 
-func (s *UserService) getRankMutex(userID string) *sync.Mutex {
+func (s *UserService) AddRatingListener(l RatingChangeListener) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if mu, exists := s.rankMu[userID]; exists {
-		return mu
+	s.listeners = append(s.listeners, l)
+}
+
+
+func (s *UserService) notifyRatingChange(ctx context.Context, change models.RatingChange) {
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+
+	for _, l := range listeners {
+		l.OnRatingChange(ctx, change)
 	}
+}
+
 
-	mu := &sync.Mutex{}
-	s.rankMu[userID] = mu
-	return mu
+func (s *UserService) loadUser(ctx context.Context, userID string) (*models.User, error) {
+	v, err := s.loader.load(ctx, loadSpec{
+		key: userLoadKey(userID),
+		lookup: func(ctx context.Context) (interface{}, bool, cache.Freshness) {
+			user, err := s.cache.GetUser(ctx, userID)
+			if err != nil {
+				s.logger.Warn("Cache error", zap.Error(err))
+			}
+			return user, user != nil, cache.Freshness{}
+		},
+		compute: func(ctx context.Context) (interface{}, error) {
+			return s.repo.GetUserByID(ctx, userID)
+		},
+		store: func(ctx context.Context, v interface{}, _ time.Duration) {
+			user := v.(*models.User)
+			if user == nil {
+				return
+			}
+			if err := s.cache.SetUser(ctx, user); err != nil {
+				s.logger.Warn("Failed to cache user", zap.Error(err))
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*models.User), nil
+}
+
+
+func userLoadKey(userID string) string {
+	return "user:" + userID
+}
+
+
+// rankLoadKey includes the epoch so a load started before a rating change is
+// never joined by callers that arrive after it.
+func rankLoadKey(epoch int64, userID string) string {
+	return fmt.Sprintf("rank:%d:%s", epoch, userID)
+}
+
+
+func leaderboardLoadKey(board string, page, pageSize int) string {
+	return fmt.Sprintf("leaderboard:%s:%d:%d", board, pageSize, page)
 }
 
 
 func (s *UserService) CreateUser(ctx context.Context, userID, username string, initialRating int32) (*models.User, error) {
+	// The uniqueness check must not miss a user created a moment ago.
+	ctx = repository.WithPrimaryReads(ctx)
 
 	if err := ValidateUsername(username); err != nil {
 		s.logger.Warn("Invalid username", zap.String("username", username), zap.Error(err))
-		return nil, fmt.Errorf("invalid username: %w", err)
+		return nil, invalidField("username", err)
 	}
 
 	if err := ValidateRating(initialRating); err != nil {
 		s.logger.Warn("Invalid rating", zap.Int32("rating", initialRating), zap.Error(err))
-		return nil, fmt.Errorf("invalid rating: %w", err)
-	}
-
-
-	existingUser, err := s.repo.GetUserByUsername(ctx, username)
-	if err != nil {
-		s.logger.Error("Failed to check user existence", zap.Error(err))
-		return nil, err
-	}
-
-	if existingUser != nil {
-		return nil, errors.New("user already exists")
+		return nil, invalidField("rating", err)
 	}
 
 
@@ -77,15 +170,59 @@ func (s *UserService) CreateUser(ctx context.Context, userID, username string, i
 		Rating:   initialRating,
 	}
 
-	if err := s.repo.CreateUser(ctx, user); err != nil {
-		s.logger.Error("Failed to create user", zap.Error(err))
-		return nil, err
-	}
+	// The username stays locked from the check to the insert, so two creates
+	// of one username cannot both find it free.
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.LockUsername(ctx, username); err != nil {
+			return err
+		}
 
+		// Deactivated users keep their username until they are erased.
+		existingUser, err := s.repo.GetUserByUsernameUnscoped(ctx, username)
+		if err != nil {
+			return err
+		}
+		if existingUser != nil {
+			return ErrUserExists
+		}
 
-	if err := s.cache.SetUser(ctx, user); err != nil {
-		s.logger.Warn("Failed to cache user", zap.Error(err))
-		
+		if err := s.repo.CreateUser(ctx, user); err != nil {
+			// Only the user ID can still clash here.
+			if errors.Is(err, repository.ErrDuplicateUser) {
+				return ErrUserExists
+			}
+			return err
+		}
+		if err := s.changeLog.Record(ctx, user.ID, 0, user.Rating); err != nil {
+			return err
+		}
+
+		repository.AfterCommit(ctx, func(ctx context.Context) {
+			s.bumpRankEpoch(ctx)
+
+			// A new row shifts every page below it and changes the total.
+			if err := s.cache.InvalidateLeaderboard(ctx, GlobalBoard); err != nil {
+				s.logger.Warn("Failed to invalidate leaderboard cache", zap.Error(err))
+			}
+			s.afterReplicaLag(ctx, func(ctx context.Context) {
+				s.bumpRankEpoch(ctx)
+				if err := s.cache.InvalidateLeaderboard(ctx, GlobalBoard); err != nil {
+					s.logger.Warn("Failed to invalidate leaderboard cache", zap.Error(err))
+				}
+			})
+
+			if err := s.cache.SetUser(ctx, user); err != nil {
+				s.logger.Warn("Failed to cache user", zap.Error(err))
+			}
+			s.search.index(ctx).put(user)
+		})
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, ErrUserExists) {
+			s.logger.Error("Failed to create user", zap.Error(err))
+		}
+		return nil, err
 	}
 
 	s.logger.Info("User created", zap.String("user_id", userID), zap.String("username", username))
@@ -95,31 +232,15 @@ func (s *UserService) CreateUser(ctx context.Context, userID, username string, i
 
 func (s *UserService) GetUserByID(ctx context.Context, userID string) (*models.UserDTO, int64, error) {
 
-	user, err := s.cache.GetUser(ctx, userID)
+	user, err := s.loadUser(ctx, userID)
 	if err != nil {
-		s.logger.Warn("Cache error", zap.Error(err))
+		return nil, 0, err
 	}
-
-
 	if user == nil {
-		dbUser, err := s.repo.GetUserByID(ctx, userID)
-		if err != nil {
-			return nil, 0, err
-		}
-		if dbUser == nil {
-			return nil, 0, errors.New("user not found")
-		}
-		user = dbUser
-
-		
-		go func() {
-			if err := s.cache.SetUser(context.Background(), user); err != nil {
-				s.logger.Warn("Failed to cache user", zap.Error(err))
-			}
-		}()
+		return nil, 0, ErrUserNotFound
 	}
 
-	
+	asOf := s.RanksAsOf(ctx)
 	rank, err := s.GetUserRank(ctx, userID)
 	if err != nil {
 		s.logger.Error("Failed to calculate rank", zap.Error(err))
@@ -127,91 +248,126 @@ func (s *UserService) GetUserByID(ctx context.Context, userID string) (*models.U
 	}
 
 	return &models.UserDTO{
-		ID:       user.ID,
-		Username: user.Username,
-		Rating:   user.Rating,
+		ID:        user.ID,
+		Username:  user.Username,
+		Rating:    user.Rating,
+		Metadata:  user.Metadata,
+		RanksAsOf: &asOf,
 	}, rank, nil
 }
 
 
 func (s *UserService) GetUserRank(ctx context.Context, userID string) (int64, error) {
-
-	cachedRank, err := s.cache.GetRank(ctx, userID)
+	epoch, err := s.cache.RankEpoch(ctx)
 	if err != nil {
-		s.logger.Warn("Cache error for rank", zap.Error(err))
-	}
-
-	if cachedRank > 0 {
-		return cachedRank, nil
-	}
-
-	 
-	rankMu := s.getRankMutex(userID)
-	rankMu.Lock()
-	defer rankMu.Unlock()
-
- 
-	cachedRank, _ = s.cache.GetRank(ctx, userID)
-	if cachedRank > 0 {
-		return cachedRank, nil
+		// Without the epoch we cannot tell a current entry from a stale one,
+		// so skip the cache entirely. An open breaker is reported by health
+		// and metrics instead of on every request.
+		if !errors.Is(err, cache.ErrCircuitOpen) {
+			s.logger.Warn("Cache error for rank epoch", zap.Error(err))
+		}
+		return s.calculateRank(ctx, userID)
 	}
 
- 
-	rank, err := s.repo.CalculateRank(ctx, userID)
+	v, err := s.loader.load(ctx, loadSpec{
+		key: rankLoadKey(epoch, userID),
+		lookup: func(ctx context.Context) (interface{}, bool, cache.Freshness) {
+			rank, fresh, err := s.cache.GetRank(ctx, epoch, userID)
+			if err != nil {
+				s.logger.Warn("Cache error for rank", zap.Error(err))
+			}
+			return rank, rank > 0, fresh
+		},
+		compute: func(ctx context.Context) (interface{}, error) {
+			return s.calculateRank(ctx, userID)
+		},
+		store: func(ctx context.Context, v interface{}, delta time.Duration) {
+			if err := s.cache.SetRank(ctx, epoch, userID, v.(int64), delta); err != nil {
+				s.logger.Warn("Failed to cache rank", zap.Error(err))
+			}
+		},
+	})
 	if err != nil {
 		return 0, err
 	}
+	return v.(int64), nil
+}
 
- 
-	go func() {
-		if err := s.cache.SetRank(context.Background(), userID, rank); err != nil {
-			s.logger.Warn("Failed to cache rank", zap.Error(err))
-		}
-	}()
 
-	return rank, nil
+func (s *UserService) bumpRankEpoch(ctx context.Context) {
+	if _, err := s.cache.BumpRankEpoch(ctx); err != nil && !errors.Is(err, cache.ErrCircuitOpen) {
+		s.logger.Warn("Failed to bump rank epoch", zap.Error(err))
+	}
 }
 
  
 func (s *UserService) UpdateUserRating(ctx context.Context, userID string, newRating int32) (*models.UserDTO, int64, error) {
+	// The rank returned below must reflect this write.
+	ctx = repository.WithPrimaryReads(ctx)
 	 
 	if err := ValidateRating(newRating); err != nil {
-		return nil, 0, fmt.Errorf("invalid rating: %w", err)
+		return nil, 0, invalidField("rating", err)
 	}
 
- 
-	user, err := s.repo.GetUserByID(ctx, userID)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	if user == nil {
-		return nil, 0, errors.New("user not found")
-	}
-
- 
-	if err := s.repo.UpdateUserRating(ctx, userID, newRating); err != nil {
-		return nil, 0, err
-	}
-
- 
-	user.Rating = newRating
+	var user *models.User
+	var oldRating int32
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		// The row stays locked until commit, so a concurrent update waits and
+		// then reads this rating as its old one; the change log records every
+		// step.
+		var err error
+		user, err = s.repo.LockUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return ErrUserNotFound
+		}
 
- 
-	go func() {
-		ctx := context.Background()
-		if err := s.cache.InvalidateUser(ctx, userID); err != nil {
-			s.logger.Warn("Failed to invalidate user cache", zap.Error(err))
+		if err := s.repo.UpdateUserRating(ctx, userID, newRating); err != nil {
+			return err
 		}
-		if err := s.cache.InvalidateRank(ctx, userID); err != nil {
-			s.logger.Warn("Failed to invalidate rank cache", zap.Error(err))
+		oldRating = user.Rating
+		user.Rating = newRating
+
+		if err := s.changeLog.Record(ctx, userID, oldRating, newRating); err != nil {
+			return err
 		}
-		if err := s.cache.InvalidateLeaderboard(ctx); err != nil {
-			s.logger.Warn("Failed to invalidate leaderboard cache", zap.Error(err))
+
+		change := models.RatingChange{
+			UserID:    user.ID,
+			Username:  user.Username,
+			OldRating: oldRating,
+			NewRating: newRating,
+			Timestamp: time.Now().UTC(),
 		}
-	}()
+		updated := *user
+		repository.AfterCommit(ctx, func(ctx context.Context) {
+			s.search.index(ctx).put(&updated)
+
+			// Everyone rated between the old and new value moved by one
+			// place, so every cached rank is retired before the new rank is
+			// read below.
+			s.bumpRankEpoch(ctx)
+
+			go func(ctx context.Context) {
+				// Listeners evaluate the board right after the write.
+				ctx = repository.WithPrimaryReads(ctx)
+				s.invalidateRatingChange(ctx, userID, oldRating, newRating)
+				s.notifyRatingChange(ctx, change)
+			}(tenant.Detach(ctx))
+			s.afterReplicaLag(ctx, func(ctx context.Context) {
+				s.bumpRankEpoch(ctx)
+				s.invalidateRatingChange(ctx, userID, oldRating, newRating)
+			})
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
 
-	 
+	asOf := s.RanksAsOf(ctx)
 	rank, err := s.GetUserRank(ctx, userID)
 	if err != nil {
 		s.logger.Error("Failed to calculate new rank", zap.Error(err))
@@ -220,22 +376,59 @@ func (s *UserService) UpdateUserRating(ctx context.Context, userID string, newRa
 
 	s.logger.Info("User rating updated",
 		zap.String("user_id", userID),
-		zap.Int32("old_rating", user.Rating),
+		zap.Int32("old_rating", oldRating),
 		zap.Int32("new_rating", newRating),
 	)
 
 	return &models.UserDTO{
-		ID:       user.ID,
-		Username: user.Username,
-		Rating:   newRating,
+		ID:        user.ID,
+		Username:  user.Username,
+		Rating:    newRating,
+		RanksAsOf: &asOf,
 	}, rank, nil
 }
 
+
+// invalidateMembership drops what a user joining or leaving the board makes
+// stale: the user, every cached rank and every page.
+func (s *UserService) invalidateMembership(ctx context.Context, userID string) {
+	invalidate := func(ctx context.Context) {
+		if err := s.cache.InvalidateUser(ctx, userID); err != nil {
+			s.logger.Warn("Failed to invalidate user cache", zap.Error(err))
+		}
+		s.invalidateBoard(ctx)
+	}
+	invalidate(ctx)
+	s.afterReplicaLag(ctx, invalidate)
+}
+
+// invalidateBoard retires every cached rank and page.
+func (s *UserService) invalidateBoard(ctx context.Context) {
+	s.bumpRankEpoch(ctx)
+	if err := s.cache.InvalidateLeaderboard(ctx, GlobalBoard); err != nil {
+		s.logger.Warn("Failed to invalidate leaderboard cache", zap.Error(err))
+	}
+}
+
+
+func (s *UserService) invalidateRatingChange(ctx context.Context, userID string, oldRating, newRating int32) {
+	if err := s.cache.InvalidateUser(ctx, userID); err != nil {
+		s.logger.Warn("Failed to invalidate user cache", zap.Error(err))
+	}
+	minRating, maxRating := oldRating, newRating
+	if minRating > maxRating {
+		minRating, maxRating = maxRating, minRating
+	}
+	if err := s.cache.InvalidateLeaderboardRange(ctx, GlobalBoard, minRating, maxRating); err != nil {
+		s.logger.Warn("Failed to invalidate leaderboard cache", zap.Error(err))
+	}
+}
+
  
 func (s *UserService) SearchUserByUsername(ctx context.Context, username string) (*models.UserDTO, int64, error) {
  
 	if err := ValidateUsername(username); err != nil {
-		return nil, 0, fmt.Errorf("invalid username: %w", err)
+		return nil, 0, invalidField("username", err)
 	}
 
 	 
@@ -248,7 +441,7 @@ func (s *UserService) SearchUserByUsername(ctx context.Context, username string)
 		return nil, 0, nil
 	}
 
- 
+	asOf := s.RanksAsOf(ctx)
 	rank, err := s.GetUserRank(ctx, user.ID)
 	if err != nil {
 		s.logger.Error("Failed to get rank", zap.Error(err))
@@ -261,9 +454,10 @@ func (s *UserService) SearchUserByUsername(ctx context.Context, username string)
 	)
 
 	return &models.UserDTO{
-		ID:       user.ID,
-		Username: user.Username,
-		Rating:   user.Rating,
+		ID:        user.ID,
+		Username:  user.Username,
+		Rating:    user.Rating,
+		RanksAsOf: &asOf,
 	}, rank, nil
 }
 
@@ -274,18 +468,89 @@ func (s *UserService) GetLeaderboard(ctx context.Context, page, pageSize int) (*
 		page = 1
 	}
 	if pageSize < 1 || pageSize > 1000 {
-		pageSize = 100 
+		pageSize = DefaultLeaderboardPageSize
 	}
 
+	v, err := s.loader.load(ctx, loadSpec{
+		key: leaderboardLoadKey(GlobalBoard, page, pageSize),
+		lookup: func(ctx context.Context) (interface{}, bool, cache.Freshness) {
+			cached, fresh, err := s.cache.GetLeaderboardPage(ctx, GlobalBoard, page, pageSize)
+			if err != nil {
+				s.logger.Warn("Cache error for leaderboard", zap.Error(err))
+			}
+			return cached, cached != nil, fresh
+		},
+		compute: func(ctx context.Context) (interface{}, error) {
+			return s.buildLeaderboardPage(ctx, page, pageSize)
+		},
+		store: func(ctx context.Context, v interface{}, delta time.Duration) {
+			if err := s.cache.SetLeaderboardPage(ctx, GlobalBoard, page, pageSize, v.(*models.LeaderboardResponse), delta); err != nil {
+				s.logger.Warn("Failed to cache leaderboard page", zap.Error(err))
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*models.LeaderboardResponse), nil
+}
+
+
+func (s *UserService) buildLeaderboardPage(ctx context.Context, page, pageSize int) (*models.LeaderboardResponse, error) {
 	offset := (page - 1) * pageSize
 
+	if s.ranks != nil {
+		entries, st, err := s.ranks.GetRankPage(ctx, offset, pageSize)
+		if err != nil {
+			s.logger.Warn("Failed to read materialized ranks", zap.Error(err))
+		} else if st.Ready() {
+			// The table's version is the last change it applied, so clients
+			// polling changes from it see exactly what the page misses.
+			return &models.LeaderboardResponse{
+				Entries:   entries,
+				Total:     st.Total,
+				Page:      page,
+				PageSize:  pageSize,
+				HasMore:   int64(offset+pageSize) < st.Total,
+				Version:   st.Version,
+				RanksAsOf: st.AsOf.UTC(),
+			}, nil
+		}
+	}
+
+	// Ranks computed live are current as of before the rows are read.
+	asOf := time.Now().UTC()
+
+	// The version is read before the page so a cached page can only carry an
+	// older version than its rows, and the change log will catch clients up.
+	// Both reads go to the same replica so its rows are at least that new.
+	ctx = repository.WithPinnedReads(ctx)
+	version, err := s.changeLog.CurrentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	
 	users, total, err := s.repo.GetLeaderboard(ctx, offset, pageSize)
 	if err != nil {
 		return nil, err
 	}
 
-	
+	s.logger.Info("Leaderboard fetched",
+		zap.Int("page", page),
+		zap.Int("page_size", pageSize),
+		zap.Int64("total", total),
+	)
+
+	return newLeaderboardPage(users, page, pageSize, total, version, asOf), nil
+}
+
+// newLeaderboardPage ranks one page of users already in leaderboard order.
+// The cache warmer builds pages with it too, so warmed and on-demand pages
+// are identical.
+func newLeaderboardPage(users []models.User, page, pageSize int, total, version int64, asOf time.Time) *models.LeaderboardResponse {
+	offset := (page - 1) * pageSize
+
 	entries := make([]models.LeaderboardEntry, 0, len(users))
 	var currentRank int64 = 1
 	var previousRating int32 = -1
@@ -306,24 +571,28 @@ func (s *UserService) GetLeaderboard(ctx context.Context, page, pageSize int) (*
 
 	hasMore := offset+int(int64(pageSize)) < int(total)
 
-	s.logger.Info("Leaderboard fetched",
-		zap.Int("page", page),
-		zap.Int("page_size", pageSize),
-		zap.Int64("total", total),
-	)
-
 	return &models.LeaderboardResponse{
-		Entries:  entries,
-		Total:    total,
-		Page:     page,
-		PageSize: pageSize,
-		HasMore:  hasMore,
-	}, nil
+		Entries:   entries,
+		Total:     total,
+		Page:      page,
+		PageSize:  pageSize,
+		HasMore:   hasMore,
+		Version:   version,
+		RanksAsOf: asOf,
+	}
 }
 
 
 func (s *UserService) GetLeaderboardAroundUser(ctx context.Context, userID string, contextSize int) (*models.LeaderboardResponse, error) {
-	
+	// The rank table can still rank a user deactivated since its last sync.
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
 	rank, err := s.GetUserRank(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -343,8 +612,31 @@ func (s *UserService) GetLeaderboardAroundUser(ctx context.Context, userID strin
 }
 
 
+func (s *UserService) CacheStats() (cache.Stats, bool) {
+	reporter, ok := s.cache.(cache.StatsReporter)
+	if !ok {
+		return cache.Stats{}, false
+	}
+	return reporter.Stats(), true
+}
+
+
+// CacheBreaker reports the circuit breaker in front of Redis, when the cache
+// has one.
+func (s *UserService) CacheBreaker() (cache.BreakerStats, bool) {
+	reporter, ok := s.cache.(cache.BreakerReporter)
+	if !ok {
+		return cache.BreakerStats{}, false
+	}
+	return reporter.BreakerStats()
+}
+
+
 func (s *UserService) IsHealthy(ctx context.Context) bool {
-	
-	_, err := s.cache.GetUser(ctx, "health-check")
-	return err == nil
+	return s.cache.Ping(ctx) == nil
+}
+
+
+func (s *UserService) GetLeaderboardChanges(ctx context.Context, since int64) (*models.LeaderboardChangesResponse, error) {
+	return s.changeLog.ChangesSince(ctx, since)
 }
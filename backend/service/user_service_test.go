@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"leaderboard-system/cache"
+	"leaderboard-system/models"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+func newTestUserService() *UserService {
+	store := repository.NewMemoryUserStore()
+	changeLog := NewChangeLogService(repository.NewMemoryChangeLog(), store, zap.NewNop())
+	return NewUserService(store, changeLog, cache.NewMemoryCache(1000), zap.NewNop())
+}
+
+// A cached rank must not survive another user's rating crossing it: carol
+// does not change, but the mover passing her moves her by one place.
+func TestUpdateUserRatingRefreshesDisplacedRanks(t *testing.T) {
+	cases := []struct {
+		name          string
+		from, to      int32
+		before, after int64
+	}{
+		{name: "mover drops below", from: 1500, to: 1000, before: 3, after: 2},
+		{name: "mover rises above", from: 1000, to: 1500, before: 2, after: 3},
+		{name: "mover stays below", from: 1000, to: 1100, before: 2, after: 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestUserService()
+			for _, u := range []struct {
+				id, name string
+				rating   int32
+			}{{"u-alice", "alice", 2000}, {"u-carol", "carol", 1200}, {"u-mover", "mover", tc.from}} {
+				if _, err := s.CreateUser(ctx, u.id, u.name, u.rating); err != nil {
+					t.Fatalf("CreateUser(%s): %v", u.id, err)
+				}
+			}
+
+			rank, err := s.GetUserRank(ctx, "u-carol")
+			if err != nil || rank != tc.before {
+				t.Fatalf("GetUserRank(u-carol) before = %d, %v, want %d", rank, err, tc.before)
+			}
+
+			if _, _, err := s.UpdateUserRating(ctx, "u-mover", tc.to); err != nil {
+				t.Fatalf("UpdateUserRating(u-mover, %d): %v", tc.to, err)
+			}
+
+			rank, err = s.GetUserRank(ctx, "u-carol")
+			if err != nil || rank != tc.after {
+				t.Fatalf("GetUserRank(u-carol) after = %d, %v, want %d", rank, err, tc.after)
+			}
+		})
+	}
+}
+
+// countingStore counts rank computations and holds each one until release
+// is closed.
+type countingStore struct {
+	repository.UserStore
+	release chan struct{}
+	ranks   int32
+}
+
+func (s *countingStore) CalculateRank(ctx context.Context, userID string) (int64, error) {
+	atomic.AddInt32(&s.ranks, 1)
+	<-s.release
+	return s.UserStore.CalculateRank(ctx, userID)
+}
+
+// A herd reading a rank due for an early refresh must all be served the
+// cached value at once while a single load refreshes it behind them.
+func TestGetUserRankHerdSharesOneEarlyRefresh(t *testing.T) {
+	ctx := context.Background()
+	store := &countingStore{UserStore: repository.NewMemoryUserStore(), release: make(chan struct{})}
+	if err := store.CreateUser(ctx, &models.User{ID: "u1", Username: "alice", Rating: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	c := cache.NewMemoryCache(1000)
+	changeLog := NewChangeLogService(repository.NewMemoryChangeLog(), store, zap.NewNop())
+	s := NewUserService(store, changeLog, c, zap.NewNop())
+
+	// A compute time far above the TTL puts the entry in the XFetch window
+	// while it is still fresh.
+	epoch, err := c.RankEpoch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetRank(ctx, epoch, "u1", 7, 100*cache.CacheRankTTL); err != nil {
+		t.Fatal(err)
+	}
+
+	const herd = 50
+	ranks := make([]int64, herd)
+	errs := make([]error, herd)
+	var wg sync.WaitGroup
+	for i := 0; i < herd; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ranks[i], errs[i] = s.GetUserRank(ctx, "u1")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range ranks {
+		if errs[i] != nil || ranks[i] != 7 {
+			t.Fatalf("caller %d got %d, %v, want the cached 7", i, ranks[i], errs[i])
+		}
+	}
+	// The refresh starts behind the callers; give any second one time to
+	// show up before counting.
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&store.ranks) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := atomic.LoadInt32(&store.ranks); n != 1 {
+		t.Fatalf("%d loads in flight, want 1", n)
+	}
+
+	close(store.release)
+	for {
+		rank, _, err := c.GetRank(ctx, epoch, "u1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rank == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cached rank = %d after the refresh, want 1", rank)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&store.ranks); n != 1 {
+		t.Fatalf("%d loads, want 1", n)
+	}
+}
+
+// Two tenants holding the same user IDs and usernames must each see only
+// their own users, ranks and pages, cached or not.
+func TestTenantsAreIsolated(t *testing.T) {
+	s := newTestUserService()
+	chess := tenant.WithID(context.Background(), "chess")
+	golf := tenant.WithID(context.Background(), "golf")
+	boards := map[context.Context]map[string]int32{
+		chess: {"alice": 2000, "bob": 1000},
+		golf:  {"alice": 1000, "bob": 2000, "carol": 1500},
+	}
+	for ctx, ratings := range boards {
+		for name, rating := range ratings {
+			if _, err := s.CreateUser(ctx, "u-"+name, name, rating); err != nil {
+				t.Fatalf("CreateUser(%s, %s): %v", tenant.ID(ctx), name, err)
+			}
+		}
+	}
+
+	// Reading twice serves the second read from the cache.
+	for i := 0; i < 2; i++ {
+		for ctx, want := range map[context.Context]struct {
+			rating int32
+			rank   int64
+			top    string
+			total  int64
+		}{
+			chess: {rating: 2000, rank: 1, top: "alice", total: 2},
+			golf:  {rating: 1000, rank: 3, top: "bob", total: 3},
+		} {
+			id := tenant.ID(ctx)
+			user, rank, err := s.GetUserByID(ctx, "u-alice")
+			if err != nil {
+				t.Fatalf("%s: GetUserByID: %v", id, err)
+			}
+			if user.Rating != want.rating || rank != want.rank {
+				t.Errorf("%s: alice = %d at rank %d, want %d at rank %d", id, user.Rating, rank, want.rating, want.rank)
+			}
+
+			page, err := s.GetLeaderboard(ctx, 1, 10)
+			if err != nil {
+				t.Fatalf("%s: GetLeaderboard: %v", id, err)
+			}
+			if len(page.Entries) == 0 || page.Entries[0].Username != want.top || page.Total != want.total {
+				t.Errorf("%s: leaderboard = %+v, want %s on top of %d", id, page, want.top, want.total)
+			}
+		}
+	}
+
+	if user, _, err := s.GetUserByID(chess, "u-carol"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("chess sees golf's carol: %+v, %v", user, err)
+	}
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"leaderboard-system/models"
+	"leaderboard-system/notifier"
+	"leaderboard-system/repository"
+	"leaderboard-system/tenant"
+)
+
+const (
+	MaxWatchesPerUser = 50
+)
+
+var (
+	ErrWatchNotFound       = NewError(ErrNotFound, "WATCH_NOT_FOUND", "watch not found")
+	ErrWatchTargetNotFound = NewError(ErrNotFound, "TARGET_NOT_FOUND", "target user not found")
+	ErrWatchLimitReached   = NewError(ErrConflict, "WATCH_LIMIT_REACHED", "watch limit reached")
+)
+
+
+type WatchService struct {
+	watchRepo repository.WatchStore
+	userRepo  repository.UserStore
+	notifier  notifier.Notifier
+	logger    *zap.Logger
+}
+
+
+func NewWatchService(watchRepo repository.WatchStore, userRepo repository.UserStore, n notifier.Notifier, logger *zap.Logger) *WatchService {
+	return &WatchService{
+		watchRepo: watchRepo,
+		userRepo:  userRepo,
+		notifier:  n,
+		logger:    logger,
+	}
+}
+
+
+func (s *WatchService) CreateWatch(ctx context.Context, watcherID string, watchType models.WatchType, targetID string, threshold int64) (*models.Watch, error) {
+	watcher, err := s.userRepo.GetUserByID(ctx, watcherID)
+	if err != nil {
+		return nil, err
+	}
+	if watcher == nil {
+		return nil, ErrUserNotFound
+	}
+
+	switch watchType {
+	case models.WatchTypeRival:
+		if targetID == "" {
+			return nil, NewValidationError("TARGET_REQUIRED", "target_id is required for rival watches",
+				FieldError{Field: "target_id", Message: "is required for rival watches"})
+		}
+		if targetID == watcherID {
+			return nil, NewValidationError("CANNOT_WATCH_SELF", "cannot watch yourself",
+				FieldError{Field: "target_id", Message: "must not be your own user ID"})
+		}
+		target, err := s.userRepo.GetUserByID(ctx, targetID)
+		if err != nil {
+			return nil, err
+		}
+		if target == nil {
+			return nil, ErrWatchTargetNotFound
+		}
+		threshold = 0
+	case models.WatchTypeRankThreshold:
+		if threshold < 1 {
+			return nil, NewValidationError("INVALID_THRESHOLD", "threshold must be at least 1",
+				FieldError{Field: "threshold", Message: "must be at least 1"})
+		}
+		targetID = ""
+	default:
+		return nil, NewValidationError("INVALID_WATCH_TYPE", fmt.Sprintf("unknown watch type: %s", watchType),
+			FieldError{Field: "type", Message: "must be rival or rank_threshold"})
+	}
+
+	count, err := s.watchRepo.CountWatchesByWatcher(ctx, watcherID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxWatchesPerUser {
+		return nil, fmt.Errorf("%w: at most %d per user", ErrWatchLimitReached, MaxWatchesPerUser)
+	}
+
+	watch := &models.Watch{
+		ID:        uuid.NewString(),
+		WatcherID: watcherID,
+		Type:      watchType,
+		TargetID:  targetID,
+		Threshold: threshold,
+	}
+
+	if err := s.watchRepo.CreateWatch(ctx, watch); err != nil {
+		s.logger.Error("Failed to create watch", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Watch created",
+		zap.String("watch_id", watch.ID),
+		zap.String("watcher_id", watcherID),
+		zap.String("type", string(watchType)),
+	)
+	return watch, nil
+}
+
+
+func (s *WatchService) ListWatches(ctx context.Context, watcherID string) ([]models.Watch, error) {
+	return s.watchRepo.GetWatchesByWatcher(ctx, watcherID)
+}
+
+
+func (s *WatchService) DeleteWatch(ctx context.Context, watcherID, watchID string) error {
+	deleted, err := s.watchRepo.DeleteWatch(ctx, watcherID, watchID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWatchNotFound
+	}
+	return nil
+}
+
+// OnRatingChange fires the watches affected by a single rating change. Only
+// users whose rating lies between the old and new value can have been
+// crossed, so every lookup is bounded by that rating window.
+func (s *WatchService) OnRatingChange(ctx context.Context, change models.RatingChange) {
+	var err error
+	switch {
+	case change.NewRating > change.OldRating:
+		err = s.evaluateRise(ctx, change)
+	case change.NewRating < change.OldRating:
+		err = s.evaluateDrop(ctx, change)
+	}
+	if err != nil {
+		s.logger.Warn("Failed to evaluate watches",
+			zap.String("user_id", change.UserID),
+			zap.Error(err),
+		)
+	}
+}
+
+// evaluateRise handles a mover climbing from OldRating to NewRating. Users
+// rated in [OldRating, NewRating) have been passed and drop one rank.
+func (s *WatchService) evaluateRise(ctx context.Context, change models.RatingChange) error {
+	rivals, err := s.watchRepo.GetRivalWatchesOnTarget(ctx, change.UserID, change.OldRating, change.NewRating)
+	if err != nil {
+		return err
+	}
+	for _, m := range rivals {
+		s.notify(ctx, models.Notification{
+			WatchID:     m.ID,
+			RecipientID: m.WatcherID,
+			Kind:        models.NotificationOvertaken,
+			ActorID:     change.UserID,
+			Timestamp:   change.Timestamp,
+		})
+	}
+
+	thresholds, err := s.watchRepo.GetThresholdWatchesInRange(ctx, change.OldRating, change.NewRating)
+	if err != nil {
+		return err
+	}
+
+	if len(thresholds) == 0 {
+		return nil
+	}
+	// One read ranks every rating in the window, however many watchers
+	// sit there.
+	ranks, err := s.userRepo.RanksInRange(ctx, change.OldRating, change.NewRating)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range thresholds {
+		if m.WatcherID == change.UserID {
+			continue
+		}
+		rank := ranks[m.SubjectRating]
+
+		// Displaced users moved down exactly one place, so the watch fires
+		// only for the one whose new rank sits just past the threshold.
+		if rank == m.Threshold+1 {
+			s.notify(ctx, models.Notification{
+				WatchID:     m.ID,
+				RecipientID: m.WatcherID,
+				Kind:        models.NotificationLeftRankThreshold,
+				ActorID:     change.UserID,
+				Rank:        rank,
+				Threshold:   m.Threshold,
+				Timestamp:   change.Timestamp,
+			})
+		}
+	}
+
+	return nil
+}
+
+// evaluateDrop handles a mover falling from OldRating to NewRating. Users
+// rated in (NewRating, OldRating] now sit above the mover.
+func (s *WatchService) evaluateDrop(ctx context.Context, change models.RatingChange) error {
+	rivals, err := s.watchRepo.GetRivalWatchesOfWatcher(ctx, change.UserID, change.NewRating+1, change.OldRating+1)
+	if err != nil {
+		return err
+	}
+	for _, m := range rivals {
+		s.notify(ctx, models.Notification{
+			WatchID:     m.ID,
+			RecipientID: change.UserID,
+			Kind:        models.NotificationOvertaken,
+			ActorID:     m.TargetID,
+			Timestamp:   change.Timestamp,
+		})
+	}
+
+	thresholds, err := s.watchRepo.GetThresholdWatchesOfWatcher(ctx, change.UserID)
+	if err != nil {
+		return err
+	}
+	if len(thresholds) == 0 {
+		return nil
+	}
+
+	aboveOld, err := s.userRepo.CountUsersAbove(ctx, change.OldRating)
+	if err != nil {
+		return err
+	}
+	aboveNew, err := s.userRepo.CountUsersAbove(ctx, change.NewRating)
+	if err != nil {
+		return err
+	}
+	oldRank, newRank := aboveOld+1, aboveNew+1
+
+	for _, w := range thresholds {
+		if oldRank <= w.Threshold && newRank > w.Threshold {
+			s.notify(ctx, models.Notification{
+				WatchID:     w.ID,
+				RecipientID: change.UserID,
+				Kind:        models.NotificationLeftRankThreshold,
+				Rank:        newRank,
+				Threshold:   w.Threshold,
+				Timestamp:   change.Timestamp,
+			})
+		}
+	}
+
+	return nil
+}
+
+
+func (s *WatchService) notify(ctx context.Context, n models.Notification) {
+	if n.Timestamp.IsZero() {
+		n.Timestamp = time.Now().UTC()
+	}
+	n.TenantID = tenant.ID(ctx)
+	if err := s.notifier.Notify(ctx, n); err != nil {
+		s.logger.Warn("Failed to send notification",
+			zap.String("watch_id", n.WatchID),
+			zap.Error(err),
+		)
+	}
+}
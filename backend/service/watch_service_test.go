@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+	"leaderboard-system/models"
+	"leaderboard-system/notifier"
+	"leaderboard-system/repository"
+)
+
+// fired is the part of a notification the tests assert on.
+type fired struct {
+	watch, recipient string
+	kind             models.NotificationKind
+	actor            string
+	rank, threshold  int64
+}
+
+// The board is alice 2000, bob 1500, carol 1200 and dave 1000, so ranks 1
+// to 4. Each case holds some watches, moves one user and checks which fire.
+func TestOnRatingChangeNotifications(t *testing.T) {
+	cases := []struct {
+		name    string
+		watches []models.Watch
+		mover   string
+		to      int32
+		want    []fired
+	}{
+		{
+			name:    "rise passes the rival's watcher",
+			watches: []models.Watch{{ID: "w1", WatcherID: "bob", Type: models.WatchTypeRival, TargetID: "dave"}},
+			mover:   "dave", to: 1600,
+			want: []fired{{watch: "w1", recipient: "bob", kind: models.NotificationOvertaken, actor: "dave"}},
+		},
+		{
+			name:    "rise stops short of the rival's watcher",
+			watches: []models.Watch{{ID: "w1", WatcherID: "bob", Type: models.WatchTypeRival, TargetID: "dave"}},
+			mover:   "dave", to: 1400,
+		},
+		{
+			name:    "rise past someone the watcher does not watch",
+			watches: []models.Watch{{ID: "w1", WatcherID: "bob", Type: models.WatchTypeRival, TargetID: "alice"}},
+			mover:   "dave", to: 1600,
+		},
+		{
+			name:    "watcher drops below the rival",
+			watches: []models.Watch{{ID: "w1", WatcherID: "bob", Type: models.WatchTypeRival, TargetID: "carol"}},
+			mover:   "bob", to: 1100,
+			want: []fired{{watch: "w1", recipient: "bob", kind: models.NotificationOvertaken, actor: "carol"}},
+		},
+		{
+			name:    "watcher drops to a tie it still wins",
+			watches: []models.Watch{{ID: "w1", WatcherID: "bob", Type: models.WatchTypeRival, TargetID: "carol"}},
+			mover:   "bob", to: 1200,
+		},
+		{
+			name:    "rise pushes a watcher out of its threshold",
+			watches: []models.Watch{{ID: "w1", WatcherID: "bob", Type: models.WatchTypeRankThreshold, Threshold: 2}},
+			mover:   "dave", to: 1600,
+			want: []fired{{watch: "w1", recipient: "bob", kind: models.NotificationLeftRankThreshold, actor: "dave", rank: 3, threshold: 2}},
+		},
+		{
+			name:    "rise pushes down a watcher already outside its threshold",
+			watches: []models.Watch{{ID: "w1", WatcherID: "carol", Type: models.WatchTypeRankThreshold, Threshold: 2}},
+			mover:   "dave", to: 1600,
+		},
+		{
+			name: "rise past several watchers ranks each at its own rating",
+			watches: []models.Watch{
+				{ID: "w1", WatcherID: "alice", Type: models.WatchTypeRankThreshold, Threshold: 1},
+				{ID: "w2", WatcherID: "bob", Type: models.WatchTypeRankThreshold, Threshold: 2},
+				{ID: "w3", WatcherID: "carol", Type: models.WatchTypeRankThreshold, Threshold: 2},
+			},
+			mover: "dave", to: 2100,
+			want: []fired{
+				{watch: "w1", recipient: "alice", kind: models.NotificationLeftRankThreshold, actor: "dave", rank: 2, threshold: 1},
+				{watch: "w2", recipient: "bob", kind: models.NotificationLeftRankThreshold, actor: "dave", rank: 3, threshold: 2},
+			},
+		},
+		{
+			name:    "watcher drops out of its threshold",
+			watches: []models.Watch{{ID: "w1", WatcherID: "bob", Type: models.WatchTypeRankThreshold, Threshold: 2}},
+			mover:   "bob", to: 1100,
+			want: []fired{{watch: "w1", recipient: "bob", kind: models.NotificationLeftRankThreshold, rank: 3, threshold: 2}},
+		},
+		{
+			name:    "watcher drops but stays within its threshold",
+			watches: []models.Watch{{ID: "w1", WatcherID: "bob", Type: models.WatchTypeRankThreshold, Threshold: 3}},
+			mover:   "bob", to: 1300,
+		},
+		{
+			name: "unchanged rating",
+			watches: []models.Watch{
+				{ID: "w1", WatcherID: "bob", Type: models.WatchTypeRival, TargetID: "dave"},
+				{ID: "w2", WatcherID: "bob", Type: models.WatchTypeRankThreshold, Threshold: 1},
+			},
+			mover: "dave", to: 1000,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			users := repository.NewMemoryUserStore()
+			ratings := map[string]int32{"alice": 2000, "bob": 1500, "carol": 1200, "dave": 1000}
+			for id, rating := range ratings {
+				if err := users.CreateUser(ctx, &models.User{ID: id, Username: id, Rating: rating}); err != nil {
+					t.Fatalf("CreateUser(%s): %v", id, err)
+				}
+			}
+			watches := repository.NewMemoryWatchStore(users)
+			for i := range tc.watches {
+				if err := watches.CreateWatch(ctx, &tc.watches[i]); err != nil {
+					t.Fatalf("CreateWatch(%s): %v", tc.watches[i].ID, err)
+				}
+			}
+			n := notifier.NewMemoryNotifier()
+			s := NewWatchService(watches, users, n, zap.NewNop())
+
+			// Listeners run after the rating is written.
+			if err := users.UpdateUserRating(ctx, tc.mover, tc.to); err != nil {
+				t.Fatalf("UpdateUserRating(%s, %d): %v", tc.mover, tc.to, err)
+			}
+			s.OnRatingChange(ctx, models.RatingChange{
+				UserID:    tc.mover,
+				Username:  tc.mover,
+				OldRating: ratings[tc.mover],
+				NewRating: tc.to,
+			})
+
+			var got []fired
+			for _, note := range n.Notifications() {
+				got = append(got, fired{
+					watch:     note.WatchID,
+					recipient: note.RecipientID,
+					kind:      note.Kind,
+					actor:     note.ActorID,
+					rank:      note.Rank,
+					threshold: note.Threshold,
+				})
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("notifications = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
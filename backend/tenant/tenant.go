@@ -0,0 +1,43 @@
+// Package tenant carries the tenant a request acts for through a context.
+// Repositories scope every query and the cache every key by it, so code in
+// between never handles tenant IDs itself.
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// Default is the tenant of requests that name none, and of every row that
+// existed before tenants did.
+const Default = "default"
+
+// idPattern keeps tenant IDs safe inside cache keys and URLs.
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+type contextKey struct{}
+
+// WithID returns a context acting for tenant id.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// ID returns the tenant ctx acts for, or Default when none was set.
+func ID(ctx context.Context) string {
+	if id, ok := ctx.Value(contextKey{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
+
+// Detach returns a background context acting for the same tenant as ctx, for
+// work that outlives the request.
+func Detach(ctx context.Context) context.Context {
+	return WithID(context.Background(), ID(ctx))
+}
+
+// ValidID reports whether id may name a tenant: 1 to 64 lowercase letters,
+// digits, '-' or '_', starting with a letter or digit.
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
@@ -1,5 +1,5 @@
 import { useCallback, useState, useRef, useEffect } from 'react';
-import { userAPI, LeaderboardResponse } from '../services/api';
+import { userAPI, LeaderboardResponse, errorMessage } from '../services/api';
 
 
 export const useLeaderboard = () => {
@@ -18,7 +18,7 @@ export const useLeaderboard = () => {
             setData(result);
             setCurrentPage(page);
         } catch (err: any) {
-            setError(err?.response?.data?.message || 'Failed to fetch leaderboard');
+            setError(errorMessage(err, 'Failed to fetch leaderboard'));
             console.error('Leaderboard fetch error:', err);
         } finally {
             setLoading(false);
@@ -75,7 +75,7 @@ export const useSearch = () => {
             const result = await userAPI.searchUser(searchQuery);
             setResult(result);
         } catch (err: any) {
-            setError(err?.response?.data?.message || 'Search failed');
+            setError(errorMessage(err, 'Search failed'));
             console.error('Search error:', err);
         } finally {
             setLoading(false);
@@ -124,7 +124,7 @@ export const useUserRank = (userId: string | null) => {
             const result = await userAPI.getUser(userId);
             setUser(result);
         } catch (err: any) {
-            setError(err?.response?.data?.message || 'Failed to fetch user');
+            setError(errorMessage(err, 'Failed to fetch user'));
             console.error('User fetch error:', err);
         } finally {
             setLoading(false);
@@ -10,7 +10,7 @@ import {
   ScrollView,
   Alert,
 } from "react-native";
-import { userAPI } from "../services/api";
+import { userAPI, problemOf, errorMessage } from "../services/api";
 
 const ProfileScreen: React.FC = () => {
   const [userId, setUserId] = useState("");
@@ -49,7 +49,9 @@ const ProfileScreen: React.FC = () => {
     } catch (error: any) {
       Alert.alert(
         "Error",
-        error?.response?.data?.message || "Failed to create user",
+        problemOf(error)?.code === "USER_EXISTS"
+          ? "That user ID or username is already taken"
+          : errorMessage(error, "Failed to create user"),
       );
     } finally {
       setLoading(false);
@@ -76,7 +78,7 @@ const ProfileScreen: React.FC = () => {
     } catch (error: any) {
       Alert.alert(
         "Error",
-        error?.response?.data?.message || "Failed to update rating",
+        errorMessage(error, "Failed to update rating"),
       );
     } finally {
       setUpdatingRating(false);
@@ -95,7 +97,12 @@ const ProfileScreen: React.FC = () => {
       setUserInfo(result);
       setNewRating(result.rating.toString());
     } catch (error: any) {
-      Alert.alert("Error", error?.response?.data?.message || "User not found");
+      Alert.alert(
+        "Error",
+        problemOf(error)?.code === "USER_NOT_FOUND"
+          ? "User not found"
+          : errorMessage(error, "Failed to fetch user"),
+      );
     } finally {
       setLoading(false);
     }
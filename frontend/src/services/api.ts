@@ -39,6 +39,7 @@ export interface User {
     username: string;
     rating: number;
     rank?: number;
+    metadata?: Record<string, unknown>;
 }
 
 export interface LeaderboardEntry {
@@ -53,8 +54,64 @@ export interface LeaderboardResponse {
     page: number;
     page_size: number;
     has_more: boolean;
+    version: number;
 }
 
+export interface LeaderboardChangesResponse {
+    since: number;
+    version: number;
+    full_refetch: boolean;
+    changes: LeaderboardEntry[];
+}
+
+export interface UserListParams {
+    username?: string;
+    min_rating?: number;
+    max_rating?: number;
+    created_after?: string;
+    created_before?: string;
+    sort?: 'created_at' | 'rating' | 'username';
+    order?: 'asc' | 'desc';
+    page?: number;
+    page_size?: number;
+}
+
+export interface UserListResponse {
+    users: (User & { created_at: string; updated_at: string })[];
+    total: number;
+    page: number;
+    page_size: number;
+    has_more: boolean;
+    sort: string;
+    order: string;
+}
+
+export interface UserPatch {
+    username?: string;
+    // Keys set to null are removed.
+    metadata?: Record<string, unknown>;
+}
+
+// Error responses are RFC 9457 problem+json. Switch on code, not on detail,
+// which is meant for people.
+export interface Problem {
+    type: string;
+    title: string;
+    status: number;
+    detail: string;
+    instance: string;
+    code: string;
+    errors?: { field: string; message: string }[];
+}
+
+export const problemOf = (err: any): Problem | undefined => {
+    const data = err?.response?.data;
+    return data && typeof data.code === 'string' ? data : undefined;
+};
+
+export const errorMessage = (err: any, fallback: string): string =>
+    problemOf(err)?.detail || fallback;
+
 export interface SearchResult {
     user: User | null;
     rank: number;
@@ -80,6 +137,18 @@ export const userAPI = {
     },
 
 
+    listUsers: async (params: UserListParams = {}): Promise<UserListResponse> => {
+        const response = await axiosInstance.get('/users', { params });
+        return response.data.data;
+    },
+
+
+    updateUser: async (userId: string, patch: UserPatch): Promise<User> => {
+        const response = await axiosInstance.patch(`/users/${userId}`, patch);
+        return response.data.data;
+    },
+
+
     updateRating: async (userId: string, rating: number): Promise<User> => {
         const response = await axiosInstance.put(`/users/${userId}/rating`, {
             rating,
@@ -104,6 +173,14 @@ export const userAPI = {
     },
 
 
+    getLeaderboardChanges: async (since: number): Promise<LeaderboardChangesResponse> => {
+        const response = await axiosInstance.get('/leaderboard/changes', {
+            params: { since },
+        });
+        return response.data.data;
+    },
+
+
     getLeaderboardAroundUser: async (userId: string, contextSize: number = 10): Promise<LeaderboardResponse> => {
         const response = await axiosInstance.get(`/users/${userId}/leaderboard-context`, {
             params: { context_size: contextSize },